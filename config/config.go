@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,16 +20,162 @@ var (
 
 var msg = []byte{32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 45, 45, 44, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 10, 44, 45, 46, 45, 45, 45, 45, 46, 32, 32, 32, 44, 45, 45, 45, 46, 39, 124, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 45, 45, 45, 45, 46, 46, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 45, 45, 46, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 10, 92, 32, 32, 32, 32, 47, 32, 32, 92, 32, 32, 124, 32, 32, 32, 124, 32, 58, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 46, 45, 45, 46, 45, 45, 46, 32, 32, 32, 32, 32, 32, 47, 32, 32, 32, 47, 32, 32, 32, 92, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 45, 45, 46, 39, 124, 32, 32, 32, 32, 44, 45, 45, 45, 44, 46, 32, 10, 124, 32, 32, 32, 58, 32, 32, 32, 32, 92, 32, 58, 32, 32, 32, 58, 32, 124, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 45, 45, 44, 32, 47, 32, 32, 47, 32, 32, 32, 32, 39, 46, 32, 32, 32, 47, 32, 32, 32, 46, 32, 32, 32, 32, 32, 58, 32, 32, 32, 32, 44, 45, 45, 44, 58, 32, 32, 58, 32, 124, 32, 32, 44, 39, 32, 32, 46, 39, 32, 124, 32, 10, 124, 32, 32, 32, 124, 32, 32, 46, 92, 32, 58, 124, 32, 32, 32, 39, 32, 58, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 44, 39, 95, 32, 47, 124, 124, 32, 32, 58, 32, 32, 47, 96, 46, 32, 47, 32, 32, 46, 32, 32, 32, 47, 32, 32, 32, 59, 46, 32, 32, 92, 44, 96, 45, 45, 46, 39, 96, 124, 32, 32, 39, 32, 58, 44, 45, 45, 45, 46, 39, 32, 32, 32, 124, 32, 10, 46, 32, 32, 32, 58, 32, 32, 124, 58, 32, 124, 59, 32, 32, 32, 59, 32, 39, 32, 32, 32, 32, 32, 46, 45, 45, 46, 32, 124, 32, 32, 124, 32, 58, 59, 32, 32, 124, 32, 32, 124, 45, 45, 96, 32, 32, 46, 32, 32, 32, 59, 32, 32, 32, 47, 32, 32, 96, 32, 59, 124, 32, 32, 32, 58, 32, 32, 58, 32, 32, 124, 32, 124, 124, 32, 32, 32, 124, 32, 32, 32, 46, 39, 32, 10, 124, 32, 32, 32, 124, 32, 32, 32, 92, 32, 58, 39, 32, 32, 32, 124, 32, 124, 95, 95, 32, 44, 39, 95, 32, 47, 124, 32, 58, 32, 32, 46, 32, 124, 124, 32, 32, 58, 32, 32, 59, 95, 32, 32, 32, 32, 59, 32, 32, 32, 124, 32, 32, 59, 32, 92, 32, 59, 32, 124, 58, 32, 32, 32, 124, 32, 32, 32, 92, 32, 124, 32, 58, 58, 32, 32, 32, 58, 32, 32, 124, 45, 44, 32, 10, 124, 32, 32, 32, 58, 32, 46, 32, 32, 32, 47, 124, 32, 32, 32, 124, 32, 58, 46, 39, 124, 124, 32, 32, 39, 32, 124, 32, 124, 32, 32, 46, 32, 46, 32, 92, 32, 32, 92, 32, 32, 32, 32, 96, 46, 32, 124, 32, 32, 32, 58, 32, 32, 124, 32, 59, 32, 124, 32, 39, 124, 32, 32, 32, 58, 32, 39, 32, 32, 39, 59, 32, 124, 58, 32, 32, 32, 124, 32, 32, 59, 47, 124, 32, 10, 59, 32, 32, 32, 124, 32, 124, 96, 45, 39, 32, 39, 32, 32, 32, 58, 32, 32, 32, 32, 59, 124, 32, 32, 124, 32, 39, 32, 124, 32, 32, 124, 32, 124, 32, 32, 96, 45, 45, 45, 45, 46, 32, 32, 32, 92, 46, 32, 32, 32, 124, 32, 32, 39, 32, 39, 32, 39, 32, 58, 39, 32, 32, 32, 39, 32, 59, 46, 32, 32, 32, 32, 59, 124, 32, 32, 32, 58, 32, 32, 32, 46, 39, 32, 10, 124, 32, 32, 32, 124, 32, 59, 32, 32, 32, 32, 124, 32, 32, 32, 124, 32, 32, 46, 47, 32, 58, 32, 32, 124, 32, 124, 32, 58, 32, 32, 39, 32, 59, 32, 32, 95, 95, 32, 92, 32, 32, 92, 32, 32, 124, 39, 32, 32, 32, 59, 32, 32, 92, 59, 32, 47, 32, 32, 124, 124, 32, 32, 32, 124, 32, 124, 32, 92, 32, 32, 32, 124, 124, 32, 32, 32, 124, 32, 32, 124, 45, 44, 32, 10, 58, 32, 32, 32, 39, 32, 124, 32, 32, 32, 32, 59, 32, 32, 32, 58, 32, 59, 32, 32, 32, 124, 32, 32, 59, 32, 39, 32, 124, 32, 32, 124, 32, 39, 32, 47, 32, 32, 47, 96, 45, 45, 39, 32, 32, 47, 32, 92, 32, 32, 32, 92, 32, 32, 39, 44, 32, 32, 47, 32, 39, 32, 32, 32, 58, 32, 124, 32, 32, 59, 32, 46, 39, 39, 32, 32, 32, 58, 32, 32, 59, 47, 124, 32, 10, 58, 32, 32, 32, 58, 32, 58, 32, 32, 32, 32, 124, 32, 32, 32, 44, 47, 32, 32, 32, 32, 58, 32, 32, 124, 32, 58, 32, 59, 32, 32, 59, 32, 124, 39, 45, 45, 39, 46, 32, 32, 32, 32, 32, 47, 32, 32, 32, 59, 32, 32, 32, 58, 32, 32, 32, 32, 47, 32, 32, 124, 32, 32, 32, 124, 32, 39, 96, 45, 45, 39, 32, 32, 124, 32, 32, 32, 124, 32, 32, 32, 32, 92, 32, 10, 124, 32, 32, 32, 124, 32, 58, 32, 32, 32, 32, 39, 45, 45, 45, 39, 32, 32, 32, 32, 32, 39, 32, 32, 58, 32, 32, 96, 45, 45, 39, 32, 32, 32, 92, 32, 96, 45, 45, 39, 45, 45, 45, 39, 32, 32, 32, 32, 32, 92, 32, 32, 32, 92, 32, 46, 39, 32, 32, 32, 39, 32, 32, 32, 58, 32, 124, 32, 32, 32, 32, 32, 32, 124, 32, 32, 32, 58, 32, 32, 32, 46, 39, 32, 10, 96, 45, 45, 45, 39, 46, 124, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 58, 32, 32, 44, 32, 32, 32, 32, 32, 32, 46, 45, 46, 47, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 96, 45, 45, 45, 96, 32, 32, 32, 32, 32, 59, 32, 32, 32, 124, 46, 39, 32, 32, 32, 32, 32, 32, 124, 32, 32, 32, 124, 32, 44, 39, 32, 32, 32, 10, 32, 32, 96, 45, 45, 45, 96, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 96, 45, 45, 96, 45, 45, 45, 45, 39, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 39, 45, 45, 45, 39, 32, 32, 32, 32, 32, 32, 32, 32, 96, 45, 45, 45, 45, 39, 32, 32, 32, 32, 32, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
 
+// defaultJWTSecret JWT_SECRET 的默认值，是公开的字符串，不能用作其他用途的签名密钥
+const defaultJWTSecret = "your_jwt_secret_key"
+
+// minSecretLength 专用签名密钥的最短长度
+const minSecretLength = 32
+
+// bcryptMaxPasswordBytes bcrypt 能处理的最大密码长度 (字节)
+const bcryptMaxPasswordBytes = 72
+
 // Config 应用配置
 type Config struct {
-	DBType        string
-	DBSource      string
-	JWTSecret     string
-	ServerPort    string
-	DBLogLevel    string // "silent", "info", "warn", "error"
-	RedisAddr     string
-	RedisPassword string
-	RedisDB       int
+	DBType     string
+	DBSource   string
+	JWTSecret  string
+	ServerPort string
+	// 可信的反向代理 (IP 或 CIDR)，只有来自这些地址的请求才采信 X-Forwarded-For，为空时使用连接的对端地址
+	TrustedProxies []string
+	DBLogLevel     string // "silent", "info", "warn", "error"
+	RedisAddr      string
+	RedisPassword  string
+	RedisDB        int
+
+	JWTAlgorithm        string // "HS256", "RS256", "ES256", "ES384", "ES512", "EdDSA"
+	JWTSigningKeyFile   string // 非对称算法的 PEM 私钥文件
+	JWTSigningKeyID     string // 签名密钥的 kid，留空则根据公钥生成
+	JWTVerificationKeys string // 轮换期间仍然接受的旧密钥, 格式 "kid1=path1,kid2=path2"
+	JWTIssuer           string
+	JWTAudience         string
+	AccessTokenTTL      time.Duration
+	JWTLeeway           time.Duration // 校验令牌时间声明时允许的时钟偏差
+
+	// 密码哈希配置，修改算法或参数后已有用户在下次登录时自动升级
+	PasswordHashAlgorithm string // "argon2id" 或 "bcrypt"
+	Argon2Memory          int    // argon2id 内存开销 (KiB)
+	Argon2Iterations      int
+	Argon2Parallelism     int
+	BcryptCost            int
+
+	// 密码策略，注册、重置和修改密码时校验
+	PasswordMinLength      int
+	PasswordMaxLength      int
+	PasswordMinCharClasses int    // 至少包含的字符类别数 (小写、大写、数字、符号)，0 或 1 表示不限制
+	PasswordMinEntropy     int    // zxcvbn 估算的最低熵 (bit)，0 表示不检查
+	PasswordRejectUserInfo bool   // 拒绝包含用户名或邮箱的密码
+	PasswordBreachedFile   string // 本地泄露密码库 (HIBP 格式的目录或排序文件)，为空时不检查
+
+	// 系统中没有管理员时用于创建第一个管理员
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
+	BootstrapAdminEmail    string
+
+	MFAIssuer string // 验证器应用中显示的签发方名称
+
+	// WebAuthn 依赖方配置，RPID 为站点的有效域名，Origins 为前端页面的完整来源
+	WebAuthnRPID      string
+	WebAuthnRPName    string
+	WebAuthnRPOrigins []string
+
+	AppBaseURL                   string        // 前端页面地址，用于生成邮件中的链接
+	PasswordResetTTL             time.Duration // 重置密码链接的有效期
+	PasswordResetRequestInterval time.Duration // 同一邮箱两次找回密码请求的最小间隔
+
+	// 邮件发送配置，MailDriver 为 "log" 时只写入日志
+	MailDriver   string
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// 邮箱验证配置，开启 EmailVerificationRequired 后邮箱未验证的用户不能登录
+	EmailVerificationRequired       bool
+	EmailVerificationSecret         string // 验证链接的签名密钥，必须单独配置
+	EmailVerificationTTL            time.Duration
+	EmailVerificationResendInterval time.Duration
+
+	// 注册防枚举模式：用户名或邮箱已被使用时注册接口仍返回成功，结果通过邮件告知注册时填写的邮箱
+	RegisterConcealConflicts bool
+
+	// 登录防暴力破解配置
+	LoginMaxAttempts     int           // 同一用户名在统计窗口内允许的失败次数
+	LoginIPMaxAttempts   int           // 同一 IP 在统计窗口内允许的失败次数
+	LoginAttemptWindow   time.Duration // 失败次数的统计窗口
+	LoginLockoutDuration time.Duration // 达到阈值后的锁定时长
+	LoginBackoffBase     time.Duration // 连续失败后的初始等待时间，每次失败翻倍
+	LoginBackoffMax      time.Duration // 单次等待时间的上限
+
+	ImpersonationTTL time.Duration // 管理员模拟用户登录时签发的令牌有效期
+	ReauthTokenTTL   time.Duration // 重新验证身份后签发的短期令牌有效期
+
+	// OpenID Connect 配置，OIDCIssuer 为本服务对外的根地址，发现文档中的端点均基于它生成
+	// OIDCProviderEnabled 开启身份提供方功能 (发现文档、ID Token 和用户信息端点)，要求 JWT 使用非对称算法签名
+	OIDCIssuer          string
+	OIDCProviderEnabled bool
+	OAuthConsentURL     string // 前端授权确认页面地址，未登录访问授权端点时跳转到这里
+
+	// 外部身份提供方登录 ("使用 xxx 登录")，名称列表来自 EXTERNAL_PROVIDERS
+	ExternalProviders []ExternalProviderConfig
+
+	// 用户名密码登录的认证后端，按顺序依次尝试，可选 database、ldap
+	Authenticators []string
+
+	// LDAP 认证配置：先使用服务账号按 LDAPUserFilter 查找用户，再以用户的 DN 和密码绑定校验
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string // %s 替换为转义后的用户名
+	LDAPEmailAttribute string
+	LDAPNameAttribute  string
+	LDAPGroupAttribute string
+	LDAPGroupRoles     map[string]string // 组 DN 到角色名的映射，为空时不同步角色
+	LDAPTimeout        time.Duration
+
+	// SAML 2.0 单点登录配置，本服务作为 SP，元数据和 ACS 地址基于 OIDCIssuer 生成
+	SAMLEnabled           bool
+	SAMLEntityID          string
+	SAMLCertFile          string // SP 证书和私钥 (PEM)，用于签名认证请求和解密断言
+	SAMLKeyFile           string
+	SAMLIDPMetadataURL    string // IdP 元数据地址和文件二选一
+	SAMLIDPMetadataFile   string
+	SAMLAllowIDPInitiated bool
+	SAMLUsernameAttribute string // 为空时使用 NameID
+	SAMLEmailAttribute    string
+	SAMLNicknameAttribute string
+	SAMLCallbackURL       string // 登录完成后跳转的前端页面，携带一次性的 code
+
+	// Cookie 会话模式，浏览器登录时请求头带 X-Auth-Mode: cookie 即可改为使用 HttpOnly Cookie 保存令牌
+	AuthCookieEnabled  bool
+	AuthCookieName     string // 访问令牌 Cookie 名称，刷新令牌和 CSRF 令牌分别使用 <name>_refresh 和 <name>_csrf
+	AuthCookieDomain   string
+	AuthCookieSecure   bool
+	AuthCookieSameSite string // lax、strict 或 none，none 要求 Secure
+	CSRFSecret         string // CSRF 令牌的签名密钥，启用 Cookie 会话时必须单独配置
+}
+
+// ExternalProviderConfig 一个外部身份提供方的配置，参数来自 EXTERNAL_<NAME>_* 环境变量
+// 配置了 Issuer 时按 OpenID Connect 自动发现端点并校验 ID Token；
+// 否则作为普通 OAuth2 提供方，必须配置 AuthURL、TokenURL 和 UserInfoURL，用户信息按 *Claim 指定的字段读取
+type ExternalProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string // 提供方回调的前端页面地址，默认为 APP_BASE_URL/auth/<name>/callback
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	SubjectClaim  string
+	EmailClaim    string
+	UsernameClaim string
+	NameClaim     string
 }
 
 // LoadConfig 从环境变量加载配置
@@ -44,21 +193,513 @@ func LoadConfig() (*Config, error) {
 			return
 		}
 
+		var accessTokenTTL, jwtLeeway time.Duration
+		accessTokenTTL, err = getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+		if err != nil {
+			return
+		}
+		jwtLeeway, err = getEnvDuration("JWT_LEEWAY", 30*time.Second)
+		if err != nil {
+			return
+		}
+
+		var argon2Memory, argon2Iterations, argon2Parallelism, bcryptCost int
+		argon2Memory, err = getEnvInt("ARGON2_MEMORY", 64*1024)
+		if err != nil {
+			return
+		}
+		argon2Iterations, err = getEnvInt("ARGON2_ITERATIONS", 3)
+		if err != nil {
+			return
+		}
+		argon2Parallelism, err = getEnvInt("ARGON2_PARALLELISM", 4)
+		if err != nil {
+			return
+		}
+		bcryptCost, err = getEnvInt("BCRYPT_COST", 12)
+		if err != nil {
+			return
+		}
+
+		var passwordMinLength, passwordMaxLength, passwordMinCharClasses, passwordMinEntropy int
+		passwordMinLength, err = getEnvInt("PASSWORD_MIN_LENGTH", 8)
+		if err != nil {
+			return
+		}
+		passwordMaxLength, err = getEnvInt("PASSWORD_MAX_LENGTH", 128)
+		if err != nil {
+			return
+		}
+		passwordMinCharClasses, err = getEnvInt("PASSWORD_MIN_CHAR_CLASSES", 1)
+		if err != nil {
+			return
+		}
+		passwordMinEntropy, err = getEnvInt("PASSWORD_MIN_ENTROPY", 20)
+		if err != nil {
+			return
+		}
+		var passwordRejectUserInfo bool
+		passwordRejectUserInfo, err = getEnvBool("PASSWORD_REJECT_USER_INFO", true)
+		if err != nil {
+			return
+		}
+
+		var passwordResetTTL, passwordResetRequestInterval time.Duration
+		passwordResetTTL, err = getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute)
+		if err != nil {
+			return
+		}
+		passwordResetRequestInterval, err = getEnvDuration("PASSWORD_RESET_REQUEST_INTERVAL", time.Minute)
+		if err != nil {
+			return
+		}
+
+		var emailVerificationRequired bool
+		emailVerificationRequired, err = getEnvBool("EMAIL_VERIFICATION_REQUIRED", false)
+		if err != nil {
+			return
+		}
+		var emailVerificationTTL, emailVerificationResendInterval time.Duration
+		emailVerificationTTL, err = getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+		if err != nil {
+			return
+		}
+		emailVerificationResendInterval, err = getEnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute)
+		if err != nil {
+			return
+		}
+
+		var registerConcealConflicts bool
+		registerConcealConflicts, err = getEnvBool("REGISTER_ANTI_ENUMERATION", false)
+		if err != nil {
+			return
+		}
+
+		var loginMaxAttempts, loginIPMaxAttempts int
+		loginMaxAttempts, err = getEnvInt("LOGIN_MAX_ATTEMPTS", 5)
+		if err != nil {
+			return
+		}
+		loginIPMaxAttempts, err = getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 50)
+		if err != nil {
+			return
+		}
+		var loginAttemptWindow, loginLockoutDuration, loginBackoffBase, loginBackoffMax time.Duration
+		loginAttemptWindow, err = getEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+		if err != nil {
+			return
+		}
+		loginLockoutDuration, err = getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+		if err != nil {
+			return
+		}
+		loginBackoffBase, err = getEnvDuration("LOGIN_BACKOFF_BASE", time.Second)
+		if err != nil {
+			return
+		}
+		loginBackoffMax, err = getEnvDuration("LOGIN_BACKOFF_MAX", 30*time.Second)
+		if err != nil {
+			return
+		}
+
+		var ldapStartTLS bool
+		ldapStartTLS, err = getEnvBool("LDAP_START_TLS", false)
+		if err != nil {
+			return
+		}
+		var ldapTimeout time.Duration
+		ldapTimeout, err = getEnvDuration("LDAP_TIMEOUT", 5*time.Second)
+		if err != nil {
+			return
+		}
+		var ldapGroupRoles map[string]string
+		ldapGroupRoles, err = parseGroupRoles(getEnv("LDAP_GROUP_ROLES", ""))
+		if err != nil {
+			return
+		}
+
+		var oidcProviderEnabled bool
+		oidcProviderEnabled, err = getEnvBool("OIDC_PROVIDER_ENABLED", false)
+		if err != nil {
+			return
+		}
+
+		var samlEnabled, samlAllowIDPInitiated bool
+		samlEnabled, err = getEnvBool("SAML_ENABLED", false)
+		if err != nil {
+			return
+		}
+		samlAllowIDPInitiated, err = getEnvBool("SAML_ALLOW_IDP_INITIATED", false)
+		if err != nil {
+			return
+		}
+
+		var authCookieEnabled, authCookieSecure bool
+		authCookieEnabled, err = getEnvBool("AUTH_COOKIE_ENABLED", false)
+		if err != nil {
+			return
+		}
+		authCookieSecure, err = getEnvBool("AUTH_COOKIE_SECURE", true)
+		if err != nil {
+			return
+		}
+
+		var impersonationTTL time.Duration
+		impersonationTTL, err = getEnvDuration("IMPERSONATION_TTL", 15*time.Minute)
+		if err != nil {
+			return
+		}
+		var reauthTokenTTL time.Duration
+		reauthTokenTTL, err = getEnvDuration("REAUTH_TOKEN_TTL", 5*time.Minute)
+		if err != nil {
+			return
+		}
+
 		config = &Config{
-			DBType:        getEnv("DB_TYPE", "sqlite"), // mysql 或 sqlite
-			DBSource:      getEnv("DB_SOURCE", "oneplusone.db"),
-			JWTSecret:     getEnv("JWT_SECRET", "your_jwt_secret_key"),
-			ServerPort:    getEnv("SERVER_PORT", "8080"),
-			DBLogLevel:    getEnv("DB_LOG_LEVEL", "info"),
-			RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
-			RedisPassword: getEnv("REDIS_PASSWORD", ""),
-			RedisDB:       redisDB,
+			DBType:         getEnv("DB_TYPE", "sqlite"), // mysql 或 sqlite
+			DBSource:       getEnv("DB_SOURCE", "oneplusone.db"),
+			JWTSecret:      getEnv("JWT_SECRET", defaultJWTSecret),
+			ServerPort:     getEnv("SERVER_PORT", "8080"),
+			TrustedProxies: getEnvList("TRUSTED_PROXIES", ""),
+			DBLogLevel:     getEnv("DB_LOG_LEVEL", "info"),
+			RedisAddr:      getEnv("REDIS_ADDR", "localhost:6379"),
+			RedisPassword:  getEnv("REDIS_PASSWORD", ""),
+			RedisDB:        redisDB,
+
+			JWTAlgorithm:        getEnv("JWT_ALGORITHM", "HS256"),
+			JWTSigningKeyFile:   getEnv("JWT_SIGNING_KEY_FILE", ""),
+			JWTSigningKeyID:     getEnv("JWT_SIGNING_KEY_ID", ""),
+			JWTVerificationKeys: getEnv("JWT_VERIFICATION_KEYS", ""),
+			JWTIssuer:           getEnv("JWT_ISSUER", "plusone"),
+			JWTAudience:         getEnv("JWT_AUDIENCE", "plusone-api"),
+			AccessTokenTTL:      accessTokenTTL,
+			JWTLeeway:           jwtLeeway,
+
+			PasswordHashAlgorithm: strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")),
+			Argon2Memory:          argon2Memory,
+			Argon2Iterations:      argon2Iterations,
+			Argon2Parallelism:     argon2Parallelism,
+			BcryptCost:            bcryptCost,
+
+			PasswordMinLength:      passwordMinLength,
+			PasswordMaxLength:      passwordMaxLength,
+			PasswordMinCharClasses: passwordMinCharClasses,
+			PasswordMinEntropy:     passwordMinEntropy,
+			PasswordRejectUserInfo: passwordRejectUserInfo,
+			PasswordBreachedFile:   getEnv("PASSWORD_BREACHED_FILE", ""),
+
+			BootstrapAdminUsername: getEnv("BOOTSTRAP_ADMIN_USERNAME", ""),
+			BootstrapAdminPassword: getEnv("BOOTSTRAP_ADMIN_PASSWORD", ""),
+			BootstrapAdminEmail:    getEnv("BOOTSTRAP_ADMIN_EMAIL", ""),
+
+			MFAIssuer: getEnv("MFA_ISSUER", "PlusOne"),
+
+			WebAuthnRPID:      getEnv("WEBAUTHN_RP_ID", "localhost"),
+			WebAuthnRPName:    getEnv("WEBAUTHN_RP_NAME", "PlusOne"),
+			WebAuthnRPOrigins: getEnvList("WEBAUTHN_RP_ORIGINS", "http://localhost:8080"),
+
+			AppBaseURL:                   getEnv("APP_BASE_URL", "http://localhost:8080"),
+			PasswordResetTTL:             passwordResetTTL,
+			PasswordResetRequestInterval: passwordResetRequestInterval,
+
+			MailDriver:   getEnv("MAIL_DRIVER", "log"),
+			MailFrom:     getEnv("MAIL_FROM", ""),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+			EmailVerificationRequired:       emailVerificationRequired,
+			EmailVerificationTTL:            emailVerificationTTL,
+			EmailVerificationResendInterval: emailVerificationResendInterval,
+
+			RegisterConcealConflicts: registerConcealConflicts,
+
+			LoginMaxAttempts:     loginMaxAttempts,
+			LoginIPMaxAttempts:   loginIPMaxAttempts,
+			LoginAttemptWindow:   loginAttemptWindow,
+			LoginLockoutDuration: loginLockoutDuration,
+			LoginBackoffBase:     loginBackoffBase,
+			LoginBackoffMax:      loginBackoffMax,
+
+			ImpersonationTTL: impersonationTTL,
+			ReauthTokenTTL:   reauthTokenTTL,
+
+			Authenticators: getEnvList("AUTHENTICATORS", "database"),
+
+			LDAPURL:            getEnv("LDAP_URL", ""),
+			LDAPStartTLS:       ldapStartTLS,
+			LDAPBindDN:         getEnv("LDAP_BIND_DN", ""),
+			LDAPBindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+			LDAPBaseDN:         getEnv("LDAP_BASE_DN", ""),
+			LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(uid=%s)"),
+			LDAPEmailAttribute: getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			LDAPNameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "displayName"),
+			LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+			LDAPGroupRoles:     ldapGroupRoles,
+			LDAPTimeout:        ldapTimeout,
+
+			OIDCProviderEnabled: oidcProviderEnabled,
+
+			SAMLEnabled:           samlEnabled,
+			SAMLCertFile:          getEnv("SAML_SP_CERT_FILE", ""),
+			SAMLKeyFile:           getEnv("SAML_SP_KEY_FILE", ""),
+			SAMLIDPMetadataURL:    getEnv("SAML_IDP_METADATA_URL", ""),
+			SAMLIDPMetadataFile:   getEnv("SAML_IDP_METADATA_FILE", ""),
+			SAMLAllowIDPInitiated: samlAllowIDPInitiated,
+			SAMLUsernameAttribute: getEnv("SAML_USERNAME_ATTRIBUTE", ""),
+			SAMLEmailAttribute:    getEnv("SAML_EMAIL_ATTRIBUTE", "email"),
+			SAMLNicknameAttribute: getEnv("SAML_NICKNAME_ATTRIBUTE", "displayName"),
+
+			AuthCookieEnabled:  authCookieEnabled,
+			AuthCookieName:     getEnv("AUTH_COOKIE_NAME", "plusone_session"),
+			AuthCookieDomain:   getEnv("AUTH_COOKIE_DOMAIN", ""),
+			AuthCookieSecure:   authCookieSecure,
+			AuthCookieSameSite: strings.ToLower(getEnv("AUTH_COOKIE_SAMESITE", "lax")),
+		}
+		config.EmailVerificationSecret = getEnv("EMAIL_VERIFICATION_SECRET", "")
+		config.CSRFSecret = getEnv("CSRF_SECRET", "")
+		config.OIDCIssuer = strings.TrimSuffix(getEnv("OIDC_ISSUER", config.AppBaseURL), "/")
+		config.OAuthConsentURL = getEnv("OAUTH_CONSENT_URL", strings.TrimSuffix(config.AppBaseURL, "/")+"/oauth/consent")
+		config.SAMLEntityID = getEnv("SAML_SP_ENTITY_ID", config.OIDCIssuer+"/saml/metadata")
+		config.SAMLCallbackURL = getEnv("SAML_CALLBACK_URL", strings.TrimSuffix(config.AppBaseURL, "/")+"/auth/saml/callback")
+		config.ExternalProviders, err = loadExternalProviders(config.AppBaseURL)
+		if err != nil {
+			return
+		}
+		if err = validateSecret(config, "EMAIL_VERIFICATION_SECRET", config.EmailVerificationSecret); err != nil {
+			return
+		}
+		if err = validateTrustedProxies(config); err != nil {
+			return
+		}
+		if err = validatePasswordHash(config); err != nil {
+			return
+		}
+		if err = validatePasswordPolicy(config); err != nil {
+			return
+		}
+		if err = validateAuthenticators(config); err != nil {
+			return
+		}
+		if err = validateOIDCProvider(config); err != nil {
+			return
+		}
+		if err = validateSAML(config); err != nil {
+			return
 		}
+		err = validateAuthCookie(config)
 	})
 
 	return config, err
 }
 
+// loadExternalProviders 加载 EXTERNAL_PROVIDERS 中列出的外部身份提供方配置
+func loadExternalProviders(appBaseURL string) ([]ExternalProviderConfig, error) {
+	var providers []ExternalProviderConfig
+	for _, name := range getEnvList("EXTERNAL_PROVIDERS", "") {
+		name = strings.ToLower(name)
+		if name == "ldap" || name == "saml" {
+			return nil, fmt.Errorf("external provider name %q is reserved", name)
+		}
+		prefix := "EXTERNAL_" + strings.ToUpper(name) + "_"
+		provider := ExternalProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnvList(prefix+"SCOPES", "openid,profile,email"),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", strings.TrimSuffix(appBaseURL, "/")+"/auth/"+name+"/callback"),
+
+			AuthURL:     getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:    getEnv(prefix+"TOKEN_URL", ""),
+			UserInfoURL: getEnv(prefix+"USERINFO_URL", ""),
+
+			SubjectClaim:  getEnv(prefix+"SUBJECT_CLAIM", "sub"),
+			EmailClaim:    getEnv(prefix+"EMAIL_CLAIM", "email"),
+			UsernameClaim: getEnv(prefix+"USERNAME_CLAIM", "preferred_username"),
+			NameClaim:     getEnv(prefix+"NAME_CLAIM", "name"),
+		}
+		if provider.ClientID == "" {
+			return nil, fmt.Errorf("missing %sCLIENT_ID", prefix)
+		}
+		if provider.Issuer == "" && (provider.AuthURL == "" || provider.TokenURL == "" || provider.UserInfoURL == "") {
+			return nil, fmt.Errorf("external provider %s requires %sISSUER or %sAUTH_URL, %sTOKEN_URL and %sUSERINFO_URL", name, prefix, prefix, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// validateSecret 校验专用的签名密钥：必须单独配置，不能是公开的默认值，也不能与 JWT_SECRET 相同
+// 使用非对称算法签发 JWT 时 JWT_SECRET 通常保持默认值，回退到它等于使用公开的密钥
+func validateSecret(cfg *Config, name, value string) error {
+	switch {
+	case value == "":
+		return fmt.Errorf("%s is required", name)
+	case value == defaultJWTSecret || value == cfg.JWTSecret:
+		return fmt.Errorf("%s must be a dedicated secret, not the default value or JWT_SECRET", name)
+	case len(value) < minSecretLength:
+		return fmt.Errorf("%s must be at least %d characters", name, minSecretLength)
+	}
+	return nil
+}
+
+// validateTrustedProxies 校验可信代理配置，每一项必须是 IP 或 CIDR
+func validateTrustedProxies(cfg *Config) error {
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES entry %q: must be an IP or CIDR", proxy)
+		}
+	}
+	return nil
+}
+
+// validatePasswordHash 校验密码哈希配置
+func validatePasswordHash(cfg *Config) error {
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id":
+		if cfg.Argon2Iterations < 1 {
+			return fmt.Errorf("ARGON2_ITERATIONS must be at least 1")
+		}
+		if cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return fmt.Errorf("ARGON2_PARALLELISM must be between 1 and 255")
+		}
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Memory > 4*1024*1024 {
+			return fmt.Errorf("ARGON2_MEMORY must be between 8*ARGON2_PARALLELISM and 4194304 KiB")
+		}
+	case "bcrypt":
+		if cfg.BcryptCost < 4 || cfg.BcryptCost > 31 {
+			return fmt.Errorf("BCRYPT_COST must be between 4 and 31")
+		}
+	default:
+		return fmt.Errorf("invalid PASSWORD_HASH_ALGORITHM %q: must be argon2id or bcrypt", cfg.PasswordHashAlgorithm)
+	}
+	return nil
+}
+
+// validatePasswordPolicy 校验密码策略配置，配置了泄露密码库时检查文件是否存在
+func validatePasswordPolicy(cfg *Config) error {
+	if cfg.PasswordMinLength < 1 {
+		return fmt.Errorf("PASSWORD_MIN_LENGTH must be at least 1")
+	}
+	if cfg.PasswordMaxLength < cfg.PasswordMinLength {
+		return fmt.Errorf("PASSWORD_MAX_LENGTH must not be less than PASSWORD_MIN_LENGTH")
+	}
+	// bcrypt 只能处理 72 字节以内的密码，更长的密码通过策略校验后会在哈希时失败
+	if cfg.PasswordHashAlgorithm == "bcrypt" && cfg.PasswordMaxLength > bcryptMaxPasswordBytes {
+		return fmt.Errorf("PASSWORD_MAX_LENGTH must not exceed %d when PASSWORD_HASH_ALGORITHM is bcrypt", bcryptMaxPasswordBytes)
+	}
+	if cfg.PasswordMinCharClasses < 0 || cfg.PasswordMinCharClasses > 4 {
+		return fmt.Errorf("PASSWORD_MIN_CHAR_CLASSES must be between 0 and 4")
+	}
+	if cfg.PasswordMinEntropy < 0 {
+		return fmt.Errorf("PASSWORD_MIN_ENTROPY must not be negative")
+	}
+	if cfg.PasswordBreachedFile != "" {
+		if _, err := os.Stat(cfg.PasswordBreachedFile); err != nil {
+			return fmt.Errorf("invalid PASSWORD_BREACHED_FILE: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateAuthenticators 校验认证后端配置
+func validateAuthenticators(cfg *Config) error {
+	if len(cfg.Authenticators) == 0 {
+		return fmt.Errorf("AUTHENTICATORS must not be empty")
+	}
+	for _, name := range cfg.Authenticators {
+		switch name {
+		case "database":
+		case "ldap":
+			if cfg.LDAPURL == "" || cfg.LDAPBaseDN == "" {
+				return fmt.Errorf("ldap authenticator requires LDAP_URL and LDAP_BASE_DN")
+			}
+			if strings.Count(cfg.LDAPUserFilter, "%s") != 1 {
+				return fmt.Errorf("LDAP_USER_FILTER must contain exactly one %%s")
+			}
+		default:
+			return fmt.Errorf("unknown authenticator %q in AUTHENTICATORS", name)
+		}
+	}
+	return nil
+}
+
+// validateSAML 校验 SAML 配置
+func validateSAML(cfg *Config) error {
+	if !cfg.SAMLEnabled {
+		return nil
+	}
+	if cfg.SAMLCertFile == "" || cfg.SAMLKeyFile == "" {
+		return fmt.Errorf("SAML requires SAML_SP_CERT_FILE and SAML_SP_KEY_FILE")
+	}
+	if (cfg.SAMLIDPMetadataURL == "") == (cfg.SAMLIDPMetadataFile == "") {
+		return fmt.Errorf("SAML requires exactly one of SAML_IDP_METADATA_URL and SAML_IDP_METADATA_FILE")
+	}
+	return nil
+}
+
+// validateOIDCProvider 校验 OpenID Connect 身份提供方配置
+// ID Token 由依赖方验证，使用 HS256 时依赖方需要持有签发全部访问令牌的 JWT_SECRET，因此只允许非对称算法；
+// 密钥环支持的非对称算法 (RS256、ES256/ES384/ES512、EdDSA) 均可使用，具体算法由签名密钥决定
+func validateOIDCProvider(cfg *Config) error {
+	if !cfg.OIDCProviderEnabled {
+		return nil
+	}
+	if cfg.JWTAlgorithm == "" || cfg.JWTAlgorithm == "HS256" {
+		return fmt.Errorf("OIDC_PROVIDER_ENABLED requires an asymmetric JWT_ALGORITHM (RS256, ES256, ES384, ES512 or EdDSA), got %s", cfg.JWTAlgorithm)
+	}
+	return nil
+}
+
+// validateAuthCookie 校验 Cookie 会话配置
+func validateAuthCookie(cfg *Config) error {
+	if !cfg.AuthCookieEnabled {
+		return nil
+	}
+	switch cfg.AuthCookieSameSite {
+	case "lax", "strict":
+	case "none":
+		if !cfg.AuthCookieSecure {
+			return fmt.Errorf("AUTH_COOKIE_SAMESITE=none requires AUTH_COOKIE_SECURE=true")
+		}
+	default:
+		return fmt.Errorf("invalid AUTH_COOKIE_SAMESITE %q: must be lax, strict or none", cfg.AuthCookieSameSite)
+	}
+	if cfg.AuthCookieName == "" {
+		return fmt.Errorf("AUTH_COOKIE_NAME must not be empty")
+	}
+	if err := validateSecret(cfg, "CSRF_SECRET", cfg.CSRFSecret); err != nil {
+		return err
+	}
+	if cfg.CSRFSecret == cfg.EmailVerificationSecret {
+		return fmt.Errorf("CSRF_SECRET must differ from EMAIL_VERIFICATION_SECRET")
+	}
+	return nil
+}
+
+// parseGroupRoles 解析组到角色的映射，格式为 "组DN=>角色;组DN=>角色"
+// 组 DN 本身包含逗号和等号，因此使用分号分隔条目、"=>" 分隔组和角色
+func parseGroupRoles(value string) (map[string]string, error) {
+	groupRoles := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(entry, "=>")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid LDAP_GROUP_ROLES entry %q, expected \"group DN=>role\"", entry)
+		}
+		groupRoles[strings.ToLower(group)] = role
+	}
+	return groupRoles, nil
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -66,3 +707,53 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDuration 获取时长类型的环境变量，格式如 "15m"、"24h"
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// getEnvList 获取逗号分隔的列表类型环境变量，忽略空白项
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvBool 获取布尔类型的环境变量，支持 "true"、"false"、"1"、"0" 等写法
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// getEnvInt 获取整数类型的环境变量
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
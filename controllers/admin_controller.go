@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// AdminController 管理员控制器
+type AdminController struct {
+	userService *services.UserService
+	roleService *services.RoleService
+}
+
+// NewAdminController 创建管理员控制器实例
+func NewAdminController(userService *services.UserService, roleService *services.RoleService) *AdminController {
+	return &AdminController{
+		userService: userService,
+		roleService: roleService,
+	}
+}
+
+// ListRoles
+// @Summary 获取角色列表
+// @Description 列出全部角色及其权限，需要 roles:read 权限
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.RoleOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/roles [get]
+func (c *AdminController) ListRoles(ctx *gin.Context) {
+	roles, err := c.roleService.ListRoles(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取角色列表失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	output := make([]dto.RoleOutput, 0, len(roles))
+	for i := range roles {
+		output = append(output, dto.NewRoleOutput(&roles[i]))
+	}
+	response.Success(ctx, output)
+}
+
+// GetUser
+// @Summary 获取指定用户信息
+// @Description 获取任意用户的信息及角色，需要 users:read 权限
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response{data=dto.UserOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/users/{id} [get]
+func (c *AdminController) GetUser(ctx *gin.Context) {
+	userID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	user, err := c.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取用户信息失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.NewUserOutput(user))
+}
+
+// AssignRoles
+// @Summary 分配用户角色
+// @Description 将用户的角色替换为给定集合，需要 roles:write 权限，新增的角色在用户刷新令牌后生效，移除角色时用户的全部令牌立即失效
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param body body dto.AssignRolesInput true "角色列表"
+// @Success 200 {object} response.Response{data=dto.UserOutput} "分配成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/users/{id}/roles [put]
+func (c *AdminController) AssignRoles(ctx *gin.Context) {
+	userID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	var input dto.AssignRolesInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	user, err := c.roleService.AssignRoles(ctx, userID, input.Roles)
+	if err != nil {
+		logger.CtxErrorf(ctx, "分配角色失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "分配角色成功, operator: %d, userID: %d, roles: %v", ctx.GetUint("userID"), userID, input.Roles)
+	response.Success(ctx, dto.NewUserOutput(user))
+}
+
+// UnlockUser
+// @Summary 解除登录锁定
+// @Description 清除用户因登录或第二因素验证失败次数过多而产生的等待和锁定状态
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} response.Response "解除成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/users/{id}/unlock [post]
+func (c *AdminController) UnlockUser(ctx *gin.Context) {
+	userID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.userService.UnlockLogin(ctx, userID); err != nil {
+		logger.CtxErrorf(ctx, "解除登录锁定失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "解除登录锁定成功, operator: %d, userID: %d", ctx.GetUint("userID"), userID)
+	response.Success(ctx, nil)
+}
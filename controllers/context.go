@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+)
+
+// currentPrincipal 从上下文中获取 Auth 中间件写入的调用方信息
+func currentPrincipal(ctx *gin.Context) (*services.Principal, error) {
+	value, exists := ctx.Get("principal")
+	if !exists {
+		return nil, errors.New("未认证: 无法从上下文中获取调用方信息")
+	}
+	return value.(*services.Principal), nil
+}
+
+// clientInfo 获取发起请求的客户端信息
+func clientInfo(ctx *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+}
+
+// parseIDParam 解析路径中的数字ID参数
+func parseIDParam(ctx *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("无效的ID")
+	}
+	return uint(id), nil
+}
+
+// parseOptionalIDQuery 解析可选的数字ID查询参数，未提供时返回 0
+func parseOptionalIDQuery(ctx *gin.Context, name string) (uint, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("无效的ID")
+	}
+	return uint(id), nil
+}
+
+// setRetryAfter 登录或第二因素验证被暂时拒绝时设置 Retry-After 响应头
+func setRetryAfter(ctx *gin.Context, err error) {
+	var retryAfter time.Duration
+	var loginLocked *services.LoginLockedError
+	var mfaLocked *services.MFALockedError
+	switch {
+	case errors.As(err, &loginLocked):
+		retryAfter = loginLocked.RetryAfter
+	case errors.As(err, &mfaLocked):
+		retryAfter = mfaLocked.RetryAfter
+	default:
+		return
+	}
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
+
+// respondPasswordError 返回设置密码失败的错误，密码不符合策略时在 data 中按请求字段给出每一项不满足的要求
+func respondPasswordError(ctx *gin.Context, err error, field string) {
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		response.ErrorWithData(ctx, err, dto.FieldErrorsOutput{Errors: map[string][]string{field: policyErr.Violations}})
+		return
+	}
+	response.Error(ctx, err)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// EmailVerificationController 邮箱验证控制器
+type EmailVerificationController struct {
+	emailVerificationService *services.EmailVerificationService
+}
+
+// NewEmailVerificationController 创建邮箱验证控制器实例
+func NewEmailVerificationController(emailVerificationService *services.EmailVerificationService) *EmailVerificationController {
+	return &EmailVerificationController{emailVerificationService: emailVerificationService}
+}
+
+// Verify
+// @Summary 验证邮箱
+// @Description 打开验证邮件中的链接完成邮箱验证
+// @Tags Email
+// @Produce json
+// @Param token query string true "验证令牌"
+// @Success 200 {object} response.Response "验证成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /email/verify [get]
+func (c *EmailVerificationController) Verify(ctx *gin.Context) {
+	if err := c.emailVerificationService.Verify(ctx, ctx.Query("token")); err != nil {
+		logger.CtxErrorf(ctx, "邮箱验证失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "邮箱验证成功")
+	response.Success(ctx, nil)
+}
+
+// Resend
+// @Summary 重新发送验证邮件
+// @Description 向未验证的邮箱重新发送验证链接，同一邮箱有发送间隔限制。无论邮箱是否已注册都返回相同的结果
+// @Tags Email
+// @Accept json
+// @Produce json
+// @Param body body dto.ResendEmailVerificationInput true "邮箱"
+// @Success 200 {object} response.Response "请求已受理"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /email/verify/resend [post]
+func (c *EmailVerificationController) Resend(ctx *gin.Context) {
+	var input dto.ResendEmailVerificationInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.emailVerificationService.Resend(ctx, input.Email); err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			response.Error(ctx, err)
+			return
+		}
+		// 其他内部错误只记录日志，响应保持一致，避免泄露邮箱是否已注册
+		logger.CtxErrorf(ctx, "重新发送验证邮件失败: %v", err)
+	}
+
+	response.Success(ctx, nil)
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+)
+
+// ExternalLoginController 外部身份提供方登录与账号关联控制器
+// 前端先获取授权地址并跳转，提供方回调前端页面后，前端将 code 和 state 提交到对应的回调接口；
+// 前端需要自行记住本次跳转是登录还是关联，以便提交到正确的接口
+type ExternalLoginController struct {
+	externalLoginService *services.ExternalLoginService
+	cookies              *utils.SessionCookies // 未启用 Cookie 会话时为 nil
+}
+
+// NewExternalLoginController 创建外部身份登录控制器实例
+func NewExternalLoginController(externalLoginService *services.ExternalLoginService, cookies *utils.SessionCookies) *ExternalLoginController {
+	return &ExternalLoginController{externalLoginService: externalLoginService, cookies: cookies}
+}
+
+// Providers
+// @Summary 获取外部登录方式
+// @Description 返回已配置的外部身份提供方名称，用于展示 "使用 xxx 登录" 按钮
+// @Tags ExternalLogin
+// @Produce json
+// @Success 200 {object} response.Response{data=[]string} "获取成功"
+// @Router /auth/providers [get]
+func (c *ExternalLoginController) Providers(ctx *gin.Context) {
+	providers := c.externalLoginService.Providers()
+	if providers == nil {
+		providers = []string{}
+	}
+	response.Success(ctx, providers)
+}
+
+// Login
+// @Summary 开始外部身份登录
+// @Description 返回外部身份提供方的授权地址，前端跳转后由提供方回调前端页面，state 10 分钟内有效且只能使用一次
+// @Tags ExternalLogin
+// @Produce json
+// @Param provider path string true "提供方名称"
+// @Success 200 {object} response.Response{data=dto.ExternalAuthorizationOutput} "获取成功"
+// @Failure 500 {object} response.Response "不支持的登录方式"
+// @Router /auth/{provider}/login [get]
+func (c *ExternalLoginController) Login(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+
+	authorizationURL, err := c.externalLoginService.AuthorizationURL(ctx, provider, 0)
+	if err != nil {
+		logger.CtxErrorf(ctx, "生成外部登录地址失败, provider: %s, error: %v", provider, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.ExternalAuthorizationOutput{AuthorizationURL: authorizationURL})
+}
+
+// Callback
+// @Summary 完成外部身份登录
+// @Description 提交提供方回调的 code 和 state。外部账号已关联时登录对应用户，否则使用外部账号的邮箱创建新用户；
+// @Description 邮箱已被其他账号使用时需要先用原账号登录后关联。启用了双因素认证时返回 mfa_token
+// @Tags ExternalLogin
+// @Accept json
+// @Produce json
+// @Param provider path string true "提供方名称"
+// @Param body body dto.ExternalCallbackInput true "授权码和状态值"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示写入 Cookie"
+// @Success 200 {object} response.Response{data=dto.LoginOutput} "登录成功"
+// @Failure 500 {object} response.Response "登录失败"
+// @Router /auth/{provider}/callback [post]
+func (c *ExternalLoginController) Callback(ctx *gin.Context) {
+	var input dto.ExternalCallbackInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	provider := ctx.Param("provider")
+	result, err := c.externalLoginService.Login(ctx, provider, input.Code, input.State, clientInfo(ctx))
+	if err != nil {
+		logger.CtxErrorf(ctx, "外部身份登录失败, provider: %s, error: %v", provider, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "外部身份登录成功, provider: %s, mfaRequired: %v", provider, result.Tokens == nil)
+	respondLoginResult(ctx, c.cookies, result)
+}
+
+// ListIdentities
+// @Summary 获取关联的外部账号
+// @Description 列出当前用户关联的外部身份
+// @Tags ExternalLogin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.IdentityOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/identities [get]
+func (c *ExternalLoginController) ListIdentities(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	identities, err := c.externalLoginService.ListIdentities(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取外部账号失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.IdentityOutput, 0, len(identities))
+	for i := range identities {
+		outputs = append(outputs, dto.NewIdentityOutput(&identities[i]))
+	}
+	response.Success(ctx, outputs)
+}
+
+// BeginLink
+// @Summary 开始关联外部账号
+// @Description 返回外部身份提供方的授权地址，完成后提交到 /user/identities/{provider}/callback；需要最近验证过身份
+// @Tags ExternalLogin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "提供方名称"
+// @Success 200 {object} response.Response{data=dto.ExternalAuthorizationOutput} "获取成功"
+// @Failure 500 {object} response.Response "不支持的登录方式"
+// @Router /user/identities/{provider} [post]
+func (c *ExternalLoginController) BeginLink(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	provider := ctx.Param("provider")
+
+	authorizationURL, err := c.externalLoginService.AuthorizationURL(ctx, provider, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "生成关联地址失败, userID: %d, provider: %s, error: %v", userID, provider, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.ExternalAuthorizationOutput{AuthorizationURL: authorizationURL})
+}
+
+// FinishLink
+// @Summary 完成关联外部账号
+// @Description 提交提供方回调的 code 和 state，state 必须由当前用户发起。外部账号已关联到其他用户时失败；需要最近验证过身份
+// @Tags ExternalLogin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "提供方名称"
+// @Param body body dto.ExternalCallbackInput true "授权码和状态值"
+// @Success 200 {object} response.Response{data=dto.IdentityOutput} "关联成功"
+// @Failure 500 {object} response.Response "关联失败"
+// @Router /user/identities/{provider}/callback [post]
+func (c *ExternalLoginController) FinishLink(ctx *gin.Context) {
+	var input dto.ExternalCallbackInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	provider := ctx.Param("provider")
+	identity, err := c.externalLoginService.Link(ctx, userID, provider, input.Code, input.State)
+	if err != nil {
+		logger.CtxErrorf(ctx, "关联外部账号失败, userID: %d, provider: %s, error: %v", userID, provider, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "关联外部账号成功, userID: %d, provider: %s, identityID: %d", userID, provider, identity.ID)
+	response.Success(ctx, dto.NewIdentityOutput(identity))
+}
+
+// Unlink
+// @Summary 解除关联外部账号
+// @Description 没有设置密码的用户不能解除最后一个外部账号
+// @Tags ExternalLogin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "外部身份ID"
+// @Success 200 {object} response.Response "解除成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/identities/{id} [delete]
+func (c *ExternalLoginController) Unlink(ctx *gin.Context) {
+	id, err := parseIDParam(ctx, "id")
+	if err != nil {
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.externalLoginService.Unlink(ctx, userID, id); err != nil {
+		logger.CtxErrorf(ctx, "解除关联外部账号失败, userID: %d, identityID: %d, error: %v", userID, id, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "解除关联外部账号成功, userID: %d, identityID: %d", userID, id)
+	response.Success(ctx, nil)
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// ImpersonationController 模拟用户登录控制器
+type ImpersonationController struct {
+	impersonationService *services.ImpersonationService
+}
+
+// NewImpersonationController 创建模拟登录控制器实例
+func NewImpersonationController(impersonationService *services.ImpersonationService) *ImpersonationController {
+	return &ImpersonationController{impersonationService: impersonationService}
+}
+
+// Start
+// @Summary 模拟用户登录
+// @Description 以指定用户的身份签发短期访问令牌，用于复现用户遇到的问题，需要 users:impersonate 权限。
+// @Description 令牌中的 act 声明记录实际操作的管理员，不签发刷新令牌，模拟期间不能修改密码、邮箱等敏感信息
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param body body dto.ImpersonateInput true "模拟原因"
+// @Success 200 {object} response.Response{data=dto.ImpersonationOutput} "模拟成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/users/{id}/impersonate [post]
+func (c *ImpersonationController) Start(ctx *gin.Context) {
+	userID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	var input dto.ImpersonateInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	result, err := c.impersonationService.Start(ctx, principal, userID, input.Reason, clientInfo(ctx))
+	if err != nil {
+		logger.CtxErrorf(ctx, "模拟登录失败, operator: %d, userID: %d, error: %v", principal.UserID, userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, newImpersonationOutput(result))
+}
+
+// Stop
+// @Summary 结束模拟登录
+// @Description 使用模拟令牌调用，作废该令牌并在审计记录中写入结束时间
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=dto.ImpersonationLogOutput} "结束成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/impersonation/stop [post]
+func (c *ImpersonationController) Stop(ctx *gin.Context) {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	record, err := c.impersonationService.Stop(ctx, principal.Claims)
+	if err != nil {
+		logger.CtxErrorf(ctx, "结束模拟登录失败, userID: %d, error: %v", principal.UserID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.NewImpersonationLogOutput(record))
+}
+
+// List
+// @Summary 获取模拟登录审计记录
+// @Description 按时间倒序列出最近的模拟登录记录，可按操作者或被模拟用户过滤，需要 users:read 权限
+// @Tags Admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param actor_id query int false "操作者用户ID"
+// @Param user_id query int false "被模拟的用户ID"
+// @Success 200 {object} response.Response{data=[]dto.ImpersonationLogOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/impersonations [get]
+func (c *ImpersonationController) List(ctx *gin.Context) {
+	actorID, err := parseOptionalIDQuery(ctx, "actor_id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+	targetUserID, err := parseOptionalIDQuery(ctx, "user_id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logs, err := c.impersonationService.List(ctx, actorID, targetUserID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取模拟登录记录失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.ImpersonationLogOutput, 0, len(logs))
+	for i := range logs {
+		outputs = append(outputs, dto.NewImpersonationLogOutput(&logs[i]))
+	}
+	response.Success(ctx, outputs)
+}
+
+// newImpersonationOutput 将 services.ImpersonationResult 转换为 ImpersonationOutput DTO
+func newImpersonationOutput(result *services.ImpersonationResult) dto.ImpersonationOutput {
+	return dto.ImpersonationOutput{
+		Token:           result.Token,
+		ExpiresIn:       int64(result.ExpiresIn.Seconds()),
+		ImpersonationID: result.Log.ID,
+		ExpiresAt:       result.Log.ExpiresAt,
+	}
+}
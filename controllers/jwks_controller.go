@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/services"
+)
+
+// JWKSController 公钥发布控制器
+type JWKSController struct {
+	tokenService *services.TokenService
+}
+
+// NewJWKSController 创建公钥发布控制器实例
+func NewJWKSController(tokenService *services.TokenService) *JWKSController {
+	return &JWKSController{tokenService: tokenService}
+}
+
+// JWKS
+// @Summary 获取令牌验证公钥
+// @Description 以 JWKS (RFC 7517) 格式返回当前接受的全部非对称验证公钥，供下游服务验证令牌签名
+// @Tags Keys
+// @Produce json
+// @Success 200 {object} utils.JWKSet "公钥集合"
+// @Router /.well-known/jwks.json [get]
+func (c *JWKSController) JWKS(ctx *gin.Context) {
+	// JWKS 是标准格式，不使用统一响应结构包装
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, c.tokenService.JWKS())
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// MFAController 双因素认证控制器
+type MFAController struct {
+	mfaService *services.MFAService
+}
+
+// NewMFAController 创建双因素认证控制器实例
+func NewMFAController(mfaService *services.MFAService) *MFAController {
+	return &MFAController{mfaService: mfaService}
+}
+
+// EnrollTOTP
+// @Summary 开始绑定 TOTP
+// @Description 生成 TOTP 密钥和 otpauth:// URI，需要调用确认接口后才会启用
+// @Tags MFA
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=dto.TOTPEnrollmentOutput} "生成成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/mfa/totp/enroll [post]
+func (c *MFAController) EnrollTOTP(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	secret, uri, err := c.mfaService.BeginTOTPEnrollment(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "开始绑定 TOTP 失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.TOTPEnrollmentOutput{Secret: secret, OtpauthURI: uri})
+}
+
+// ConfirmTOTP
+// @Summary 确认绑定 TOTP
+// @Description 提交验证器应用生成的验证码以启用 TOTP，成功后返回一组一次性恢复码
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.TOTPCodeInput true "验证码"
+// @Success 200 {object} response.Response{data=dto.RecoveryCodesOutput} "启用成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/mfa/totp/confirm [post]
+func (c *MFAController) ConfirmTOTP(ctx *gin.Context) {
+	var input dto.TOTPCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	codes, err := c.mfaService.ConfirmTOTPEnrollment(ctx, userID, input.Code)
+	if err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "确认绑定 TOTP 失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "启用 TOTP 成功, userID: %d", userID)
+	response.Success(ctx, dto.RecoveryCodesOutput{RecoveryCodes: codes})
+}
+
+// DisableTOTP
+// @Summary 停用 TOTP
+// @Description 提交验证码或恢复码以停用双因素认证，同时作废全部恢复码；需要最近验证过身份
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.SecondFactorInput true "验证码或恢复码"
+// @Success 200 {object} response.Response "停用成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/mfa/totp/disable [post]
+func (c *MFAController) DisableTOTP(ctx *gin.Context) {
+	var input dto.SecondFactorInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.mfaService.DisableTOTP(ctx, userID, input.Code, input.RecoveryCode); err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "停用 TOTP 失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "停用 TOTP 成功, userID: %d", userID)
+	response.Success(ctx, nil)
+}
+
+// RegenerateRecoveryCodes
+// @Summary 重新生成恢复码
+// @Description 提交验证码以重新生成恢复码，旧恢复码全部作废；需要最近验证过身份
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.TOTPCodeInput true "验证码"
+// @Success 200 {object} response.Response{data=dto.RecoveryCodesOutput} "生成成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/mfa/recovery-codes [post]
+func (c *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var input dto.TOTPCodeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	codes, err := c.mfaService.RegenerateRecoveryCodes(ctx, userID, input.Code)
+	if err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "重新生成恢复码失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "重新生成恢复码成功, userID: %d", userID)
+	response.Success(ctx, dto.RecoveryCodesOutput{RecoveryCodes: codes})
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// OAuthClientController OAuth 客户端管理控制器
+type OAuthClientController struct {
+	oauthClientService *services.OAuthClientService
+}
+
+// NewOAuthClientController 创建 OAuth 客户端管理控制器实例
+func NewOAuthClientController(oauthClientService *services.OAuthClientService) *OAuthClientController {
+	return &OAuthClientController{oauthClientService: oauthClientService}
+}
+
+// Create
+// @Summary 注册 OAuth 客户端
+// @Description 为第三方应用注册客户端，机密客户端的 client_secret 只在此时返回一次，需要 oauth_clients:write 权限
+// @Tags OAuthClients
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateOAuthClientInput true "客户端信息"
+// @Success 200 {object} response.Response{data=dto.CreatedOAuthClientOutput} "注册成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/oauth/clients [post]
+func (c *OAuthClientController) Create(ctx *gin.Context) {
+	var input dto.CreateOAuthClientInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	client, secret, err := c.oauthClientService.Create(ctx, input.Name, input.RedirectURIs, input.Scopes, input.GrantTypes, input.Confidential)
+	if err != nil {
+		logger.CtxErrorf(ctx, "注册 OAuth 客户端失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "注册 OAuth 客户端成功, operator: %d, clientID: %s", ctx.GetUint("userID"), client.ClientID)
+	response.Success(ctx, dto.CreatedOAuthClientOutput{
+		OAuthClientOutput: dto.NewOAuthClientOutput(client),
+		ClientSecret:      secret,
+	})
+}
+
+// List
+// @Summary 获取 OAuth 客户端列表
+// @Description 列出全部已注册的客户端，需要 oauth_clients:write 权限
+// @Tags OAuthClients
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.OAuthClientOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/oauth/clients [get]
+func (c *OAuthClientController) List(ctx *gin.Context) {
+	clients, err := c.oauthClientService.List(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取 OAuth 客户端列表失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.OAuthClientOutput, 0, len(clients))
+	for i := range clients {
+		outputs = append(outputs, dto.NewOAuthClientOutput(&clients[i]))
+	}
+	response.Success(ctx, outputs)
+}
+
+// Delete
+// @Summary 删除 OAuth 客户端
+// @Description 删除客户端及用户对其的全部授权，已签发的刷新令牌随之失效，需要 oauth_clients:write 权限
+// @Tags OAuthClients
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "客户端记录ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/oauth/clients/{id} [delete]
+func (c *OAuthClientController) Delete(ctx *gin.Context) {
+	id, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.oauthClientService.Delete(ctx, id); err != nil {
+		logger.CtxErrorf(ctx, "删除 OAuth 客户端失败, id: %d, error: %v", id, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "删除 OAuth 客户端成功, operator: %d, id: %d", ctx.GetUint("userID"), id)
+	response.Success(ctx, nil)
+}
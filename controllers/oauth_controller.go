@@ -0,0 +1,401 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+)
+
+// OAuthController OAuth 2.0 授权服务器控制器
+// 授权确认和授权管理接口供前端页面调用，使用统一响应结构；令牌、撤销和自省端点面向第三方客户端，按 RFC 规定的格式响应
+type OAuthController struct {
+	oauthService *services.OAuthService
+	cookies      *utils.SessionCookies // 未启用 Cookie 会话时为 nil
+	consentURL   string                // 前端授权确认页面地址
+}
+
+// NewOAuthController 创建 OAuth 控制器实例
+func NewOAuthController(oauthService *services.OAuthService, cookies *utils.SessionCookies, consentURL string) *OAuthController {
+	return &OAuthController{oauthService: oauthService, cookies: cookies, consentURL: consentURL}
+}
+
+// RedirectToConsent 浏览器直接访问授权端点时跳转到前端授权确认页，查询参数原样传递
+// 前端授权页携带 Authorization 头或会话 Cookie 的请求由后续的 Authorize 处理；
+// 浏览器导航请求即使带着会话 Cookie 也要跳转，否则已登录的用户从第三方应用跳转过来时会直接看到 JSON 响应
+func (c *OAuthController) RedirectToConsent(ctx *gin.Context) {
+	hasSessionCookie := c.cookies != nil && c.cookies.AccessToken(ctx.Request) != ""
+	if ctx.GetHeader("Authorization") != "" || (hasSessionCookie && !isBrowserNavigation(ctx.Request)) {
+		ctx.Next()
+		return
+	}
+
+	target := c.consentURL
+	if query := ctx.Request.URL.RawQuery; query != "" {
+		target = appendRawQuery(target, query)
+	}
+	ctx.Redirect(http.StatusFound, target)
+	ctx.Abort()
+}
+
+// Authorize
+// @Summary 校验授权请求
+// @Description 前端授权页携带第三方应用的授权请求参数调用。用户已授权过全部作用域时直接返回携带授权码的 redirect_to；
+// @Description 否则返回 consent_required，由前端展示应用名称和作用域供用户确认。必须使用 PKCE (S256)
+// @Tags OAuth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param response_type query string true "固定为 code"
+// @Param client_id query string true "客户端ID"
+// @Param redirect_uri query string false "回调地址，只注册了一个时可省略"
+// @Param scope query string false "空格分隔的作用域，留空为客户端允许的全部作用域"
+// @Param state query string false "客户端状态值，原样返回"
+// @Param nonce query string false "OpenID Connect nonce，原样写入 ID Token"
+// @Param code_challenge query string true "PKCE code_challenge"
+// @Param code_challenge_method query string true "固定为 S256"
+// @Success 200 {object} response.Response{data=dto.AuthorizationPromptOutput} "校验成功"
+// @Failure 500 {object} response.Response "客户端或回调地址无效"
+// @Router /oauth/authorize [get]
+func (c *OAuthController) Authorize(ctx *gin.Context) {
+	var input dto.AuthorizeInput
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		response.Error(ctx, err)
+		return
+	}
+
+	prompt, err := c.oauthService.PrepareAuthorization(ctx, principal, authorizeRequest(input))
+	if err != nil {
+		logger.CtxErrorf(ctx, "校验授权请求失败, userID: %d, clientID: %s, error: %v", principal.UserID, input.ClientID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, newAuthorizationPromptOutput(prompt))
+}
+
+// Decide
+// @Summary 确认或拒绝授权
+// @Description 用户在授权页做出决定后调用，返回前端应跳转的回调地址 (携带授权码或 access_denied 错误)
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.AuthorizeDecisionInput true "授权请求参数和用户决定"
+// @Success 200 {object} response.Response{data=dto.AuthorizeDecisionOutput} "处理成功"
+// @Failure 500 {object} response.Response "客户端或回调地址无效"
+// @Router /oauth/authorize [post]
+func (c *OAuthController) Decide(ctx *gin.Context) {
+	var input dto.AuthorizeDecisionInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		response.Error(ctx, err)
+		return
+	}
+
+	redirectTo, err := c.oauthService.DecideAuthorization(ctx, principal, authorizeRequest(input.AuthorizeInput), input.Approve)
+	if err != nil {
+		logger.CtxErrorf(ctx, "处理授权决定失败, userID: %d, clientID: %s, error: %v", principal.UserID, input.ClientID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "用户处理授权请求, userID: %d, clientID: %s, approve: %v", principal.UserID, input.ClientID, input.Approve)
+	response.Success(ctx, dto.AuthorizeDecisionOutput{RedirectTo: redirectTo})
+}
+
+// Token
+// @Summary 令牌端点
+// @Description 支持 authorization_code (必须携带 code_verifier)、refresh_token 和 client_credentials 三种授权类型。
+// @Description 客户端凭证可以通过 HTTP Basic 认证或表单中的 client_id/client_secret 提供，公开客户端只需提供 client_id
+// @Description 授予了 openid 作用域时响应中同时包含 id_token
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "授权类型"
+// @Param code formData string false "授权码"
+// @Param redirect_uri formData string false "授权请求中使用的回调地址"
+// @Param code_verifier formData string false "PKCE code_verifier"
+// @Param refresh_token formData string false "刷新令牌"
+// @Param scope formData string false "空格分隔的作用域"
+// @Param client_id formData string false "客户端ID"
+// @Param client_secret formData string false "客户端密钥"
+// @Success 200 {object} dto.OAuthTokenOutput "签发成功"
+// @Failure 400 {object} dto.OAuthErrorOutput "请求无效"
+// @Failure 401 {object} dto.OAuthErrorOutput "客户端认证失败"
+// @Router /oauth/token [post]
+func (c *OAuthController) Token(ctx *gin.Context) {
+	clientID, clientSecret, err := clientCredentials(ctx)
+	if err != nil {
+		oauthError(ctx, err)
+		return
+	}
+
+	result, err := c.oauthService.Token(ctx, services.TokenRequest{
+		GrantType:    ctx.PostForm("grant_type"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Code:         ctx.PostForm("code"),
+		RedirectURI:  ctx.PostForm("redirect_uri"),
+		CodeVerifier: ctx.PostForm("code_verifier"),
+		RefreshToken: ctx.PostForm("refresh_token"),
+		Scope:        ctx.PostForm("scope"),
+	})
+	if err != nil {
+		logger.CtxWarnf(ctx, "OAuth 签发令牌失败, clientID: %s, grantType: %s, error: %v", clientID, ctx.PostForm("grant_type"), err)
+		oauthError(ctx, err)
+		return
+	}
+
+	noStore(ctx)
+	ctx.JSON(http.StatusOK, newOAuthTokenOutput(result))
+}
+
+// Revoke
+// @Summary 撤销令牌
+// @Description 撤销签发给该客户端的访问令牌或刷新令牌 (RFC 7009)，令牌无效时同样返回 200
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "要撤销的令牌"
+// @Param token_type_hint formData string false "access_token 或 refresh_token"
+// @Param client_id formData string false "客户端ID"
+// @Param client_secret formData string false "客户端密钥"
+// @Success 200 "撤销成功"
+// @Failure 400 {object} dto.OAuthErrorOutput "请求无效"
+// @Failure 401 {object} dto.OAuthErrorOutput "客户端认证失败"
+// @Router /oauth/revoke [post]
+func (c *OAuthController) Revoke(ctx *gin.Context) {
+	clientID, clientSecret, err := clientCredentials(ctx)
+	if err != nil {
+		oauthError(ctx, err)
+		return
+	}
+
+	if err := c.oauthService.Revoke(ctx, clientID, clientSecret, ctx.PostForm("token")); err != nil {
+		logger.CtxWarnf(ctx, "OAuth 撤销令牌失败, clientID: %s, error: %v", clientID, err)
+		oauthError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Introspect
+// @Summary 令牌自省
+// @Description 返回 OAuth 令牌的状态和元数据 (RFC 7662)，需要客户端认证；登录会话、个人访问令牌和签发给其他客户端的令牌始终返回 active=false
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "要查询的令牌"
+// @Param token_type_hint formData string false "access_token 或 refresh_token"
+// @Param client_id formData string false "客户端ID"
+// @Param client_secret formData string false "客户端密钥"
+// @Success 200 {object} dto.IntrospectionOutput "查询成功"
+// @Failure 400 {object} dto.OAuthErrorOutput "请求无效"
+// @Failure 401 {object} dto.OAuthErrorOutput "客户端认证失败"
+// @Router /oauth/introspect [post]
+func (c *OAuthController) Introspect(ctx *gin.Context) {
+	clientID, clientSecret, err := clientCredentials(ctx)
+	if err != nil {
+		oauthError(ctx, err)
+		return
+	}
+
+	result, err := c.oauthService.Introspect(ctx, clientID, clientSecret, ctx.PostForm("token"))
+	if err != nil {
+		logger.CtxWarnf(ctx, "OAuth 令牌自省失败, clientID: %s, error: %v", clientID, err)
+		oauthError(ctx, err)
+		return
+	}
+
+	noStore(ctx)
+	ctx.JSON(http.StatusOK, newIntrospectionOutput(result))
+}
+
+// ListConsents
+// @Summary 获取已授权的应用
+// @Description 列出当前用户授权过的第三方应用及作用域
+// @Tags OAuth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.OAuthConsentOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/oauth/consents [get]
+func (c *OAuthController) ListConsents(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	consents, err := c.oauthService.ListConsents(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取授权记录失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.OAuthConsentOutput, 0, len(consents))
+	for i := range consents {
+		outputs = append(outputs, dto.NewOAuthConsentOutput(&consents[i]))
+	}
+	response.Success(ctx, outputs)
+}
+
+// RevokeConsent
+// @Summary 撤销应用授权
+// @Description 撤销对第三方应用的授权，该应用持有的刷新令牌立即失效，下次访问需要重新授权
+// @Tags OAuth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param client_id path string true "客户端ID"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/oauth/consents/{client_id} [delete]
+func (c *OAuthController) RevokeConsent(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	clientID := ctx.Param("client_id")
+	if err := c.oauthService.RevokeConsent(ctx, userID, clientID); err != nil {
+		logger.CtxErrorf(ctx, "撤销应用授权失败, userID: %d, clientID: %s, error: %v", userID, clientID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "撤销应用授权成功, userID: %d, clientID: %s", userID, clientID)
+	response.Success(ctx, nil)
+}
+
+// authorizeRequest 将授权请求参数转换为服务层的请求
+func authorizeRequest(input dto.AuthorizeInput) services.AuthorizeRequest {
+	return services.AuthorizeRequest{
+		ResponseType:        input.ResponseType,
+		ClientID:            input.ClientID,
+		RedirectURI:         input.RedirectURI,
+		Scope:               input.Scope,
+		State:               input.State,
+		Nonce:               input.Nonce,
+		CodeChallenge:       input.CodeChallenge,
+		CodeChallengeMethod: input.CodeChallengeMethod,
+	}
+}
+
+// clientCredentials 读取客户端凭证，支持 HTTP Basic 认证 (RFC 6749 第 2.3.1 节) 和表单参数，二者不能同时使用
+func clientCredentials(ctx *gin.Context) (string, string, error) {
+	formID, formSecret := ctx.PostForm("client_id"), ctx.PostForm("client_secret")
+
+	basicID, basicSecret, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return formID, formSecret, nil
+	}
+	if formSecret != "" {
+		return "", "", &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "不能同时使用多种客户端认证方式"}
+	}
+
+	// Basic 认证中的 client_id 和 client_secret 需要先进行表单编码
+	clientID, err := url.QueryUnescape(basicID)
+	if err != nil {
+		return "", "", &services.OAuthError{Code: services.OAuthErrInvalidClient, Description: "客户端凭证格式无效"}
+	}
+	clientSecret, err := url.QueryUnescape(basicSecret)
+	if err != nil {
+		return "", "", &services.OAuthError{Code: services.OAuthErrInvalidClient, Description: "客户端凭证格式无效"}
+	}
+	return clientID, clientSecret, nil
+}
+
+// oauthError 按 RFC 6749 第 5.2 节返回错误：客户端认证失败为 401，其余请求错误为 400，内部错误为 500
+func oauthError(ctx *gin.Context, err error) {
+	noStore(ctx)
+
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		ctx.JSON(http.StatusInternalServerError, dto.OAuthErrorOutput{Error: "server_error", ErrorDescription: err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthErrInvalidClient {
+		status = http.StatusUnauthorized
+		ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	ctx.JSON(status, dto.OAuthErrorOutput{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
+
+// appendRawQuery 将原始查询字符串追加到地址上
+func appendRawQuery(target, query string) string {
+	if strings.Contains(target, "?") {
+		return target + "&" + query
+	}
+	return target + "?" + query
+}
+
+// isBrowserNavigation 判断请求是否为浏览器的页面导航，不支持 Sec-Fetch-Mode 的浏览器按 Accept 头判断
+func isBrowserNavigation(r *http.Request) bool {
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// noStore 禁止缓存包含令牌的响应
+func noStore(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+}
+
+// newAuthorizationPromptOutput 将 services.AuthorizationPrompt 转换为 AuthorizationPromptOutput DTO
+func newAuthorizationPromptOutput(prompt *services.AuthorizationPrompt) dto.AuthorizationPromptOutput {
+	output := dto.AuthorizationPromptOutput{
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+		RedirectTo:      prompt.RedirectTo,
+	}
+	if prompt.Client != nil {
+		output.ClientID = prompt.Client.ClientID
+		output.ClientName = prompt.Client.Name
+	}
+	return output
+}
+
+// newOAuthTokenOutput 将 services.OAuthTokenResult 转换为 OAuthTokenOutput DTO
+func newOAuthTokenOutput(result *services.OAuthTokenResult) dto.OAuthTokenOutput {
+	return dto.OAuthTokenOutput{
+		AccessToken:  result.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(result.ExpiresIn.Seconds()),
+		RefreshToken: result.RefreshToken,
+		IDToken:      result.IDToken,
+		Scope:        strings.Join(result.Scopes, " "),
+	}
+}
+
+// newIntrospectionOutput 将 services.IntrospectionResult 转换为 IntrospectionOutput DTO
+func newIntrospectionOutput(result *services.IntrospectionResult) dto.IntrospectionOutput {
+	return dto.IntrospectionOutput{
+		Active:    result.Active,
+		Scope:     result.Scope,
+		ClientID:  result.ClientID,
+		Username:  result.Username,
+		TokenType: result.TokenType,
+		Exp:       result.ExpiresAt,
+		Iat:       result.IssuedAt,
+		Sub:       result.Subject,
+		Aud:       result.Audience,
+		Iss:       result.Issuer,
+		Jti:       result.TokenID,
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/utils"
+)
+
+func TestOAuthControllerRedirectToConsent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cookies := utils.NewSessionCookies(utils.SessionCookieOptions{Name: "plusone_session", CSRFSecret: []byte("csrf-secret")})
+
+	tests := []struct {
+		name         string
+		cookies      *utils.SessionCookies
+		header       map[string]string
+		cookie       string
+		wantRedirect bool
+	}{
+		{
+			name:         "浏览器直接访问跳转到授权确认页",
+			cookies:      cookies,
+			header:       map[string]string{"Sec-Fetch-Mode": "navigate", "Accept": "text/html"},
+			wantRedirect: true,
+		},
+		{
+			name:   "携带 Authorization 头的请求交给授权处理",
+			header: map[string]string{"Authorization": "Bearer token"},
+		},
+		{
+			name:    "前端授权页携带会话 Cookie 的请求交给授权处理",
+			cookies: cookies,
+			header:  map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "application/json"},
+			cookie:  "access-token",
+		},
+		{
+			name:    "不支持 Sec-Fetch-Mode 的浏览器按 Accept 头判断",
+			cookies: cookies,
+			header:  map[string]string{"Accept": "application/json"},
+			cookie:  "access-token",
+		},
+		{
+			name:         "已登录的浏览器从第三方应用跳转过来时仍显示授权确认页",
+			cookies:      cookies,
+			header:       map[string]string{"Sec-Fetch-Mode": "navigate", "Accept": "text/html"},
+			cookie:       "access-token",
+			wantRedirect: true,
+		},
+		{
+			name:         "未启用 Cookie 会话时忽略 Cookie",
+			header:       map[string]string{"Accept": "application/json"},
+			cookie:       "access-token",
+			wantRedirect: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewOAuthController(nil, tt.cookies, "https://app.example.com/consent")
+			router := gin.New()
+			router.GET("/oauth/authorize", controller.RedirectToConsent, func(ctx *gin.Context) {
+				ctx.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=app&state=xyz", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "plusone_session", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if tt.wantRedirect {
+				if w.Code != http.StatusFound || w.Header().Get("Location") != "https://app.example.com/consent?client_id=app&state=xyz" {
+					t.Fatalf("期望跳转到授权确认页，实际为 %d %s", w.Code, w.Header().Get("Location"))
+				}
+				return
+			}
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("期望交给授权处理，实际为 %d %s", w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/models"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// OIDCController OpenID Connect 身份提供方控制器，发现文档和用户信息均按规范格式响应，不使用统一响应结构
+type OIDCController struct {
+	oauthService *services.OAuthService
+	tokenService *services.TokenService
+	issuer       string
+}
+
+// NewOIDCController 创建 OpenID Connect 控制器实例
+func NewOIDCController(oauthService *services.OAuthService, tokenService *services.TokenService, issuer string) *OIDCController {
+	return &OIDCController{
+		oauthService: oauthService,
+		tokenService: tokenService,
+		issuer:       issuer,
+	}
+}
+
+// Discovery
+// @Summary OpenID Connect 发现文档
+// @Description 返回签发者、各端点地址以及支持的作用域、授权类型和签名算法，供客户端自动配置
+// @Tags OIDC
+// @Produce json
+// @Success 200 {object} dto.DiscoveryOutput "发现文档"
+// @Router /.well-known/openid-configuration [get]
+func (c *OIDCController) Discovery(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, dto.DiscoveryOutput{
+		Issuer:                            c.issuer,
+		AuthorizationEndpoint:             c.issuer + "/oauth/authorize",
+		TokenEndpoint:                     c.issuer + "/oauth/token",
+		UserInfoEndpoint:                  c.issuer + "/userinfo",
+		JWKSURI:                           c.issuer + "/.well-known/jwks.json",
+		RevocationEndpoint:                c.issuer + "/oauth/revoke",
+		IntrospectionEndpoint:             c.issuer + "/oauth/introspect",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{c.tokenService.SigningAlgorithm()},
+		ScopesSupported:                   services.OAuthScopes(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "name", "email", "email_verified"},
+	})
+}
+
+// UserInfo
+// @Summary 获取用户信息
+// @Description 使用带 openid 作用域的访问令牌调用，返回 sub 以及 profile、email 作用域对应的标准声明
+// @Tags OIDC
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} dto.UserInfoOutput "用户信息"
+// @Failure 401 {object} dto.OAuthErrorOutput "令牌不代表任何用户"
+// @Failure 500 {object} response.Response "未认证或缺少 openid 作用域"
+// @Router /userinfo [get]
+func (c *OIDCController) UserInfo(ctx *gin.Context) {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, dto.OAuthErrorOutput{Error: "invalid_token", ErrorDescription: err.Error()})
+		return
+	}
+
+	subject, claims, err := c.oauthService.UserInfo(ctx, principal)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取用户信息失败, userID: %d, error: %v", principal.UserID, err)
+		ctx.JSON(http.StatusUnauthorized, dto.OAuthErrorOutput{Error: "invalid_token", ErrorDescription: err.Error()})
+		return
+	}
+
+	noStore(ctx)
+	ctx.JSON(http.StatusOK, dto.UserInfoOutput{Sub: subject, UserInfoClaims: claims})
+}
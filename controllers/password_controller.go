@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// PasswordController 找回密码控制器
+type PasswordController struct {
+	passwordResetService *services.PasswordResetService
+}
+
+// NewPasswordController 创建找回密码控制器实例
+func NewPasswordController(passwordResetService *services.PasswordResetService) *PasswordController {
+	return &PasswordController{passwordResetService: passwordResetService}
+}
+
+// Forgot
+// @Summary 找回密码
+// @Description 向邮箱发送重置密码链接，同一邮箱有请求间隔限制。无论邮箱是否已注册都返回相同的结果
+// @Tags Password
+// @Accept json
+// @Produce json
+// @Param body body dto.ForgotPasswordInput true "邮箱"
+// @Success 200 {object} response.Response "请求已受理"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /password/forgot [post]
+func (c *PasswordController) Forgot(ctx *gin.Context) {
+	var input dto.ForgotPasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.passwordResetService.RequestReset(ctx, input.Email); err != nil {
+		if errors.Is(err, services.ErrTooManyRequests) {
+			response.Error(ctx, err)
+			return
+		}
+		// 其他内部错误只记录日志，响应保持一致，避免泄露邮箱是否已注册
+		logger.CtxErrorf(ctx, "处理找回密码请求失败: %v", err)
+	}
+
+	response.Success(ctx, nil)
+}
+
+// Reset
+// @Summary 重置密码
+// @Description 使用邮件中的一次性令牌设置新密码，成功后所有设备上的登录状态都会失效。
+// @Description 新密码不符合密码策略时令牌不会失效，data.errors.password 列出每一项不满足的要求
+// @Tags Password
+// @Accept json
+// @Produce json
+// @Param body body dto.ResetPasswordInput true "重置令牌和新密码"
+// @Success 200 {object} response.Response "重置成功"
+// @Failure 500 {object} response.Response{data=dto.FieldErrorsOutput} "重置失败"
+// @Router /password/reset [post]
+func (c *PasswordController) Reset(ctx *gin.Context) {
+	var input dto.ResetPasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.passwordResetService.ResetPassword(ctx, input.Token, input.Password); err != nil {
+		logger.CtxErrorf(ctx, "重置密码失败: %v", err)
+		respondPasswordError(ctx, err, "password")
+		return
+	}
+
+	logger.CtxInfof(ctx, "重置密码成功")
+	response.Success(ctx, nil)
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// PersonalAccessTokenController 个人访问令牌控制器
+type PersonalAccessTokenController struct {
+	patService *services.PersonalAccessTokenService
+}
+
+// NewPersonalAccessTokenController 创建个人访问令牌控制器实例
+func NewPersonalAccessTokenController(patService *services.PersonalAccessTokenService) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{patService: patService}
+}
+
+// Create
+// @Summary 创建个人访问令牌
+// @Description 创建一个带作用域和可选有效期的个人访问令牌，令牌明文只在本次响应中返回
+// @Tags PersonalAccessTokens
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreatePersonalAccessTokenInput true "令牌信息"
+// @Success 200 {object} response.Response{data=dto.CreatedPersonalAccessTokenOutput} "创建成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/tokens [post]
+func (c *PersonalAccessTokenController) Create(ctx *gin.Context) {
+	var input dto.CreatePersonalAccessTokenInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	expiresIn := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	plaintext, token, err := c.patService.Create(ctx, principal, input.Name, input.Scopes, expiresIn)
+	if err != nil {
+		logger.CtxErrorf(ctx, "创建个人访问令牌失败, userID: %d, error: %v", principal.UserID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "创建个人访问令牌成功, userID: %d, tokenID: %d", principal.UserID, token.ID)
+	response.Success(ctx, dto.CreatedPersonalAccessTokenOutput{
+		PersonalAccessTokenOutput: dto.NewPersonalAccessTokenOutput(token),
+		Token:                     plaintext,
+	})
+}
+
+// List
+// @Summary 获取个人访问令牌列表
+// @Description 列出当前用户未撤销的个人访问令牌，不包含令牌明文
+// @Tags PersonalAccessTokens
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.PersonalAccessTokenOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/tokens [get]
+func (c *PersonalAccessTokenController) List(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	tokens, err := c.patService.List(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取个人访问令牌失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	output := make([]dto.PersonalAccessTokenOutput, 0, len(tokens))
+	for i := range tokens {
+		output = append(output, dto.NewPersonalAccessTokenOutput(&tokens[i]))
+	}
+	response.Success(ctx, output)
+}
+
+// Revoke
+// @Summary 撤销个人访问令牌
+// @Description 撤销当前用户的一个个人访问令牌，撤销后立即失效
+// @Tags PersonalAccessTokens
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "令牌ID"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/tokens/{id} [delete]
+func (c *PersonalAccessTokenController) Revoke(ctx *gin.Context) {
+	tokenID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.patService.Revoke(ctx, userID, tokenID); err != nil {
+		logger.CtxErrorf(ctx, "撤销个人访问令牌失败, userID: %d, tokenID: %d, error: %v", userID, tokenID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "撤销个人访问令牌成功, userID: %d, tokenID: %d", userID, tokenID)
+	response.Success(ctx, nil)
+}
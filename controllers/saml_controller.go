@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+)
+
+// SAMLController SAML 2.0 单点登录控制器
+// 浏览器访问 /saml/login 跳转到 IdP，IdP 将断言提交到 /saml/acs，验证通过后跳转到前端回调页面并携带一次性的 code，
+// 前端再调用 /api/saml/exchange 换取令牌；失败时回调页面携带 error 参数
+type SAMLController struct {
+	samlService *services.SAMLService
+	cookies     *utils.SessionCookies // 未启用 Cookie 会话时为 nil
+}
+
+// NewSAMLController 创建 SAML 登录控制器实例
+func NewSAMLController(samlService *services.SAMLService, cookies *utils.SessionCookies) *SAMLController {
+	return &SAMLController{samlService: samlService, cookies: cookies}
+}
+
+// Metadata
+// @Summary 获取 SAML SP 元数据
+// @Description 返回本服务作为 SAML 服务提供方的元数据，提供给 IdP 管理员配置
+// @Tags SAML
+// @Produce xml
+// @Success 200 {string} string "SP 元数据"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /saml/metadata [get]
+func (c *SAMLController) Metadata(ctx *gin.Context) {
+	metadata, err := c.samlService.Metadata()
+	if err != nil {
+		logger.CtxErrorf(ctx, "生成 SAML 元数据失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// Login
+// @Summary 开始 SAML 登录
+// @Description 生成签名的认证请求并跳转到 IdP，请求 10 分钟内有效
+// @Tags SAML
+// @Success 302 "跳转到 IdP"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /saml/login [get]
+func (c *SAMLController) Login(ctx *gin.Context) {
+	loginURL, err := c.samlService.LoginURL(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "生成 SAML 认证请求失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+	ctx.Redirect(http.StatusFound, loginURL)
+}
+
+// ACS
+// @Summary SAML 断言消费端点
+// @Description IdP 以 HTTP-POST 绑定提交断言。验证通过后按 NameID 查找关联的用户，未关联时使用断言中的邮箱创建新用户，
+// @Description 然后跳转到前端回调页面并携带 1 分钟内有效的一次性 code；失败时携带 error
+// @Tags SAML
+// @Accept x-www-form-urlencoded
+// @Param SAMLResponse formData string true "Base64 编码的 SAML 响应"
+// @Param RelayState formData string false "SP 发起登录时的状态值"
+// @Success 302 "跳转到前端回调页面"
+// @Router /saml/acs [post]
+func (c *SAMLController) ACS(ctx *gin.Context) {
+	callbackURL, err := c.samlService.ACS(ctx, ctx.PostForm("SAMLResponse"), ctx.PostForm("RelayState"))
+	if err != nil {
+		logger.CtxErrorf(ctx, "SAML 登录失败: %v", err)
+		ctx.Redirect(http.StatusFound, c.samlService.CallbackURL(url.Values{"error": {err.Error()}}))
+		return
+	}
+
+	logger.CtxInfof(ctx, "SAML 断言验证成功")
+	ctx.Redirect(http.StatusFound, callbackURL)
+}
+
+// Exchange
+// @Summary 完成 SAML 登录
+// @Description 使用 ACS 跳转时携带的一次性 code 换取令牌，启用了双因素认证时返回 mfa_token
+// @Tags SAML
+// @Accept json
+// @Produce json
+// @Param body body dto.SAMLExchangeInput true "一次性登录码"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示写入 Cookie"
+// @Success 200 {object} response.Response{data=dto.LoginOutput} "登录成功"
+// @Failure 500 {object} response.Response "登录失败"
+// @Router /saml/exchange [post]
+func (c *SAMLController) Exchange(ctx *gin.Context) {
+	var input dto.SAMLExchangeInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	result, err := c.samlService.Exchange(ctx, input.Code, clientInfo(ctx))
+	if err != nil {
+		logger.CtxErrorf(ctx, "SAML 登录失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "SAML 登录成功, mfaRequired: %v", result.Tokens == nil)
+	respondLoginResult(ctx, c.cookies, result)
+}
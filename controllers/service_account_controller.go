@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// ServiceAccountController 服务账号管理控制器
+type ServiceAccountController struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+// NewServiceAccountController 创建服务账号管理控制器实例
+func NewServiceAccountController(serviceAccountService *services.ServiceAccountService) *ServiceAccountController {
+	return &ServiceAccountController{serviceAccountService: serviceAccountService}
+}
+
+// Create
+// @Summary 创建服务账号
+// @Description 创建一个代表内部服务的服务账号，并为其分配角色，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateServiceAccountInput true "服务账号信息"
+// @Success 200 {object} response.Response{data=dto.ServiceAccountOutput} "创建成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/service-accounts [post]
+func (c *ServiceAccountController) Create(ctx *gin.Context) {
+	var input dto.CreateServiceAccountInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	account, err := c.serviceAccountService.Create(ctx, input.Name, input.Description, input.Roles)
+	if err != nil {
+		logger.CtxErrorf(ctx, "创建服务账号失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "创建服务账号成功, operator: %d, account: %s", ctx.GetUint("userID"), account.Name)
+	response.Success(ctx, dto.NewServiceAccountOutput(account))
+}
+
+// List
+// @Summary 获取服务账号列表
+// @Description 列出全部服务账号，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.ServiceAccountOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/service-accounts [get]
+func (c *ServiceAccountController) List(ctx *gin.Context) {
+	accounts, err := c.serviceAccountService.List(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取服务账号列表失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	output := make([]dto.ServiceAccountOutput, 0, len(accounts))
+	for i := range accounts {
+		output = append(output, dto.NewServiceAccountOutput(&accounts[i]))
+	}
+	response.Success(ctx, output)
+}
+
+// IssueKey
+// @Summary 签发 API Key
+// @Description 为服务账号签发一个新的 API Key，Key 明文只在本次响应中返回，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务账号ID"
+// @Param body body dto.IssueApiKeyInput true "API Key 信息"
+// @Success 200 {object} response.Response{data=dto.IssuedApiKeyOutput} "签发成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/service-accounts/{id}/keys [post]
+func (c *ServiceAccountController) IssueKey(ctx *gin.Context) {
+	accountID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	var input dto.IssueApiKeyInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	expiresIn := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	plaintext, key, err := c.serviceAccountService.IssueKey(ctx, accountID, input.Name, expiresIn)
+	if err != nil {
+		logger.CtxErrorf(ctx, "签发 API Key 失败, accountID: %d, error: %v", accountID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "签发 API Key 成功, operator: %d, accountID: %d, prefix: %s", ctx.GetUint("userID"), accountID, key.Prefix)
+	response.Success(ctx, dto.IssuedApiKeyOutput{ApiKeyOutput: dto.NewApiKeyOutput(key), Key: plaintext})
+}
+
+// ListKeys
+// @Summary 获取 API Key 列表
+// @Description 列出服务账号未撤销的 API Key，不包含明文，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务账号ID"
+// @Success 200 {object} response.Response{data=[]dto.ApiKeyOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/service-accounts/{id}/keys [get]
+func (c *ServiceAccountController) ListKeys(ctx *gin.Context) {
+	accountID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	keys, err := c.serviceAccountService.ListKeys(ctx, accountID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取 API Key 列表失败, accountID: %d, error: %v", accountID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	output := make([]dto.ApiKeyOutput, 0, len(keys))
+	for i := range keys {
+		output = append(output, dto.NewApiKeyOutput(&keys[i]))
+	}
+	response.Success(ctx, output)
+}
+
+// RotateKey
+// @Summary 轮换 API Key
+// @Description 签发一个同名的新 API Key，旧 Key 在宽限期后失效，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Param body body dto.RotateApiKeyInput false "轮换参数"
+// @Success 200 {object} response.Response{data=dto.IssuedApiKeyOutput} "轮换成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/api-keys/{id}/rotate [post]
+func (c *ServiceAccountController) RotateKey(ctx *gin.Context) {
+	keyID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	var input dto.RotateApiKeyInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+			response.Error(ctx, err)
+			return
+		}
+	}
+
+	gracePeriod := time.Duration(input.GracePeriodMinutes) * time.Minute
+	plaintext, key, err := c.serviceAccountService.RotateKey(ctx, keyID, gracePeriod)
+	if err != nil {
+		logger.CtxErrorf(ctx, "轮换 API Key 失败, keyID: %d, error: %v", keyID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "轮换 API Key 成功, operator: %d, oldKeyID: %d, newKeyID: %d", ctx.GetUint("userID"), keyID, key.ID)
+	response.Success(ctx, dto.IssuedApiKeyOutput{ApiKeyOutput: dto.NewApiKeyOutput(key), Key: plaintext})
+}
+
+// RevokeKey
+// @Summary 撤销 API Key
+// @Description 立即撤销一个 API Key，需要 service_accounts:write 权限
+// @Tags ServiceAccounts
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /admin/api-keys/{id} [delete]
+func (c *ServiceAccountController) RevokeKey(ctx *gin.Context) {
+	keyID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.serviceAccountService.RevokeKey(ctx, keyID); err != nil {
+		logger.CtxErrorf(ctx, "撤销 API Key 失败, keyID: %d, error: %v", keyID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "撤销 API Key 成功, operator: %d, keyID: %d", ctx.GetUint("userID"), keyID)
+	response.Success(ctx, nil)
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// SessionController 登录会话控制器
+type SessionController struct {
+	sessionService *services.SessionService
+}
+
+// NewSessionController 创建登录会话控制器实例
+func NewSessionController(sessionService *services.SessionService) *SessionController {
+	return &SessionController{sessionService: sessionService}
+}
+
+// List
+// @Summary 获取登录会话列表
+// @Description 列出当前用户在各设备上的有效登录会话，current 标记发起本次请求的会话。模拟登录期间不可用
+// @Tags Sessions
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.SessionOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/sessions [get]
+func (c *SessionController) List(ctx *gin.Context) {
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	sessions, err := c.sessionService.List(ctx, principal.UserID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取登录会话失败, userID: %d, error: %v", principal.UserID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.SessionOutput, 0, len(sessions))
+	for i := range sessions {
+		outputs = append(outputs, dto.NewSessionOutput(&sessions[i], principal.Claims.SessionID))
+	}
+	response.Success(ctx, outputs)
+}
+
+// Revoke
+// @Summary 撤销登录会话
+// @Description 撤销当前用户的一个登录会话，该设备上的访问令牌和刷新令牌立即失效。模拟登录期间不可用
+// @Tags Sessions
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} response.Response "撤销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/sessions/{id} [delete]
+func (c *SessionController) Revoke(ctx *gin.Context) {
+	sessionID, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.sessionService.Revoke(ctx, userID, sessionID); err != nil {
+		logger.CtxErrorf(ctx, "撤销登录会话失败, userID: %d, sessionID: %d, error: %v", userID, sessionID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "撤销登录会话成功, userID: %d, sessionID: %d", userID, sessionID)
+	response.Success(ctx, nil)
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+)
+
+// respondLoginResult 返回登录结果，需要双因素认证时只返回 MFA 挑战令牌
+func respondLoginResult(ctx *gin.Context, cookies *utils.SessionCookies, result *services.LoginResult) {
+	if result.Tokens == nil {
+		response.Success(ctx, dto.LoginOutput{MFARequired: true, MFAToken: result.MFAToken})
+		return
+	}
+	respondTokens(ctx, cookies, result.Tokens)
+}
+
+// respondTokens 返回签发的令牌
+// 启用了 Cookie 会话且请求头带 X-Auth-Mode: cookie 时，令牌写入 HttpOnly Cookie，响应体只包含有效期和 CSRF 令牌
+func respondTokens(ctx *gin.Context, cookies *utils.SessionCookies, tokens *services.TokenPair) {
+	if cookies == nil || !utils.CookieModeRequested(ctx.Request) {
+		response.Success(ctx, dto.LoginOutput{
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		})
+		return
+	}
+
+	csrfToken, err := cookies.Set(ctx.Writer, tokens.AccessToken, tokens.RefreshToken, tokens.SessionID, tokens.ExpiresIn, services.RefreshTokenTTL)
+	if err != nil {
+		logger.CtxErrorf(ctx, "写入会话 Cookie 失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+	response.Success(ctx, dto.LoginOutput{
+		ExpiresIn: int64(tokens.ExpiresIn.Seconds()),
+		CSRFToken: csrfToken,
+	})
+}
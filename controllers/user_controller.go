@@ -7,28 +7,33 @@ import (
 	"github.com/plusone/dto"
 	"github.com/plusone/response"
 	"github.com/plusone/services"
+	"github.com/plusone/utils"
 	"github.com/plusone/utils/logger"
 )
 
 // UserController 用户控制器
 type UserController struct {
 	userService *services.UserService
+	cookies     *utils.SessionCookies // 未启用 Cookie 会话时为 nil
+
+	concealRegistration bool // 注册防枚举模式：注册成功和用户名、邮箱冲突返回相同的响应
 }
 
 // NewUserController 创建用户控制器实例
-func NewUserController(userService *services.UserService) *UserController {
-	return &UserController{userService: userService}
+func NewUserController(userService *services.UserService, cookies *utils.SessionCookies, concealRegistration bool) *UserController {
+	return &UserController{userService: userService, cookies: cookies, concealRegistration: concealRegistration}
 }
 
 // Register
 // @Summary 用户注册
-// @Description 创建一个新用户
+// @Description 创建一个新用户。密码不符合密码策略时，data.errors.password 列出每一项不满足的要求。
+// @Description 开启注册防枚举模式时，用户名或邮箱已被使用也返回成功且不返回用户信息，结果通过邮件告知
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param user body dto.RegisterInput true "用户信息"
 // @Success 200 {object} response.Response{data=dto.UserOutput} "注册成功"
-// @Failure 500 {object} response.Response "服务器错误"
+// @Failure 500 {object} response.Response{data=dto.FieldErrorsOutput} "注册失败"
 // @Router /register [post]
 func (c *UserController) Register(ctx *gin.Context) {
 	var input dto.RegisterInput
@@ -41,21 +46,31 @@ func (c *UserController) Register(ctx *gin.Context) {
 	user, err := c.userService.Register(ctx, input.Username, input.Password, input.Email, input.Nickname)
 	if err != nil {
 		logger.CtxErrorf(ctx, "用户注册失败: %v", err)
-		response.Error(ctx, err)
+		respondPasswordError(ctx, err, "password")
 		return
 	}
 
+	// 防枚举模式下冲突时 user 为 nil，两种情况都不返回用户信息
+	if c.concealRegistration {
+		if user != nil {
+			logger.CtxInfof(ctx, "用户注册成功: %s", user.Username)
+		}
+		response.Success(ctx, nil)
+		return
+	}
 	logger.CtxInfof(ctx, "用户注册成功: %s", user.Username)
 	response.Success(ctx, dto.NewUserOutput(user))
 }
 
 // Login
 // @Summary 用户登录
-// @Description 用户使用用户名和密码登录，获取JWT
+// @Description 用户使用用户名或邮箱和密码登录，获取JWT，用户名和邮箱不区分大小写。用户不存在和密码错误返回相同的错误。连续失败会被要求等待或临时锁定，响应头 Retry-After 给出可重试的秒数。
+// @Description 启用了 Cookie 会话时，请求头带 X-Auth-Mode: cookie 则令牌写入 HttpOnly Cookie，响应体返回 csrf_token
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param credentials body dto.LoginInput true "登录凭证"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示写入 Cookie"
 // @Success 200 {object} response.Response{data=dto.LoginOutput} "登录成功"
 // @Failure 500 {object} response.Response "服务器错误"
 // @Router /login [post]
@@ -67,15 +82,104 @@ func (c *UserController) Login(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.userService.Login(ctx, input.Username, input.Password)
+	result, err := c.userService.Login(ctx, input.Username, input.Password, clientInfo(ctx))
+	if err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "用户登录失败: %s, error: %v", input.Username, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if result.Tokens == nil {
+		logger.CtxInfof(ctx, "用户密码验证通过，等待双因素认证: %s", input.Username)
+	} else {
+		logger.CtxInfof(ctx, "用户登录成功: %s", input.Username)
+	}
+	respondLoginResult(ctx, c.cookies, result)
+}
+
+// LoginMFA
+// @Summary 双因素认证登录
+// @Description 登录第二步，使用登录返回的 mfa_token 和 TOTP 验证码 (或恢复码) 换取令牌
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.LoginMFAInput true "MFA 凭证"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示写入 Cookie"
+// @Success 200 {object} response.Response{data=dto.LoginOutput} "登录成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /login/mfa [post]
+func (c *UserController) LoginMFA(ctx *gin.Context) {
+	var input dto.LoginMFAInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	tokens, err := c.userService.LoginMFA(ctx, input.MFAToken, input.Code, input.RecoveryCode, clientInfo(ctx))
+	if err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "双因素认证失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "用户双因素认证登录成功")
+	respondTokens(ctx, c.cookies, tokens)
+}
+
+// RefreshToken
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌，旧的刷新令牌随即失效。
+// @Description 使用 Cookie 会话时请求头带 X-Auth-Mode: cookie 和 X-CSRF-Token，刷新令牌从 Cookie 读取，新令牌写回 Cookie
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body dto.RefreshTokenInput false "刷新令牌"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示使用 Cookie"
+// @Param X-CSRF-Token header string false "CSRF 令牌，Cookie 模式下必填"
+// @Success 200 {object} response.Response{data=dto.LoginOutput} "刷新成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /token/refresh [post]
+func (c *UserController) RefreshToken(ctx *gin.Context) {
+	var refreshToken string
+	if c.cookies != nil && utils.CookieModeRequested(ctx.Request) {
+		refreshToken = c.cookies.RefreshToken(ctx.Request)
+		if refreshToken == "" {
+			response.Error(ctx, services.ErrInvalidRefreshToken)
+			return
+		}
+		// 刷新请求同样依赖 Cookie 认证，需要校验 CSRF 令牌属于刷新令牌所在的会话
+		sessionID, err := c.userService.RefreshTokenSession(ctx, refreshToken)
+		if err != nil {
+			logger.CtxErrorf(ctx, "刷新令牌失败: %v", err)
+			response.Error(ctx, err)
+			return
+		}
+		if err := c.cookies.VerifyCSRF(ctx.Request, sessionID); err != nil {
+			logger.CtxWarnf(ctx, "刷新令牌 CSRF 校验失败, sessionID: %d", sessionID)
+			response.Error(ctx, err)
+			return
+		}
+	} else {
+		var input dto.RefreshTokenInput
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+			response.Error(ctx, err)
+			return
+		}
+		refreshToken = input.RefreshToken
+	}
+
+	tokens, err := c.userService.RefreshToken(ctx, refreshToken)
 	if err != nil {
-		logger.CtxErrorf(ctx, "用户登录失败: %v", err)
+		logger.CtxErrorf(ctx, "刷新令牌失败: %v", err)
 		response.Error(ctx, err)
 		return
 	}
 
-	logger.CtxInfof(ctx, "用户登录成功: %s", input.Username)
-	response.Success(ctx, dto.LoginOutput{Token: token})
+	respondTokens(ctx, c.cookies, tokens)
 }
 
 // GetUserInfo
@@ -91,7 +195,7 @@ func (c *UserController) GetUserInfo(ctx *gin.Context) {
 	userID, exists := ctx.Get("userID")
 	if !exists {
 		err := errors.New("未认证: 无法从上下文中获取用户ID")
-		logger.CtxErrorf(ctx, err.Error())
+		logger.CtxErrorf(ctx, "%v", err)
 		response.Error(ctx, err)
 		return
 	}
@@ -106,3 +210,215 @@ func (c *UserController) GetUserInfo(ctx *gin.Context) {
 	logger.CtxInfof(ctx, "获取用户信息成功, userID: %d", userID)
 	response.Success(ctx, dto.NewUserOutput(user))
 }
+
+// Logout
+// @Summary 注销登录
+// @Description 注销当前访问令牌及其所属会话，使用 Cookie 会话时同时清除 Cookie
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.LogoutInput false "刷新令牌"
+// @Success 200 {object} response.Response "注销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/logout [post]
+func (c *UserController) Logout(ctx *gin.Context) {
+	var input dto.LogoutInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+			response.Error(ctx, err)
+			return
+		}
+	}
+
+	claims, exists := ctx.Get("claims")
+	if !exists {
+		err := errors.New("未认证: 无法从上下文中获取令牌信息")
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.userService.Logout(ctx, claims.(*utils.JWTClaims), input.RefreshToken); err != nil {
+		logger.CtxErrorf(ctx, "注销登录失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	c.clearCookies(ctx)
+	response.Success(ctx, nil)
+}
+
+// LogoutAll
+// @Summary 退出所有设备
+// @Description 撤销当前用户已签发的全部访问令牌和刷新令牌，模拟登录期间不可用
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "注销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/logout-all [post]
+func (c *UserController) LogoutAll(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		err := errors.New("未认证: 无法从上下文中获取用户ID")
+		logger.CtxErrorf(ctx, "%v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	if err := c.userService.LogoutAll(ctx, userID.(uint)); err != nil {
+		logger.CtxErrorf(ctx, "退出所有设备失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "已退出所有设备, userID: %d", userID)
+	c.clearCookies(ctx)
+	response.Success(ctx, nil)
+}
+
+// ChangePassword
+// @Summary 修改密码
+// @Description 验证当前密码后修改密码，成功后所有设备需要重新登录；需要最近验证过身份，模拟登录期间不可用。
+// @Description 新密码不符合密码策略时，data.errors.new_password 列出每一项不满足的要求
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.ChangePasswordInput true "当前密码和新密码"
+// @Success 200 {object} response.Response "修改成功"
+// @Failure 500 {object} response.Response{data=dto.FieldErrorsOutput} "修改失败"
+// @Router /user/password [put]
+func (c *UserController) ChangePassword(ctx *gin.Context) {
+	var input dto.ChangePasswordInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.userService.ChangePassword(ctx, userID, input.CurrentPassword, input.NewPassword, clientInfo(ctx)); err != nil {
+		logger.CtxErrorf(ctx, "修改密码失败, userID: %d, error: %v", userID, err)
+		setRetryAfter(ctx, err)
+		respondPasswordError(ctx, err, "new_password")
+		return
+	}
+
+	logger.CtxInfof(ctx, "修改密码成功, userID: %d", userID)
+	response.Success(ctx, nil)
+}
+
+// ChangeEmail
+// @Summary 更换邮箱
+// @Description 验证当前密码后更换邮箱，并向新邮箱发送验证链接；需要最近验证过身份，模拟登录期间不可用
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.ChangeEmailInput true "新邮箱和当前密码"
+// @Success 200 {object} response.Response{data=dto.UserOutput} "更换成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user/email [put]
+func (c *UserController) ChangeEmail(ctx *gin.Context) {
+	var input dto.ChangeEmailInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	user, err := c.userService.ChangeEmail(ctx, userID, input.Password, input.Email, clientInfo(ctx))
+	if err != nil {
+		logger.CtxErrorf(ctx, "更换邮箱失败, userID: %d, error: %v", userID, err)
+		setRetryAfter(ctx, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "更换邮箱成功, userID: %d", userID)
+	response.Success(ctx, dto.NewUserOutput(user))
+}
+
+// Reauthenticate
+// @Summary 重新验证身份
+// @Description 使用密码或双因素验证码 (恢复码) 再次验证身份，返回属于当前会话的短期令牌。
+// @Description 修改密码、更换邮箱、注销账号等操作要求最近验证过身份，提示需要重新验证时调用本接口，再用返回的令牌重试。
+// @Description 使用 Cookie 会话时短期令牌写入 HttpOnly Cookie 替换访问令牌，响应中只有有效期，直接重试即可。
+// @Description 验证码和恢复码连续错误过多时与登录第二步一起锁定，返回 Retry-After
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.ReauthenticateInput true "密码或验证码"
+// @Success 200 {object} response.Response{data=dto.ReauthenticateOutput} "验证成功"
+// @Failure 500 {object} response.Response "验证失败"
+// @Router /user/reauthenticate [post]
+func (c *UserController) Reauthenticate(ctx *gin.Context) {
+	var input dto.ReauthenticateInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	principal, err := currentPrincipal(ctx)
+	if err != nil {
+		response.Error(ctx, err)
+		return
+	}
+
+	token, expiresIn, err := c.userService.Reauthenticate(ctx, principal.Claims, input.Password, input.Code, input.RecoveryCode, clientInfo(ctx))
+	if err != nil {
+		setRetryAfter(ctx, err)
+		logger.CtxErrorf(ctx, "重新验证身份失败, userID: %d, error: %v", principal.UserID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "重新验证身份成功, userID: %d", principal.UserID)
+	output := dto.ReauthenticateOutput{Token: token, ExpiresIn: int64(expiresIn.Seconds())}
+	// 使用 Cookie 认证时短期令牌不交给前端脚本，写入 HttpOnly Cookie 替换当前的访问令牌
+	if c.cookieAuthenticated(ctx) {
+		c.cookies.SetAccessToken(ctx.Writer, token, expiresIn)
+		output.Token = ""
+	}
+	response.Success(ctx, output)
+}
+
+// DeleteAccount
+// @Summary 注销账号
+// @Description 彻底删除当前用户及其登录凭据和会话，所有令牌立即失效。需要最近验证过身份，模拟登录期间不可用
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "注销成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /user [delete]
+func (c *UserController) DeleteAccount(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+	if err := c.userService.DeleteAccount(ctx, userID); err != nil {
+		logger.CtxErrorf(ctx, "注销账号失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "账号已注销, userID: %d", userID)
+	c.clearCookies(ctx)
+	response.Success(ctx, nil)
+}
+
+// clearCookies 请求使用 Cookie 认证时清除会话 Cookie，使用 Authorization 头注销其他会话时保留
+func (c *UserController) clearCookies(ctx *gin.Context) {
+	if c.cookieAuthenticated(ctx) {
+		c.cookies.Clear(ctx.Writer)
+	}
+}
+
+// cookieAuthenticated 判断请求是否使用会话 Cookie 认证，与认证中间件一样 Authorization 头优先
+func (c *UserController) cookieAuthenticated(ctx *gin.Context) bool {
+	return c.cookies != nil && ctx.GetHeader("Authorization") == "" && c.cookies.AccessToken(ctx.Request) != ""
+}
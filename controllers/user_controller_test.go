@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/mailer"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testLoginMaxAttempts = 3
+
+type discardMailer struct{}
+
+func (discardMailer) Send(ctx context.Context, msg mailer.Message) error {
+	return nil
+}
+
+// newLoginRouter 创建只注册登录接口的路由，用户服务使用内存数据库、进程内 Redis 和数据库认证后端
+func newLoginRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.RecoveryCode{}, &models.Session{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	users := repositories.NewUserRepository(db)
+	roles := repositories.NewRoleRepository(db)
+	hasher := utils.NewPasswordHasher(utils.PasswordHasherOptions{Algorithm: "bcrypt", BcryptCost: 4})
+	if err := services.NewRoleService(roles, users, hasher, nil).EnsureDefaults(context.Background()); err != nil {
+		t.Fatalf("初始化默认角色失败: %v", err)
+	}
+	tokens := services.NewTokenService(utils.NewHMACKeyring("test-secret"), utils.TokenOptions{
+		Issuer:   "plusone-test",
+		Audience: "plusone-test",
+		TTL:      15 * time.Minute,
+	}, users, repositories.NewSessionRepository(db), rdb)
+	mfa := services.NewMFAService(users, repositories.NewRecoveryCodeRepository(db), rdb, "PlusOne")
+	verification := services.NewEmailVerificationService(users, discardMailer{}, rdb, "verify-secret", "http://localhost", time.Hour, time.Minute)
+	throttle := services.NewLoginThrottleService(rdb, services.LoginThrottleOptions{
+		MaxAttempts:     testLoginMaxAttempts,
+		IPMaxAttempts:   1000,
+		Window:          15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+	})
+	userService := services.NewUserService(users, roles, tokens, hasher, services.NewPasswordPolicy(services.PasswordPolicyOptions{MinLength: 1, MaxLength: 128}, nil),
+		mfa, verification, throttle, services.NewDatabaseAuthenticator(users, hasher), services.NewLoginCompleter(tokens, mfa, true), rdb, false, 5*time.Minute)
+
+	for _, user := range []struct {
+		username, email, password string
+		verified                  bool
+	}{
+		{"alice", "alice@example.com", "correct-password", true},
+		{"unverified", "unverified@example.com", "correct-password", false},
+		{"federated", "federated@example.com", "", true},
+	} {
+		record := &models.User{Username: user.username, Email: user.email}
+		if user.password != "" {
+			if err := record.SetPassword(hasher, user.password); err != nil {
+				t.Fatalf("设置密码失败: %v", err)
+			}
+		}
+		if user.verified {
+			now := time.Now()
+			record.EmailVerifiedAt = &now
+		}
+		if err := users.Create(context.Background(), record); err != nil {
+			t.Fatalf("创建用户 %s 失败: %v", user.username, err)
+		}
+	}
+
+	router := gin.New()
+	router.POST("/api/login", NewUserController(userService, nil, false).Login)
+	return router
+}
+
+type loginResponse struct {
+	status     int
+	body       string
+	retryAfter string
+}
+
+func postLogin(router *gin.Engine, username, password string) loginResponse {
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return loginResponse{status: w.Code, body: w.Body.String(), retryAfter: w.Header().Get("Retry-After")}
+}
+
+func TestUserControllerLoginUniformResponse(t *testing.T) {
+	router := newLoginRouter(t)
+	// 基准：已存在的用户输入错误的密码
+	want := postLogin(router, "alice", "wrong-password")
+	if want.status != http.StatusInternalServerError || want.retryAfter != "" {
+		t.Fatalf("密码错误的响应不正确: %+v", want)
+	}
+	var body struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(want.body), &body); err != nil || body.Msg != services.ErrInvalidCredentials.Error() || body.Data != nil {
+		t.Fatalf("密码错误的响应体不正确: %s", want.body)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{name: "用户不存在", username: "ghost", password: "correct-password"},
+		{name: "邮箱不存在", username: "ghost@example.com", password: "correct-password"},
+		{name: "使用邮箱登录且密码错误", username: "alice@example.com", password: "wrong-password"},
+		{name: "没有密码的外部账号", username: "federated", password: "correct-password"},
+		{name: "邮箱未验证且密码错误", username: "unverified", password: "wrong-password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postLogin(router, tt.username, tt.password); got != want {
+				t.Fatalf("响应与密码错误不一致:\n实际 %+v\n期望 %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserControllerLoginLockedResponse(t *testing.T) {
+	router := newLoginRouter(t)
+
+	lock := func(username string) loginResponse {
+		t.Helper()
+		for i := 0; i < testLoginMaxAttempts; i++ {
+			postLogin(router, username, "wrong-password")
+		}
+		return postLogin(router, username, "correct-password")
+	}
+	existing := lock("alice")
+	unknown := lock("ghost")
+	unverified := lock("unverified")
+
+	// 锁定后无论账号是否存在、密码是否正确都返回相同的响应，并通过 Retry-After 告知等待时间
+	if existing.status != http.StatusInternalServerError || existing.retryAfter == "" {
+		t.Fatalf("锁定的响应不正确: %+v", existing)
+	}
+	for name, got := range map[string]loginResponse{"不存在的账号": unknown, "邮箱未验证的账号": unverified} {
+		if got.status != existing.status || got.body != existing.body || got.retryAfter != existing.retryAfter {
+			t.Errorf("%s 锁定后的响应与已存在的账号不一致:\n实际 %+v\n期望 %+v", name, got, existing)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/dto"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+)
+
+// WebAuthnController WebAuthn 通行密钥控制器
+type WebAuthnController struct {
+	webAuthnService *services.WebAuthnService
+	cookies         *utils.SessionCookies // 未启用 Cookie 会话时为 nil
+}
+
+// NewWebAuthnController 创建 WebAuthn 控制器实例
+func NewWebAuthnController(webAuthnService *services.WebAuthnService, cookies *utils.SessionCookies) *WebAuthnController {
+	return &WebAuthnController{webAuthnService: webAuthnService, cookies: cookies}
+}
+
+// BeginRegistration
+// @Summary 开始注册通行密钥
+// @Description 生成传给 navigator.credentials.create() 的参数，挑战 5 分钟内有效；需要最近验证过身份
+// @Tags WebAuthn
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "生成成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/register/begin [post]
+func (c *WebAuthnController) BeginRegistration(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	options, err := c.webAuthnService.BeginRegistration(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "开始注册通行密钥失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, options)
+}
+
+// FinishRegistration
+// @Summary 完成注册通行密钥
+// @Description 请求体为 navigator.credentials.create() 返回的 PublicKeyCredential JSON；需要最近验证过身份
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name query string false "凭据名称"
+// @Success 200 {object} response.Response{data=dto.WebAuthnCredentialOutput} "注册成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/register/finish [post]
+func (c *WebAuthnController) FinishRegistration(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	credential, err := c.webAuthnService.FinishRegistration(ctx, userID, ctx.Query("name"), ctx.Request.Body)
+	if err != nil {
+		logger.CtxErrorf(ctx, "注册通行密钥失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "注册通行密钥成功, userID: %d, credentialID: %d", userID, credential.ID)
+	response.Success(ctx, dto.NewWebAuthnCredentialOutput(credential))
+}
+
+// BeginLogin
+// @Summary 开始通行密钥登录
+// @Description 生成传给 navigator.credentials.get() 的参数，不提供用户名时使用可发现凭据
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Param body body dto.WebAuthnLoginBeginInput false "用户名"
+// @Success 200 {object} response.Response{data=dto.WebAuthnLoginBeginOutput} "生成成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/login/begin [post]
+func (c *WebAuthnController) BeginLogin(ctx *gin.Context) {
+	var input dto.WebAuthnLoginBeginInput
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			logger.CtxErrorf(ctx, "参数绑定失败: %v", err)
+			response.Error(ctx, err)
+			return
+		}
+	}
+
+	sessionID, options, err := c.webAuthnService.BeginLogin(ctx, input.Username)
+	if err != nil {
+		logger.CtxErrorf(ctx, "开始通行密钥登录失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	response.Success(ctx, dto.WebAuthnLoginBeginOutput{SessionID: sessionID, Options: options})
+}
+
+// FinishLogin
+// @Summary 完成通行密钥登录
+// @Description 请求体为 navigator.credentials.get() 返回的 PublicKeyCredential JSON，成功后签发与密码登录相同的令牌
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Param session_id query string true "开始登录时返回的会话ID"
+// @Param X-Auth-Mode header string false "令牌返回方式，cookie 表示写入 Cookie"
+// @Success 200 {object} response.Response{data=dto.LoginOutput} "登录成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/login/finish [post]
+func (c *WebAuthnController) FinishLogin(ctx *gin.Context) {
+	tokens, err := c.webAuthnService.FinishLogin(ctx, ctx.Query("session_id"), ctx.Request.Body, clientInfo(ctx))
+	if err != nil {
+		logger.CtxErrorf(ctx, "通行密钥登录失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "通行密钥登录成功")
+	respondTokens(ctx, c.cookies, tokens)
+}
+
+// ListCredentials
+// @Summary 获取通行密钥列表
+// @Description 列出当前用户已注册的 WebAuthn 凭据
+// @Tags WebAuthn
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.WebAuthnCredentialOutput} "获取成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/credentials [get]
+func (c *WebAuthnController) ListCredentials(ctx *gin.Context) {
+	userID := ctx.GetUint("userID")
+
+	credentials, err := c.webAuthnService.ListCredentials(ctx, userID)
+	if err != nil {
+		logger.CtxErrorf(ctx, "获取通行密钥失败, userID: %d, error: %v", userID, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	outputs := make([]dto.WebAuthnCredentialOutput, 0, len(credentials))
+	for i := range credentials {
+		outputs = append(outputs, dto.NewWebAuthnCredentialOutput(&credentials[i]))
+	}
+	response.Success(ctx, outputs)
+}
+
+// DeleteCredential
+// @Summary 删除通行密钥
+// @Description 删除当前用户的一个 WebAuthn 凭据
+// @Tags WebAuthn
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "凭据ID"
+// @Success 200 {object} response.Response "删除成功"
+// @Failure 500 {object} response.Response "服务器错误"
+// @Router /webauthn/credentials/{id} [delete]
+func (c *WebAuthnController) DeleteCredential(ctx *gin.Context) {
+	id, err := parseIDParam(ctx, "id")
+	if err != nil {
+		logger.CtxErrorf(ctx, "参数解析失败: %v", err)
+		response.Error(ctx, err)
+		return
+	}
+
+	userID := ctx.GetUint("userID")
+	if err := c.webAuthnService.DeleteCredential(ctx, userID, id); err != nil {
+		logger.CtxErrorf(ctx, "删除通行密钥失败, userID: %d, credentialID: %d, error: %v", userID, id, err)
+		response.Error(ctx, err)
+		return
+	}
+
+	logger.CtxInfof(ctx, "删除通行密钥成功, userID: %d, credentialID: %d", userID, id)
+	response.Success(ctx, nil)
+}
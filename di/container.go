@@ -1,25 +1,197 @@
 package di
 
 import (
+	"github.com/crewjam/saml"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/plusone/config"
 	"github.com/plusone/controllers"
 	"github.com/plusone/repositories"
 	"github.com/plusone/services"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/mailer"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 // Container 依赖注入容器
 type Container struct {
-	UserController *controllers.UserController
+	AuthService                   *services.AuthService
+	RoleService                   *services.RoleService
+	UserService                   *services.UserService
+	UserController                *controllers.UserController
+	AdminController               *controllers.AdminController
+	JWKSController                *controllers.JWKSController
+	PersonalAccessTokenController *controllers.PersonalAccessTokenController
+	ServiceAccountController      *controllers.ServiceAccountController
+	MFAController                 *controllers.MFAController
+	WebAuthnController            *controllers.WebAuthnController
+	PasswordController            *controllers.PasswordController
+	EmailVerificationController   *controllers.EmailVerificationController
+	SessionController             *controllers.SessionController
+	ImpersonationController       *controllers.ImpersonationController
+	OAuthController               *controllers.OAuthController
+	OAuthClientController         *controllers.OAuthClientController
+	OIDCController                *controllers.OIDCController // 未启用 OpenID Connect 身份提供方时为 nil
+	ExternalLoginController       *controllers.ExternalLoginController
+	SAMLController                *controllers.SAMLController // 未启用 SAML 时为 nil
+	SessionCookies                *utils.SessionCookies       // 未启用 Cookie 会话时为 nil
 }
 
 // NewContainer 创建一个新的依赖注入容器
-func NewContainer(db *gorm.DB, jwtSecret string, rdb *redis.Client) *Container {
+func NewContainer(cfg *config.Config, db *gorm.DB, keys *utils.Keyring, relyingParty *webauthn.WebAuthn, samlSP *saml.ServiceProvider, rdb *redis.Client, m mailer.Mailer) *Container {
 	userRepository := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepository, jwtSecret, rdb)
-	userController := controllers.NewUserController(userService)
+	roleRepository := repositories.NewRoleRepository(db)
+	patRepository := repositories.NewPersonalAccessTokenRepository(db)
+	serviceAccountRepository := repositories.NewServiceAccountRepository(db)
+	recoveryCodeRepository := repositories.NewRecoveryCodeRepository(db)
+	webAuthnCredentialRepository := repositories.NewWebAuthnCredentialRepository(db)
+	passwordResetTokenRepository := repositories.NewPasswordResetTokenRepository(db)
+	sessionRepository := repositories.NewSessionRepository(db)
+	impersonationLogRepository := repositories.NewImpersonationLogRepository(db)
+	oauthRepository := repositories.NewOAuthRepository(db)
+	identityRepository := repositories.NewIdentityRepository(db)
+	tokenService := services.NewTokenService(keys, utils.TokenOptions{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		TTL:      cfg.AccessTokenTTL,
+		Leeway:   cfg.JWTLeeway,
+	}, userRepository, sessionRepository, rdb)
+	passwordHasher := utils.NewPasswordHasher(utils.PasswordHasherOptions{
+		Algorithm:         cfg.PasswordHashAlgorithm,
+		Argon2Memory:      uint32(cfg.Argon2Memory),
+		Argon2Iterations:  uint32(cfg.Argon2Iterations),
+		Argon2Parallelism: uint8(cfg.Argon2Parallelism),
+		BcryptCost:        cfg.BcryptCost,
+	})
+	var breachedPasswords *utils.BreachedPasswords
+	if cfg.PasswordBreachedFile != "" {
+		breachedPasswords = utils.NewBreachedPasswords(cfg.PasswordBreachedFile)
+	}
+	var passwordMaxBytes int
+	if cfg.PasswordHashAlgorithm == utils.PasswordAlgorithmBcrypt {
+		passwordMaxBytes = utils.BcryptMaxPasswordBytes
+	}
+	passwordPolicy := services.NewPasswordPolicy(services.PasswordPolicyOptions{
+		MinLength:      cfg.PasswordMinLength,
+		MaxLength:      cfg.PasswordMaxLength,
+		MaxBytes:       passwordMaxBytes,
+		MinCharClasses: cfg.PasswordMinCharClasses,
+		MinEntropy:     float64(cfg.PasswordMinEntropy),
+		RejectUserInfo: cfg.PasswordRejectUserInfo,
+	}, breachedPasswords)
+	mfaService := services.NewMFAService(userRepository, recoveryCodeRepository, rdb, cfg.MFAIssuer)
+	emailVerificationService := services.NewEmailVerificationService(userRepository, m, rdb, cfg.EmailVerificationSecret, cfg.AppBaseURL, cfg.EmailVerificationTTL, cfg.EmailVerificationResendInterval)
+	loginCompleter := services.NewLoginCompleter(tokenService, mfaService, cfg.EmailVerificationRequired)
+	loginThrottleService := services.NewLoginThrottleService(rdb, services.LoginThrottleOptions{
+		MaxAttempts:     cfg.LoginMaxAttempts,
+		IPMaxAttempts:   cfg.LoginIPMaxAttempts,
+		Window:          cfg.LoginAttemptWindow,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		BackoffBase:     cfg.LoginBackoffBase,
+		BackoffMax:      cfg.LoginBackoffMax,
+	})
+	userService := services.NewUserService(userRepository, roleRepository, tokenService, passwordHasher, passwordPolicy, mfaService, emailVerificationService, loginThrottleService, newAuthenticator(cfg, userRepository, identityRepository, roleRepository, passwordHasher), loginCompleter, rdb, cfg.RegisterConcealConflicts, cfg.ReauthTokenTTL)
+	roleService := services.NewRoleService(roleRepository, userRepository, passwordHasher, tokenService)
+	patService := services.NewPersonalAccessTokenService(patRepository, userRepository)
+	serviceAccountService := services.NewServiceAccountService(serviceAccountRepository, roleRepository)
+	authService := services.NewAuthService(tokenService, patService, serviceAccountService)
+	webAuthnService := services.NewWebAuthnService(relyingParty, userRepository, webAuthnCredentialRepository, loginCompleter, rdb)
+	sessionService := services.NewSessionService(sessionRepository, tokenService)
+	passwordResetService := services.NewPasswordResetService(userRepository, passwordResetTokenRepository, tokenService, passwordHasher, passwordPolicy, m, rdb, cfg.AppBaseURL, cfg.PasswordResetTTL, cfg.PasswordResetRequestInterval)
+	impersonationService := services.NewImpersonationService(userRepository, impersonationLogRepository, tokenService, cfg.ImpersonationTTL)
+	oauthService := services.NewOAuthService(oauthRepository, userRepository, sessionRepository, tokenService, rdb, cfg.OIDCIssuer, cfg.OIDCProviderEnabled)
+	oauthClientService := services.NewOAuthClientService(oauthRepository)
+	externalProviders := make([]services.ExternalProviderOptions, 0, len(cfg.ExternalProviders))
+	for _, p := range cfg.ExternalProviders {
+		externalProviders = append(externalProviders, services.ExternalProviderOptions(p))
+	}
+	externalLoginService := services.NewExternalLoginService(externalProviders, userRepository, identityRepository, emailVerificationService, loginCompleter, rdb)
+	var sessionCookies *utils.SessionCookies
+	if cfg.AuthCookieEnabled {
+		sessionCookies = utils.NewSessionCookies(utils.SessionCookieOptions{
+			Name:       cfg.AuthCookieName,
+			Domain:     cfg.AuthCookieDomain,
+			Secure:     cfg.AuthCookieSecure,
+			SameSite:   utils.ParseSameSite(cfg.AuthCookieSameSite),
+			CSRFSecret: []byte(cfg.CSRFSecret),
+		})
+	}
+	userController := controllers.NewUserController(userService, sessionCookies, cfg.RegisterConcealConflicts)
+	adminController := controllers.NewAdminController(userService, roleService)
+	jwksController := controllers.NewJWKSController(tokenService)
+	patController := controllers.NewPersonalAccessTokenController(patService)
+	serviceAccountController := controllers.NewServiceAccountController(serviceAccountService)
+	mfaController := controllers.NewMFAController(mfaService)
+	webAuthnController := controllers.NewWebAuthnController(webAuthnService, sessionCookies)
+	passwordController := controllers.NewPasswordController(passwordResetService)
+	emailVerificationController := controllers.NewEmailVerificationController(emailVerificationService)
+	sessionController := controllers.NewSessionController(sessionService)
+	impersonationController := controllers.NewImpersonationController(impersonationService)
+	oauthController := controllers.NewOAuthController(oauthService, sessionCookies, cfg.OAuthConsentURL)
+	oauthClientController := controllers.NewOAuthClientController(oauthClientService)
+	var oidcController *controllers.OIDCController
+	if cfg.OIDCProviderEnabled {
+		oidcController = controllers.NewOIDCController(oauthService, tokenService, cfg.OIDCIssuer)
+	}
+	externalLoginController := controllers.NewExternalLoginController(externalLoginService, sessionCookies)
+	var samlController *controllers.SAMLController
+	if samlSP != nil {
+		samlService := services.NewSAMLService(samlSP, services.SAMLOptions{
+			IDPMetadataURL:    cfg.SAMLIDPMetadataURL,
+			UsernameAttribute: cfg.SAMLUsernameAttribute,
+			EmailAttribute:    cfg.SAMLEmailAttribute,
+			NicknameAttribute: cfg.SAMLNicknameAttribute,
+			CallbackURL:       cfg.SAMLCallbackURL,
+		}, externalLoginService, userRepository, rdb)
+		samlController = controllers.NewSAMLController(samlService, sessionCookies)
+	}
 
 	return &Container{
-		UserController: userController,
+		AuthService:                   authService,
+		RoleService:                   roleService,
+		UserService:                   userService,
+		UserController:                userController,
+		AdminController:               adminController,
+		JWKSController:                jwksController,
+		PersonalAccessTokenController: patController,
+		ServiceAccountController:      serviceAccountController,
+		MFAController:                 mfaController,
+		WebAuthnController:            webAuthnController,
+		PasswordController:            passwordController,
+		EmailVerificationController:   emailVerificationController,
+		SessionController:             sessionController,
+		ImpersonationController:       impersonationController,
+		OAuthController:               oauthController,
+		OAuthClientController:         oauthClientController,
+		OIDCController:                oidcController,
+		ExternalLoginController:       externalLoginController,
+		SAMLController:                samlController,
+		SessionCookies:                sessionCookies,
+	}
+}
+
+// newAuthenticator 按 AUTHENTICATORS 配置的顺序组装认证后端
+func newAuthenticator(cfg *config.Config, users *repositories.UserRepository, identities *repositories.IdentityRepository, roles *repositories.RoleRepository, hasher *utils.PasswordHasher) services.Authenticator {
+	chain := make(services.AuthenticatorChain, 0, len(cfg.Authenticators))
+	for _, name := range cfg.Authenticators {
+		switch name {
+		case "database":
+			chain = append(chain, services.NewDatabaseAuthenticator(users, hasher))
+		case "ldap":
+			chain = append(chain, services.NewLDAPAuthenticator(services.LDAPOptions{
+				URL:            cfg.LDAPURL,
+				StartTLS:       cfg.LDAPStartTLS,
+				BindDN:         cfg.LDAPBindDN,
+				BindPassword:   cfg.LDAPBindPassword,
+				BaseDN:         cfg.LDAPBaseDN,
+				UserFilter:     cfg.LDAPUserFilter,
+				EmailAttribute: cfg.LDAPEmailAttribute,
+				NameAttribute:  cfg.LDAPNameAttribute,
+				GroupAttribute: cfg.LDAPGroupAttribute,
+				GroupRoles:     cfg.LDAPGroupRoles,
+				Timeout:        cfg.LDAPTimeout,
+			}, users, identities, roles))
+		}
 	}
+	return chain
 }
@@ -0,0 +1,12 @@
+package dto
+
+// ResendEmailVerificationInput 重新发送验证邮件的输入
+type ResendEmailVerificationInput struct {
+	Email string `json:"email" binding:"required,email" example:"test@example.com"`
+}
+
+// ChangeEmailInput 更换邮箱的输入
+type ChangeEmailInput struct {
+	Email    string `json:"email" binding:"required,email" example:"new@example.com"`
+	Password string `json:"password" binding:"required" example:"password123"` // 当前密码
+}
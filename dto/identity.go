@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// ExternalAuthorizationOutput 跳转到外部身份提供方的授权地址
+type ExternalAuthorizationOutput struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// ExternalCallbackInput 外部身份提供方回调前端页面后，前端提交的授权码和状态值
+type ExternalCallbackInput struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// IdentityOutput 用户关联的外部身份
+type IdentityOutput struct {
+	ID          uint       `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	Username    string     `json:"username"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// NewIdentityOutput 将 models.Identity 转换为 IdentityOutput DTO
+func NewIdentityOutput(identity *models.Identity) IdentityOutput {
+	return IdentityOutput{
+		ID:          identity.ID,
+		Provider:    identity.Provider,
+		Email:       identity.Email,
+		Username:    identity.Username,
+		LastLoginAt: identity.LastLoginAt,
+		CreatedAt:   identity.CreatedAt,
+	}
+}
+
+// SAMLExchangeInput SAML 登录完成后，前端使用 ACS 跳转地址中的一次性登录码换取令牌
+type SAMLExchangeInput struct {
+	Code string `json:"code" binding:"required"`
+}
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// ImpersonateInput 发起模拟登录的输入
+type ImpersonateInput struct {
+	Reason string `json:"reason" binding:"required,max=500" example:"复现工单 #1234 中的问题"`
+}
+
+// ImpersonationOutput 发起模拟登录的输出
+type ImpersonationOutput struct {
+	Token           string    `json:"token"`
+	ExpiresIn       int64     `json:"expires_in"` // 模拟令牌有效期 (秒)
+	ImpersonationID uint      `json:"impersonation_id"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// ImpersonationLogOutput 模拟登录审计记录的标准输出
+type ImpersonationLogOutput struct {
+	ID           uint       `json:"id"`
+	ActorID      uint       `json:"actor_id"`
+	TargetUserID uint       `json:"target_user_id"`
+	Reason       string     `json:"reason"`
+	IP           string     `json:"ip"`
+	UserAgent    string     `json:"user_agent"`
+	StartedAt    time.Time  `json:"started_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	EndedAt      *time.Time `json:"ended_at"` // 为空表示未主动结束，令牌到期后自然失效
+}
+
+// NewImpersonationLogOutput 将 models.ImpersonationLog 转换为 ImpersonationLogOutput DTO
+func NewImpersonationLogOutput(log *models.ImpersonationLog) ImpersonationLogOutput {
+	return ImpersonationLogOutput{
+		ID:           log.ID,
+		ActorID:      log.ActorID,
+		TargetUserID: log.TargetUserID,
+		Reason:       log.Reason,
+		IP:           log.IP,
+		UserAgent:    log.UserAgent,
+		StartedAt:    log.CreatedAt,
+		ExpiresAt:    log.ExpiresAt,
+		EndedAt:      log.EndedAt,
+	}
+}
@@ -0,0 +1,23 @@
+package dto
+
+// TOTPEnrollmentOutput 开始绑定 TOTP 的输出
+type TOTPEnrollmentOutput struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"` // 可生成二维码供验证器应用扫描
+}
+
+// TOTPCodeInput 提交 TOTP 验证码的输入
+type TOTPCodeInput struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// SecondFactorInput 提交第二因素的输入，验证码和恢复码提供其一即可
+type SecondFactorInput struct {
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"abcde-fghij"`
+}
+
+// RecoveryCodesOutput 恢复码的输出，明文只返回这一次
+type RecoveryCodesOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
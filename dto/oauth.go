@@ -0,0 +1,130 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// CreateOAuthClientInput 注册 OAuth 客户端的输入
+type CreateOAuthClientInput struct {
+	Name         string   `json:"name" binding:"required,max=100" example:"示例应用"`
+	RedirectURIs []string `json:"redirect_uris" example:"https://app.example.com/callback"`
+	Scopes       []string `json:"scopes" binding:"required" example:"user:read"`
+	GrantTypes   []string `json:"grant_types" example:"authorization_code,refresh_token"` // 留空默认为 authorization_code 和 refresh_token
+	Confidential bool     `json:"confidential" example:"true"`                            // 机密客户端会获得一个客户端密钥
+}
+
+// OAuthClientOutput OAuth 客户端信息的标准输出
+type OAuthClientOutput struct {
+	ID           uint      `json:"id"`
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	GrantTypes   []string  `json:"grant_types"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreatedOAuthClientOutput 注册客户端的输出，客户端密钥只在注册时返回一次
+type CreatedOAuthClientOutput struct {
+	OAuthClientOutput
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// NewOAuthClientOutput 将 models.OAuthClient 转换为 OAuthClientOutput DTO
+func NewOAuthClientOutput(client *models.OAuthClient) OAuthClientOutput {
+	return OAuthClientOutput{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIList(),
+		Scopes:       client.ScopeList(),
+		GrantTypes:   client.GrantTypeList(),
+		Confidential: client.Confidential(),
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// AuthorizeInput 授权请求的参数，GET 时来自查询字符串，POST 时来自 JSON
+type AuthorizeInput struct {
+	ResponseType        string `form:"response_type" json:"response_type" example:"code"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope" example:"user:read"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" example:"S256"`
+}
+
+// AuthorizeDecisionInput 用户确认或拒绝授权的输入
+type AuthorizeDecisionInput struct {
+	AuthorizeInput
+	Approve bool `json:"approve"`
+}
+
+// AuthorizationPromptOutput 授权请求的校验结果
+// consent_required 为 true 时前端展示授权确认页，否则直接跳转到 redirect_to
+type AuthorizationPromptOutput struct {
+	ClientID        string   `json:"client_id,omitempty"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	ConsentRequired bool     `json:"consent_required"`
+	RedirectTo      string   `json:"redirect_to,omitempty"`
+}
+
+// AuthorizeDecisionOutput 用户确认或拒绝授权后的输出
+type AuthorizeDecisionOutput struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuthTokenOutput 令牌端点的响应 (RFC 6749 第 5.1 节)
+type OAuthTokenOutput struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthErrorOutput OAuth 端点的错误响应 (RFC 6749 第 5.2 节)
+type OAuthErrorOutput struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// IntrospectionOutput 令牌自省的响应 (RFC 7662 第 2.2 节)
+type IntrospectionOutput struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// OAuthConsentOutput 用户已授权应用的标准输出
+type OAuthConsentOutput struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// NewOAuthConsentOutput 将 models.OAuthConsent 转换为 OAuthConsentOutput DTO
+func NewOAuthConsentOutput(consent *models.OAuthConsent) OAuthConsentOutput {
+	return OAuthConsentOutput{
+		ClientID:   consent.Client.ClientID,
+		ClientName: consent.Client.Name,
+		Scopes:     consent.ScopeList(),
+		GrantedAt:  consent.UpdatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package dto
+
+// ForgotPasswordInput 找回密码的输入
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email" example:"test@example.com"`
+}
+
+// ResetPasswordInput 重置密码的输入
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"` // 重置邮件链接中的令牌
+	Password string `json:"password" binding:"required" example:"Blue-Otter-Canyon-42"`
+}
+
+// ChangePasswordInput 修改密码的输入
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required" example:"password123"`
+	NewPassword     string `json:"new_password" binding:"required" example:"Blue-Otter-Canyon-42"`
+}
@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// CreatePersonalAccessTokenInput 创建个人访问令牌的输入
+type CreatePersonalAccessTokenInput struct {
+	Name          string   `json:"name" binding:"required,max=100" example:"ci-deploy"`
+	Scopes        []string `json:"scopes" example:"user:read"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365" example:"90"` // 留空表示永不过期
+}
+
+// PersonalAccessTokenOutput 个人访问令牌信息的标准输出
+type PersonalAccessTokenOutput struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedPersonalAccessTokenOutput 创建个人访问令牌的输出，Token 只在创建时返回一次
+type CreatedPersonalAccessTokenOutput struct {
+	PersonalAccessTokenOutput
+	Token string `json:"token"`
+}
+
+// NewPersonalAccessTokenOutput 将 models.PersonalAccessToken 转换为 PersonalAccessTokenOutput DTO
+func NewPersonalAccessTokenOutput(token *models.PersonalAccessToken) PersonalAccessTokenOutput {
+	return PersonalAccessTokenOutput{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scopes:     token.ScopeList(),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "github.com/plusone/models"
+
+// AssignRolesInput 为用户分配角色的输入
+type AssignRolesInput struct {
+	Roles []string `json:"roles" binding:"required" example:"admin,user"`
+}
+
+// RoleOutput 角色信息的标准输出
+type RoleOutput struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// NewRoleOutput 将 models.Role 转换为 RoleOutput DTO
+func NewRoleOutput(role *models.Role) RoleOutput {
+	perms := make([]string, 0, len(role.Permissions))
+	for _, perm := range role.Permissions {
+		perms = append(perms, perm.Code)
+	}
+	return RoleOutput{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: perms,
+	}
+}
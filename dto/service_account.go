@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// CreateServiceAccountInput 创建服务账号的输入
+type CreateServiceAccountInput struct {
+	Name        string   `json:"name" binding:"required,max=100" example:"billing-service"`
+	Description string   `json:"description" binding:"max=200" example:"计费服务"`
+	Roles       []string `json:"roles" example:"user"`
+}
+
+// IssueApiKeyInput 签发 API Key 的输入
+type IssueApiKeyInput struct {
+	Name          string `json:"name" binding:"required,max=100" example:"production"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"omitempty,min=1,max=3650" example:"365"` // 留空表示永不过期
+}
+
+// RotateApiKeyInput 轮换 API Key 的输入
+type RotateApiKeyInput struct {
+	GracePeriodMinutes int `json:"grace_period_minutes" binding:"omitempty,min=0,max=10080" example:"60"` // 旧 Key 的宽限期，0 表示立即撤销
+}
+
+// ServiceAccountOutput 服务账号信息的标准输出
+type ServiceAccountOutput struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Roles       []string  `json:"roles"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ApiKeyOutput API Key 信息的标准输出
+type ApiKeyOutput struct {
+	ID               uint       `json:"id"`
+	ServiceAccountID uint       `json:"service_account_id"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IssuedApiKeyOutput 签发 API Key 的输出，Key 只在签发时返回一次
+type IssuedApiKeyOutput struct {
+	ApiKeyOutput
+	Key string `json:"key"`
+}
+
+// NewServiceAccountOutput 将 models.ServiceAccount 转换为 ServiceAccountOutput DTO
+func NewServiceAccountOutput(account *models.ServiceAccount) ServiceAccountOutput {
+	return ServiceAccountOutput{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		Roles:       account.RoleNames(),
+		CreatedAt:   account.CreatedAt,
+	}
+}
+
+// NewApiKeyOutput 将 models.ApiKey 转换为 ApiKeyOutput DTO
+func NewApiKeyOutput(key *models.ApiKey) ApiKeyOutput {
+	return ApiKeyOutput{
+		ID:               key.ID,
+		ServiceAccountID: key.ServiceAccountID,
+		Name:             key.Name,
+		Prefix:           key.Prefix,
+		ExpiresAt:        key.ExpiresAt,
+		LastUsedAt:       key.LastUsedAt,
+		CreatedAt:        key.CreatedAt,
+	}
+}
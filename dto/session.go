@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/plusone/models"
+)
+
+// SessionOutput 登录会话信息的标准输出
+type SessionOutput struct {
+	ID          uint      `json:"id"`
+	DeviceLabel string    `json:"device_label"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	Current     bool      `json:"current"` // 是否为发起本次请求的会话
+}
+
+// NewSessionOutput 将 models.Session 转换为 SessionOutput DTO
+func NewSessionOutput(session *models.Session, currentID uint) SessionOutput {
+	return SessionOutput{
+		ID:          session.ID,
+		DeviceLabel: session.DeviceLabel,
+		UserAgent:   session.UserAgent,
+		IP:          session.IP,
+		CreatedAt:   session.CreatedAt,
+		LastSeenAt:  session.LastSeenAt,
+		Current:     session.ID == currentID,
+	}
+}
@@ -1,32 +1,76 @@
 package dto
 
-import "github.com/plusone/models"
+import (
+	"github.com/plusone/models"
+)
 
-// RegisterInput 用户注册的输入
+// RegisterInput 用户注册的输入，用户名不能包含 @
 type RegisterInput struct {
-	Username string `json:"username" binding:"required" example:"testuser"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Username string `json:"username" binding:"required,max=50,excludes=@" example:"testuser"`
+	Password string `json:"password" binding:"required" example:"Blue-Otter-Canyon-42"`
 	Email    string `json:"email" binding:"required,email" example:"test@example.com"`
 	Nickname string `json:"nickname" example:"Tester"`
 }
 
-// LoginInput 用户登录的输入
+// LoginInput 用户登录的输入，Username 可以是用户名或邮箱
 type LoginInput struct {
 	Username string `json:"username" binding:"required" example:"testuser"`
 	Password string `json:"password" binding:"required" example:"password123"`
 }
 
 // LoginOutput 用户登录的输出
+// 启用了双因素认证时只返回 MFARequired 和 MFAToken，需要调用 /login/mfa 完成登录；
+// 使用 Cookie 会话时不返回令牌，只返回有效期和 CSRFToken
 type LoginOutput struct {
-	Token string `json:"token"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // 访问令牌有效期 (秒)
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	CSRFToken    string `json:"csrf_token,omitempty"` // 使用 Cookie 认证的写请求需在 X-CSRF-Token 头中携带
+}
+
+// LoginMFAInput 登录第二步的输入，验证码和恢复码提供其一即可
+type LoginMFAInput struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"abcde-fghij"`
+}
+
+// RefreshTokenInput 刷新令牌的输入
+// 使用 Cookie 会话时从 Cookie 读取刷新令牌，不需要请求体
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutInput 注销登录的输入
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"` // 可选，同时撤销该刷新令牌
+}
+
+// ReauthenticateInput 重新验证身份的输入，密码、验证码和恢复码提供其一即可
+type ReauthenticateInput struct {
+	Password     string `json:"password" example:"password123"`
+	Code         string `json:"code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"abcde-fghij"`
+}
+
+// ReauthenticateOutput 重新验证身份后签发的短期令牌，执行敏感操作时放在 Authorization 头中
+// 使用 Cookie 会话时令牌写入 HttpOnly Cookie，响应中不包含 Token
+type ReauthenticateOutput struct {
+	Token     string `json:"token,omitempty"`
+	ExpiresIn int64  `json:"expires_in"` // 有效期 (秒)
 }
 
 // UserOutput 用户信息的标准输出
 type UserOutput struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Nickname string `json:"nickname"`
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Nickname string   `json:"nickname"`
+	Roles    []string `json:"roles"`
+
+	EmailVerified bool `json:"email_verified"`
 }
 
 // NewUserOutput 将 models.User 转换为 UserOutput DTO
@@ -36,5 +80,8 @@ func NewUserOutput(user *models.User) UserOutput {
 		Username: user.Username,
 		Email:    user.Email,
 		Nickname: user.Nickname,
+		Roles:    user.RoleNames(),
+
+		EmailVerified: user.EmailVerified(),
 	}
 }
@@ -0,0 +1,6 @@
+package dto
+
+// FieldErrorsOutput 字段级的校验错误，键为请求中的字段名，值为该字段不满足的各项要求
+type FieldErrorsOutput struct {
+	Errors map[string][]string `json:"errors"`
+}
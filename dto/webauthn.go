@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/plusone/models"
+)
+
+// WebAuthnLoginBeginInput 开始通行密钥登录的输入
+type WebAuthnLoginBeginInput struct {
+	Username string `json:"username" example:"testuser"` // 留空表示使用可发现凭据登录
+}
+
+// WebAuthnLoginBeginOutput 开始通行密钥登录的输出
+type WebAuthnLoginBeginOutput struct {
+	SessionID string                        `json:"session_id"` // 完成登录时原样提交
+	Options   *protocol.CredentialAssertion `json:"options"`    // 传给 navigator.credentials.get()
+}
+
+// WebAuthnCredentialOutput WebAuthn 凭据信息的标准输出
+type WebAuthnCredentialOutput struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"` // 是否为可同步的通行密钥
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NewWebAuthnCredentialOutput 将 models.WebAuthnCredential 转换为 WebAuthnCredentialOutput DTO
+func NewWebAuthnCredentialOutput(credential *models.WebAuthnCredential) WebAuthnCredentialOutput {
+	return WebAuthnCredentialOutput{
+		ID:             credential.ID,
+		Name:           credential.Name,
+		Transports:     credential.TransportList(),
+		BackupEligible: credential.BackupEligible,
+		LastUsedAt:     credential.LastUsedAt,
+		CreatedAt:      credential.CreatedAt,
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 
+	"github.com/crewjam/saml"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/plusone/config"
 	"github.com/plusone/di"
 	_ "github.com/plusone/docs" // 引入生成的 docs
 	"github.com/plusone/models"
+	"github.com/plusone/repositories"
 	"github.com/plusone/routes"
 	"github.com/plusone/utils"
 	"github.com/plusone/utils/logger"
+	"github.com/plusone/utils/mailer"
 )
 
 // @title PlusOne API
@@ -55,18 +60,90 @@ func main() {
 	slog.Info("Redis 连接成功")
 
 	// 自动迁移表结构
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.PersonalAccessToken{}, &models.ServiceAccount{}, &models.ApiKey{}, &models.RecoveryCode{}, &models.WebAuthnCredential{}, &models.PasswordResetToken{}, &models.Session{}, &models.ImpersonationLog{}, &models.OAuthClient{}, &models.OAuthConsent{}, &models.Identity{}); err != nil {
 		slog.Error("数据库迁移失败", "error", err)
 		return
 	}
 	slog.Info("数据库迁移完成")
 
+	// 加载 JWT 密钥环
+	keyring, err := utils.LoadKeyring(cfg.JWTAlgorithm, cfg.JWTSecret, cfg.JWTSigningKeyFile, cfg.JWTSigningKeyID, cfg.JWTVerificationKeys)
+	if err != nil {
+		slog.Error("加载 JWT 密钥失败", "error", err)
+		return
+	}
+	slog.Info("JWT 密钥加载成功", "algorithms", keyring.Algorithms())
+
+	// 初始化 WebAuthn 依赖方
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnRPOrigins,
+	})
+	if err != nil {
+		slog.Error("初始化 WebAuthn 失败", "error", err)
+		return
+	}
+
+	// 初始化 SAML 服务提供方
+	var samlSP *saml.ServiceProvider
+	if cfg.SAMLEnabled {
+		samlSP, err = utils.NewSAMLServiceProvider(utils.SAMLServiceProviderOptions{
+			EntityID:          cfg.SAMLEntityID,
+			BaseURL:           cfg.OIDCIssuer,
+			CertFile:          cfg.SAMLCertFile,
+			KeyFile:           cfg.SAMLKeyFile,
+			IDPMetadataFile:   cfg.SAMLIDPMetadataFile,
+			AllowIDPInitiated: cfg.SAMLAllowIDPInitiated,
+		})
+		if err != nil {
+			slog.Error("初始化 SAML 失败", "error", err)
+			return
+		}
+		slog.Info("SAML 服务提供方初始化完成", "entityID", cfg.SAMLEntityID)
+	}
+
+	// 初始化邮件发送器
+	mail, err := mailer.New(cfg.MailDriver, cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	if err != nil {
+		slog.Error("初始化邮件发送器失败", "error", err)
+		return
+	}
+
 	// 初始化依赖注入容器
-	container := di.NewContainer(db, cfg.JWTSecret, redisClient)
+	container := di.NewContainer(cfg, db, keyring, relyingParty, samlSP, redisClient, mail)
 	slog.Info("依赖注入容器初始化完成")
 
+	// 回填规范化的用户名和邮箱，冲突的用户仍可使用原始用户名登录，需要人工处理
+	conflicts, err := container.UserService.BackfillNormalizedIdentities(context.Background())
+	if err != nil {
+		slog.Error("迁移用户标识失败", "error", err)
+		return
+	}
+	for _, conflict := range conflicts {
+		if conflict.Kind == repositories.IdentityConflictUsernameAt {
+			slog.Warn("用户名包含 @，只能使用邮箱登录，请为用户改名", "value", conflict.Value, "userIDs", conflict.UserIDs)
+			continue
+		}
+		slog.Warn("用户标识规范化后重复", "field", conflict.Field, "value", conflict.Value, "keptUserID", conflict.KeptUserID, "conflictUserIDs", conflict.UserIDs)
+	}
+
+	// 初始化内置角色，并在没有管理员时创建第一个管理员
+	if err := container.RoleService.EnsureDefaults(context.Background()); err != nil {
+		slog.Error("初始化角色失败", "error", err)
+		return
+	}
+	if err := container.RoleService.BootstrapAdmin(context.Background(), cfg.BootstrapAdminUsername, cfg.BootstrapAdminPassword, cfg.BootstrapAdminEmail); err != nil {
+		slog.Error("创建初始管理员失败", "error", err)
+		return
+	}
+
 	// 设置路由
-	router := routes.SetupRouter(container, cfg.JWTSecret)
+	router, err := routes.SetupRouter(container, cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("配置路由失败", "error", err)
+		return
+	}
 	slog.Info("路由配置完成")
 
 	// 启动服务器
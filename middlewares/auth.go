@@ -6,38 +6,107 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/plusone/response"
+	"github.com/plusone/services"
 	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
 )
 
 // Auth 认证中间件
-func Auth(jwtSecret string) gin.HandlerFunc {
+// 接受 X-API-Key 头中的服务 API Key，或 Authorization 头中的 JWT 访问令牌和个人访问令牌；
+// 启用了 Cookie 会话时，没有 Authorization 头的请求使用 Cookie 中的访问令牌，写请求还需要通过 CSRF 校验
+func Auth(authService *services.AuthService, cookies *utils.SessionCookies) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			response.Error(c, errors.New("未提供认证令牌"))
-			c.Abort()
+		// 服务间调用使用 API Key
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			principal, err := authService.AuthenticateAPIKey(c.Request.Context(), apiKey)
+			if err != nil {
+				logger.CtxWarnf(c.Request.Context(), "API Key 验证失败: %v", err)
+				response.Error(c, err)
+				c.Abort()
+				return
+			}
+			setPrincipal(c, principal)
+			c.Next()
 			return
 		}
 
-		// 检查Bearer前缀
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			response.Error(c, errors.New("认证格式无效"))
+		var tokenString string
+		fromCookie := false
+		authHeader := c.GetHeader("Authorization")
+		switch {
+		case authHeader != "":
+			// 检查Bearer前缀
+			parts := strings.SplitN(authHeader, " ", 2)
+			if !(len(parts) == 2 && parts[0] == "Bearer") {
+				response.Error(c, errors.New("认证格式无效"))
+				c.Abort()
+				return
+			}
+			tokenString = parts[1]
+		case cookies != nil && cookies.AccessToken(c.Request) != "":
+			tokenString = cookies.AccessToken(c.Request)
+			fromCookie = true
+		default:
+			response.Error(c, errors.New("未提供认证令牌"))
 			c.Abort()
 			return
 		}
 
 		// 验证令牌
-		tokenString := parts[1]
-		claims, err := utils.ValidateToken(tokenString, jwtSecret)
+		// 过期、尚未生效、受众不匹配、会话已撤销等情况会返回不同的错误原因
+		principal, err := authService.AuthenticateBearer(c.Request.Context(), tokenString)
 		if err != nil {
-			response.Error(c, errors.New("无效的令牌"))
+			logger.CtxWarnf(c.Request.Context(), "令牌验证失败: %v", err)
+			response.Error(c, err)
 			c.Abort()
 			return
 		}
 
-		// 将用户ID存储在上下文中
-		c.Set("userID", claims.UserID)
+		// 浏览器会自动携带 Cookie，写请求必须带上与 Cookie 一致且属于当前会话的 CSRF 令牌
+		if fromCookie && !utils.IsSafeMethod(c.Request.Method) {
+			var sessionID uint
+			if principal.Claims != nil {
+				sessionID = principal.Claims.SessionID
+			}
+			if sessionID == 0 {
+				response.Error(c, utils.ErrCSRFTokenInvalid)
+				c.Abort()
+				return
+			}
+			if err := cookies.VerifyCSRF(c.Request, sessionID); err != nil {
+				logger.CtxWarnf(c.Request.Context(), "CSRF 校验失败, userID: %d, method: %s, path: %s", principal.UserID, c.Request.Method, c.Request.URL.Path)
+				response.Error(c, err)
+				c.Abort()
+				return
+			}
+		}
+
+		// 更新会话活跃时间失败不影响本次请求
+		if err := authService.TouchSession(c.Request.Context(), principal, c.ClientIP()); err != nil {
+			logger.CtxWarnf(c.Request.Context(), "更新会话活跃时间失败: %v", err)
+		}
+
+		// 模拟登录时在日志中标记实际操作的管理员
+		if principal.Claims != nil && principal.Claims.ActorID != 0 {
+			c.Request = c.Request.WithContext(logger.WithActorID(c.Request.Context(), principal.Claims.ActorID))
+		}
+
+		setPrincipal(c, principal)
 		c.Next()
 	}
 }
+
+// setPrincipal 将调用方信息存储在上下文中
+// scopes 为 nil 表示不受作用域限制，claims 仅在使用 JWT 认证时存在
+func setPrincipal(c *gin.Context, principal *services.Principal) {
+	c.Set("principal", principal)
+	c.Set("userID", principal.UserID)
+	c.Set("serviceAccountID", principal.ServiceAccountID)
+	c.Set("roles", principal.Roles)
+	c.Set("permissions", principal.Permissions)
+	c.Set("scopes", principal.Scopes)
+	if principal.Claims != nil {
+		c.Set("claims", principal.Claims)
+		c.Set("actorID", principal.Claims.ActorID)
+	}
+}
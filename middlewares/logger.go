@@ -37,8 +37,8 @@ func LoggerMiddleware() gin.HandlerFunc {
 		// 执行时间
 		latency := end.Sub(start)
 
-		// 记录请求日志
-		slogWithTrace.Info("request handled",
+		// 记录请求日志，使用 context 中的 logger 以带上认证后补充的字段 (如 actor_id)
+		logger.FromContext(c.Request.Context()).Info("request handled",
 			"method", c.Request.Method,
 			"path", c.Request.URL.Path,
 			"status", c.Writer.Status(),
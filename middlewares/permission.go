@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/response"
+	"github.com/plusone/services"
+	"github.com/plusone/utils/logger"
+)
+
+// RequirePermission 权限守卫中间件，必须放在 Auth 之后
+// 当前用户的角色中没有指定权限时拒绝访问
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(c.GetStringSlice("permissions"), permission) {
+			logger.CtxWarnf(c.Request.Context(), "权限不足, userID: %d, 需要权限: %s", c.GetUint("userID"), permission)
+			response.Error(c, errors.New("权限不足"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope 作用域守卫中间件，必须放在 Auth 之后
+// 登录会话签发的访问令牌不受作用域限制，个人访问令牌必须被授予指定作用域
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("principal")
+		principal, ok := value.(*services.Principal)
+		if !ok || !principal.HasScope(scope) {
+			logger.CtxWarnf(c.Request.Context(), "令牌作用域不足, userID: %d, 需要作用域: %s", c.GetUint("userID"), scope)
+			response.Error(c, errors.New("令牌作用域不足"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUserSession 要求调用方使用登录会话签发的访问令牌，必须放在 Auth 之后
+// 用于双因素认证等只能由用户本人在登录状态下操作的接口，个人访问令牌、OAuth 令牌和 API Key 均不被接受
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("principal")
+		principal, ok := value.(*services.Principal)
+		if !ok || principal.Claims == nil || principal.Claims.ClientID != "" || principal.UserID == 0 {
+			response.Error(c, errors.New("该操作需要使用登录会话"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// DenyImpersonation 拒绝模拟登录令牌访问，必须放在 Auth 之后
+// 用于修改密码、更换邮箱等只能由用户本人执行的敏感操作
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actorID := c.GetUint("actorID"); actorID != 0 {
+			logger.CtxWarnf(c.Request.Context(), "模拟登录期间尝试执行敏感操作, actor: %d, userID: %d, path: %s", actorID, c.GetUint("userID"), c.FullPath())
+			response.Error(c, services.ErrImpersonationForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRecentAuth 要求调用方在 maxAge 内验证过身份，必须放在 Auth 之后
+// 用于修改密码、更换邮箱、注销账号等敏感操作；超时后需调用 /api/user/reauthenticate 换取短期令牌再重试
+func RequireRecentAuth(maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("principal")
+		principal, ok := value.(*services.Principal)
+		if !ok || principal.Claims == nil || principal.Claims.AuthTime == 0 ||
+			time.Since(time.Unix(principal.Claims.AuthTime, 0)) > maxAge {
+			logger.CtxInfof(c.Request.Context(), "需要重新验证身份, userID: %d, path: %s", c.GetUint("userID"), c.FullPath())
+			response.Error(c, services.ErrReauthenticationRequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Identity 用户关联的外部身份，(Provider, Subject) 唯一确定外部身份提供方中的一个账号
+type Identity struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email       string     `gorm:"size:100" json:"email"`    // 关联时提供方返回的邮箱，仅用于展示
+	Username    string     `gorm:"size:100" json:"username"` // 关联时提供方返回的用户名，仅用于展示
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
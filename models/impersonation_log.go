@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImpersonationLog 管理员模拟用户登录的审计记录
+// 每次模拟签发一个访问令牌，TokenID 为该令牌的 jti；EndedAt 为空表示尚未主动结束
+type ImpersonationLog struct {
+	gorm.Model
+	ActorID      uint       `gorm:"not null;index" json:"actor_id"`
+	TargetUserID uint       `gorm:"not null;index" json:"target_user_id"`
+	Reason       string     `gorm:"size:500;not null" json:"reason"`
+	TokenID      string     `gorm:"size:36;not null;uniqueIndex" json:"-"`
+	IP           string     `gorm:"size:45" json:"ip"`
+	UserAgent    string     `gorm:"size:500" json:"user_agent"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	EndedAt      *time.Time `json:"ended_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// OAuth 2.0 授权类型
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OpenID Connect 作用域
+const (
+	ScopeOpenID  = "openid"  // 签发 ID Token
+	ScopeProfile = "profile" // preferred_username、name
+	ScopeEmail   = "email"   // email、email_verified
+)
+
+// OAuthClient 第三方应用在 OAuth 2.0 授权服务器上注册的客户端
+// SecretHash 为空表示公开客户端 (单页应用、移动应用等无法保存密钥的客户端)，只能使用带 PKCE 的授权码模式
+type OAuthClient struct {
+	gorm.Model
+	ClientID     string `gorm:"size:64;not null;uniqueIndex" json:"client_id"`
+	SecretHash   string `gorm:"size:64" json:"-"`
+	Name         string `gorm:"size:100;not null" json:"name"`
+	RedirectURIs string `gorm:"size:2000" json:"redirect_uris"` // 空格分隔，授权时必须完全匹配其中之一
+	Scopes       string `gorm:"size:500" json:"scopes"`         // 空格分隔，客户端可以申请的作用域上限
+	GrantTypes   string `gorm:"size:200" json:"grant_types"`    // 空格分隔
+}
+
+// Confidential 判断客户端是否为持有密钥的机密客户端
+func (c *OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// RedirectURIList 返回已注册的回调地址
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// ScopeList 返回客户端可以申请的作用域
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// GrantTypeList 返回客户端允许使用的授权类型
+func (c *OAuthClient) GrantTypeList() []string {
+	return strings.Fields(c.GrantTypes)
+}
+
+// AllowsGrantType 判断客户端是否允许使用指定的授权类型
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	return slices.Contains(c.GrantTypeList(), grantType)
+}
+
+// OAuthConsent 用户对客户端的授权记录，再次授权相同或更小范围的作用域时无需重新确认
+type OAuthConsent struct {
+	gorm.Model
+	UserID   uint        `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"user_id"`
+	ClientID uint        `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"client_id"`
+	Client   OAuthClient `json:"client"`
+	Scopes   string      `gorm:"size:500" json:"scopes"` // 空格分隔
+}
+
+// ScopeList 返回用户已授权的作用域
+func (c *OAuthConsent) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken 重置密码的一次性令牌，只保存哈希
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// Expired 判断令牌是否已过期
+func (t *PasswordResetToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌可授予的自助类作用域，其余作用域必须是用户已拥有的权限
+const (
+	ScopeUserRead    = "user:read"    // 读取自己的用户信息
+	ScopeTokensWrite = "tokens:write" // 管理自己的个人访问令牌
+)
+
+// PersonalAccessTokenPrefix 个人访问令牌的固定前缀，用于与 JWT 区分
+const PersonalAccessTokenPrefix = "pat_"
+
+// PersonalAccessToken 个人访问令牌模型，数据库中只保存令牌的哈希
+// 撤销令牌即软删除记录
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"size:500" json:"scopes"` // 逗号分隔
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// ScopeList 返回令牌的作用域列表
+func (t *PersonalAccessToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// Expired 判断令牌是否已过期
+func (t *PersonalAccessToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
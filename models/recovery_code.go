@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode 双因素认证的一次性恢复码，只保存哈希
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "gorm.io/gorm"
+
+// 内置角色
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// 内置权限
+const (
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+	PermRolesRead  = "roles:read"
+	PermRolesWrite = "roles:write"
+
+	PermUsersImpersonate = "users:impersonate"
+
+	PermServiceAccountsWrite = "service_accounts:write"
+	PermOAuthClientsWrite    = "oauth_clients:write"
+)
+
+// Role 角色模型
+type Role struct {
+	gorm.Model
+	Name        string       `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Description string       `gorm:"size:200" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+}
+
+// Permission 权限模型，Code 形如 "users:write"
+type Permission struct {
+	gorm.Model
+	Code        string `gorm:"size:100;not null;uniqueIndex" json:"code"`
+	Description string `gorm:"size:200" json:"description"`
+}
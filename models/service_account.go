@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiKeyPrefix 服务 API Key 的固定前缀
+const ApiKeyPrefix = "pk_"
+
+// ServiceAccount 服务账号模型，代表调用本系统的内部服务而不是自然人
+// 服务账号通过角色获得权限，通过 API Key 认证
+type ServiceAccount struct {
+	gorm.Model
+	Name        string   `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string   `gorm:"size:200" json:"description"`
+	Roles       []Role   `gorm:"many2many:service_account_roles" json:"roles"`
+	ApiKeys     []ApiKey `json:"-"`
+}
+
+// ApiKey 服务 API Key 模型
+// 明文形如 "pk_<prefix>_<secret>"，按 Prefix 查找后比对整个 Key 的哈希，数据库中不保存明文
+type ApiKey struct {
+	gorm.Model
+	ServiceAccountID uint       `gorm:"not null;index" json:"service_account_id"`
+	Name             string     `gorm:"size:100;not null" json:"name"`
+	Prefix           string     `gorm:"size:16;not null;uniqueIndex" json:"prefix"`
+	KeyHash          string     `gorm:"size:64;not null" json:"-"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+}
+
+// Expired 判断 API Key 是否已过期
+func (k *ApiKey) Expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+// RoleNames 返回服务账号拥有的角色名，需要预加载 Roles
+func (a *ServiceAccount) RoleNames() []string {
+	names := make([]string, 0, len(a.Roles))
+	for _, role := range a.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// PermissionCodes 返回服务账号所有角色的权限并集，需要预加载 Roles.Permissions
+func (a *ServiceAccount) PermissionCodes() []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, role := range a.Roles {
+		for _, perm := range role.Permissions {
+			if !seen[perm.Code] {
+				seen[perm.Code] = true
+				codes = append(codes, perm.Code)
+			}
+		}
+	}
+	return codes
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session 用户的一次登录会话，对应一个刷新令牌族
+// 访问令牌通过 sid 声明引用会话，会话撤销后其访问令牌和刷新令牌随即失效
+type Session struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID    string     `gorm:"size:36;not null;uniqueIndex" json:"-"` // 刷新令牌族ID
+	DeviceLabel string     `gorm:"size:100" json:"device_label"`
+	UserAgent   string     `gorm:"size:500" json:"user_agent"`
+	IP          string     `gorm:"size:45" json:"ip"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
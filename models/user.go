@@ -1,7 +1,9 @@
 package models
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"time"
+
+	"github.com/plusone/utils"
 	"gorm.io/gorm"
 )
 
@@ -9,23 +11,83 @@ import (
 type User struct {
 	gorm.Model
 	Username string `gorm:"size:50;not null;uniqueIndex" json:"username"`
-	Password string `gorm:"size:100;not null" json:"-"` // 不在JSON中显示密码
+	Password string `gorm:"size:255;not null" json:"-"` // 自描述的密码哈希，不在JSON中显示密码
 	Email    string `gorm:"size:100;uniqueIndex" json:"email"`
 	Nickname string `gorm:"size:50" json:"nickname"`
+	Roles    []Role `gorm:"many2many:user_roles" json:"roles"`
+
+	// 规范化 (NFKC、小写) 的用户名和邮箱，唯一索引保证不区分大小写也不重复
+	// 为空表示升级前已存在且与其他用户冲突的记录，只能按原始用户名精确匹配
+	NormalizedUsername *string `gorm:"size:50;uniqueIndex" json:"-"`
+	NormalizedEmail    *string `gorm:"size:100;uniqueIndex" json:"-"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // 为空表示邮箱尚未验证
+
+	// TOTP 双因素认证，TOTPSecret 非空但 TOTPEnabledAt 为空表示正在绑定中
+	TOTPSecret    string     `gorm:"size:64" json:"-"`
+	TOTPEnabledAt *time.Time `json:"-"`
 }
 
-// SetPassword 设置加密后的密码
-func (u *User) SetPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// SetPassword 使用配置的哈希算法设置密码
+func (u *User) SetPassword(hasher *utils.PasswordHasher, password string) error {
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
 
-// CheckPassword 检查密码是否正确
+// CheckPassword 检查密码是否正确，按哈希值中记录的算法校验
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	return utils.VerifyPassword(u.Password, password)
+}
+
+// SetNormalizedIdentities 根据用户名和邮箱设置规范化的标识，邮箱为空时不占用唯一索引
+func (u *User) SetNormalizedIdentities() {
+	username := utils.NormalizeIdentity(u.Username)
+	u.NormalizedUsername = &username
+	u.NormalizedEmail = nil
+	if email := utils.NormalizeIdentity(u.Email); email != "" {
+		u.NormalizedEmail = &email
+	}
+}
+
+// HasPassword 判断用户是否设置了密码，通过外部身份创建的账号没有密码
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+// RoleNames 返回用户拥有的角色名，需要预加载 Roles
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// PermissionCodes 返回用户所有角色的权限并集，需要预加载 Roles.Permissions
+func (u *User) PermissionCodes() []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, role := range u.Roles {
+		for _, perm := range role.Permissions {
+			if !seen[perm.Code] {
+				seen[perm.Code] = true
+				codes = append(codes, perm.Code)
+			}
+		}
+	}
+	return codes
+}
+
+// EmailVerified 判断用户的邮箱是否已验证
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// TOTPEnabled 判断用户是否已启用 TOTP 双因素认证
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
 }
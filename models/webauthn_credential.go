@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredential 用户注册的 WebAuthn 凭据 (通行密钥或安全密钥)
+// 只保存公钥和签名计数器，私钥始终留在认证器中
+type WebAuthnCredential struct {
+	gorm.Model
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	CredentialID    string     `gorm:"size:255;not null;uniqueIndex" json:"credential_id"` // base64url 编码
+	PublicKey       []byte     `gorm:"not null" json:"-"`                                  // COSE 编码的公钥
+	AttestationType string     `gorm:"size:32" json:"attestation_type"`
+	AAGUID          []byte     `gorm:"size:16" json:"-"`
+	Transports      string     `gorm:"size:100" json:"transports"` // 逗号分隔
+	SignCount       uint32     `json:"sign_count"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+// TransportList 返回凭据支持的传输方式
+func (c *WebAuthnCredential) TransportList() []string {
+	if c.Transports == "" {
+		return []string{}
+	}
+	return strings.Split(c.Transports, ",")
+}
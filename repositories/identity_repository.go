@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// IdentityRepository 外部身份数据访问层
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository 创建外部身份仓库实例
+func NewIdentityRepository(db *gorm.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// Create 保存新关联的外部身份
+func (r *IdentityRepository) Create(ctx context.Context, identity *models.Identity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// FindByProviderSubject 通过提供方和外部账号标识查找外部身份
+func (r *IdentityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	return &identity, err
+}
+
+// FindByUser 查找属于指定用户的外部身份
+func (r *IdentityRepository) FindByUser(ctx context.Context, userID, id uint) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&identity, id).Error
+	return &identity, err
+}
+
+// ListByUser 列出用户关联的全部外部身份
+func (r *IdentityRepository) ListByUser(ctx context.Context, userID uint) ([]models.Identity, error) {
+	var identities []models.Identity
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// CountByUser 统计用户关联的外部身份数量
+func (r *IdentityRepository) CountByUser(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Identity{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// UpdateLastLogin 记录通过外部身份登录的时间
+func (r *IdentityRepository) UpdateLastLogin(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Identity{}).Where("id = ?", id).UpdateColumn("last_login_at", at).Error
+}
+
+// DeleteByUser 删除属于指定用户的外部身份，返回受影响的行数
+// 使用硬删除，解除关联后同一外部账号可以重新关联
+func (r *IdentityRepository) DeleteByUser(ctx context.Context, userID, id uint) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.Identity{}, id)
+	return result.RowsAffected, result.Error
+}
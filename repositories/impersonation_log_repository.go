@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// ImpersonationLogRepository 模拟登录审计记录数据访问层
+type ImpersonationLogRepository struct {
+	db *gorm.DB
+}
+
+// NewImpersonationLogRepository 创建模拟登录审计记录仓库实例
+func NewImpersonationLogRepository(db *gorm.DB) *ImpersonationLogRepository {
+	return &ImpersonationLogRepository{db: db}
+}
+
+// Create 创建审计记录
+func (r *ImpersonationLogRepository) Create(ctx context.Context, log *models.ImpersonationLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+// FindByTokenID 通过令牌 jti 查找审计记录
+func (r *ImpersonationLogRepository) FindByTokenID(ctx context.Context, tokenID string) (*models.ImpersonationLog, error) {
+	var log models.ImpersonationLog
+	err := r.db.WithContext(ctx).Where("token_id = ?", tokenID).First(&log).Error
+	return &log, err
+}
+
+// MarkEnded 记录模拟结束时间，返回受影响的行数
+func (r *ImpersonationLogRepository) MarkEnded(ctx context.Context, id uint, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.ImpersonationLog{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		UpdateColumn("ended_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// List 按时间倒序列出审计记录，actorID 或 targetUserID 为 0 时不作为过滤条件
+func (r *ImpersonationLogRepository) List(ctx context.Context, actorID, targetUserID uint, limit int) ([]models.ImpersonationLog, error) {
+	query := r.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if actorID != 0 {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if targetUserID != 0 {
+		query = query.Where("target_user_id = ?", targetUserID)
+	}
+	var logs []models.ImpersonationLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthRepository OAuth 客户端与用户授权记录数据访问层
+type OAuthRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthRepository 创建 OAuth 仓库实例
+func NewOAuthRepository(db *gorm.DB) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+// Transaction 执行一个数据库事务
+func (r *OAuthRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// CreateClient 创建客户端
+func (r *OAuthRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	return r.db.WithContext(ctx).Create(client).Error
+}
+
+// FindClientByClientID 通过 client_id 查找客户端
+func (r *OAuthRepository) FindClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error
+	return &client, err
+}
+
+// ListClients 列出全部客户端
+func (r *OAuthRepository) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.WithContext(ctx).Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// DeleteClient 删除客户端，返回受影响的行数
+func (r *OAuthRepository) DeleteClient(ctx context.Context, id uint) (int64, error) {
+	result := r.db.WithContext(ctx).Delete(&models.OAuthClient{}, id)
+	return result.RowsAffected, result.Error
+}
+
+// FindConsent 查找用户对客户端的授权记录
+func (r *OAuthRepository) FindConsent(ctx context.Context, userID, clientID uint) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	err := r.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	return &consent, err
+}
+
+// SaveConsent 保存授权记录，已存在时更新作用域
+func (r *OAuthRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+}
+
+// ListConsentsByUser 列出用户的全部授权记录及对应客户端
+func (r *OAuthRepository) ListConsentsByUser(ctx context.Context, userID uint) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	err := r.db.WithContext(ctx).Preload("Client").Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error
+	return consents, err
+}
+
+// DeleteConsent 删除用户对客户端的授权记录，返回受影响的行数
+func (r *OAuthRepository) DeleteConsent(ctx context.Context, userID, clientID uint) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&models.OAuthConsent{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteConsentsByClient 删除客户端的全部授权记录
+func (r *OAuthRepository) DeleteConsentsByClient(ctx context.Context, clientID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("client_id = ?", clientID).Delete(&models.OAuthConsent{}).Error
+}
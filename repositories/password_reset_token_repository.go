@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// PasswordResetTokenRepository 重置密码令牌数据访问层
+type PasswordResetTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetTokenRepository 创建重置密码令牌仓库实例
+func NewPasswordResetTokenRepository(db *gorm.DB) *PasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{db: db}
+}
+
+// Transaction 执行数据库事务
+func (r *PasswordResetTokenRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 保存重置密码令牌
+func (r *PasswordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash 通过令牌哈希查找令牌
+func (r *PasswordResetTokenRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// MarkUsed 将未使用的令牌标记为已使用，返回受影响的行数
+// 通过带条件的更新保证同一令牌并发使用时只有一次成功
+func (r *PasswordResetTokenRepository) MarkUsed(ctx context.Context, id uint, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByUser 删除用户全部重置密码令牌
+func (r *PasswordResetTokenRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.PasswordResetToken{}).Error
+}
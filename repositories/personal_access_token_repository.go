@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenRepository 个人访问令牌数据访问层
+type PersonalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPersonalAccessTokenRepository 创建个人访问令牌仓库实例
+func NewPersonalAccessTokenRepository(db *gorm.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+// Create 创建个人访问令牌
+func (r *PersonalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash 通过令牌哈希查找未撤销的令牌
+func (r *PersonalAccessTokenRepository) FindByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error
+	return &token, err
+}
+
+// ListByUser 列出用户全部未撤销的令牌
+func (r *PersonalAccessTokenRepository) ListByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteByUser 撤销属于指定用户的令牌，返回受影响的行数
+func (r *PersonalAccessTokenRepository) DeleteByUser(ctx context.Context, userID, id uint) (int64, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}, id)
+	return result.RowsAffected, result.Error
+}
+
+// TouchLastUsed 更新令牌的最近使用时间
+func (r *PersonalAccessTokenRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
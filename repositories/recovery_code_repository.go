@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeRepository 恢复码数据访问层
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository 创建恢复码仓库实例
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// Replace 删除用户全部旧恢复码并保存新的恢复码
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID uint, codes []models.RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// MarkUsed 将未使用的恢复码标记为已使用，返回受影响的行数
+// 通过带条件的更新保证同一恢复码并发使用时只有一次成功
+func (r *RecoveryCodeRepository) MarkUsed(ctx context.Context, userID uint, hash string, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// CountUnused 统计用户剩余可用的恢复码数量
+func (r *RecoveryCodeRepository) CountUnused(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// RoleRepository 角色与权限数据访问层
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository 创建角色仓库实例
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// Transaction 执行数据库事务
+func (r *RoleRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// List 列出全部角色及其权限
+func (r *RoleRepository) List(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).Preload("Permissions").Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// FindByName 通过名称查找角色
+func (r *RoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error
+	return &role, err
+}
+
+// FindByNames 通过名称批量查找角色
+func (r *RoleRepository) FindByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&roles).Error
+	return roles, err
+}
+
+// FirstOrCreatePermission 按 Code 查找权限，不存在则创建
+func (r *RoleRepository) FirstOrCreatePermission(ctx context.Context, perm *models.Permission) error {
+	return r.db.WithContext(ctx).Where(models.Permission{Code: perm.Code}).Attrs(models.Permission{Description: perm.Description}).FirstOrCreate(perm).Error
+}
+
+// FirstOrCreate 按名称查找角色，不存在则创建
+func (r *RoleRepository) FirstOrCreate(ctx context.Context, role *models.Role) error {
+	return r.db.WithContext(ctx).Where(models.Role{Name: role.Name}).Attrs(models.Role{Description: role.Description}).FirstOrCreate(role).Error
+}
+
+// AddPermissions 为角色追加权限，已存在的关联会被忽略
+func (r *RoleRepository) AddPermissions(ctx context.Context, role *models.Role, perms []models.Permission) error {
+	return r.db.WithContext(ctx).Model(role).Association("Permissions").Append(perms)
+}
+
+// CountUsers 统计拥有指定角色的用户数
+func (r *RoleRepository) CountUsers(ctx context.Context, roleName string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Where("roles.name = ?", roleName).
+		Count(&count).Error
+	return count, err
+}
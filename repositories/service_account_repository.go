@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// ServiceAccountRepository 服务账号与 API Key 数据访问层
+type ServiceAccountRepository struct {
+	db *gorm.DB
+}
+
+// NewServiceAccountRepository 创建服务账号仓库实例
+func NewServiceAccountRepository(db *gorm.DB) *ServiceAccountRepository {
+	return &ServiceAccountRepository{db: db}
+}
+
+// Transaction 执行数据库事务
+func (r *ServiceAccountRepository) Transaction(fc func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fc)
+}
+
+// Create 创建服务账号
+func (r *ServiceAccountRepository) Create(ctx context.Context, account *models.ServiceAccount) error {
+	return r.db.WithContext(ctx).Create(account).Error
+}
+
+// FindByID 通过ID查找服务账号，并预加载角色和权限
+func (r *ServiceAccountRepository) FindByID(ctx context.Context, id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.WithContext(ctx).Preload("Roles.Permissions").First(&account, id).Error
+	return &account, err
+}
+
+// List 列出全部服务账号
+func (r *ServiceAccountRepository) List(ctx context.Context) ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := r.db.WithContext(ctx).Preload("Roles").Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+// CreateKey 创建 API Key
+func (r *ServiceAccountRepository) CreateKey(ctx context.Context, key *models.ApiKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// FindKeyByID 通过ID查找未撤销的 API Key
+func (r *ServiceAccountRepository) FindKeyByID(ctx context.Context, id uint) (*models.ApiKey, error) {
+	var key models.ApiKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	return &key, err
+}
+
+// FindKeyByPrefix 通过前缀查找未撤销的 API Key
+func (r *ServiceAccountRepository) FindKeyByPrefix(ctx context.Context, prefix string) (*models.ApiKey, error) {
+	var key models.ApiKey
+	err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	return &key, err
+}
+
+// ListKeys 列出服务账号全部未撤销的 API Key
+func (r *ServiceAccountRepository) ListKeys(ctx context.Context, accountID uint) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	err := r.db.WithContext(ctx).Where("service_account_id = ?", accountID).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// UpdateKeyExpiry 修改 API Key 的过期时间
+func (r *ServiceAccountRepository) UpdateKeyExpiry(ctx context.Context, id uint, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.ApiKey{}).Where("id = ?", id).Update("expires_at", expiresAt).Error
+}
+
+// DeleteKey 撤销 API Key，返回受影响的行数
+func (r *ServiceAccountRepository) DeleteKey(ctx context.Context, id uint) (int64, error) {
+	result := r.db.WithContext(ctx).Delete(&models.ApiKey{}, id)
+	return result.RowsAffected, result.Error
+}
+
+// TouchKeyLastUsed 更新 API Key 的最近使用时间
+func (r *ServiceAccountRepository) TouchKeyLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.ApiKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
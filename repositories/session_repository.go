@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// SessionRepository 登录会话数据访问层
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository 创建登录会话仓库实例
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create 创建会话
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// FindByUser 查找属于指定用户的会话
+func (r *SessionRepository) FindByUser(ctx context.Context, userID, id uint) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&session, id).Error
+	return &session, err
+}
+
+// ListActiveByUser 列出用户未撤销且在 since 之后活跃过的会话
+func (r *SessionRepository) ListActiveByUser(ctx context.Context, userID uint, since time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND last_seen_at > ?", userID, since).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListActiveIDsByUser 列出用户全部未撤销会话的ID
+func (r *SessionRepository) ListActiveIDsByUser(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// TouchLastSeen 更新会话的最近活跃时间和IP
+func (r *SessionRepository) TouchLastSeen(ctx context.Context, id uint, ip string, at time.Time) error {
+	updates := map[string]interface{}{"last_seen_at": at}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
+// Revoke 将会话标记为已撤销，返回受影响的行数
+func (r *SessionRepository) Revoke(ctx context.Context, id uint, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		UpdateColumn("revoked_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// RevokeAllByUser 将用户全部未撤销的会话标记为已撤销
+func (r *SessionRepository) RevokeAllByUser(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", at).Error
+}
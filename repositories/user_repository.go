@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/plusone/models"
+	"github.com/plusone/utils"
 	"gorm.io/gorm"
 )
 
@@ -22,8 +25,9 @@ func (r *UserRepository) Transaction(fc func(tx *gorm.DB) error) error {
 	return r.db.Transaction(fc)
 }
 
-// Create 创建新用户
+// Create 创建新用户，同时写入规范化的用户名和邮箱
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	user.SetNormalizedIdentities()
 	return r.db.WithContext(ctx).Create(user).Error
 }
 
@@ -34,14 +38,205 @@ func (r *UserRepository) FindByID(ctx context.Context, id uint) (*models.User, e
 	return &user, err
 }
 
-// FindByUsername 通过用户名查找用户
+// FindByIDWithRoles 通过ID查找用户，并预加载角色和权限
+func (r *UserRepository) FindByIDWithRoles(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Roles.Permissions").First(&user, id).Error
+	return &user, err
+}
+
+// FindByUsername 通过用户名查找用户，按规范化的用户名匹配，不区分大小写
+// 没有规范化用户名的冲突记录只能按原始用户名精确匹配，且优先于规范化匹配
 func (r *UserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	err := r.db.WithContext(ctx).
+		Where("normalized_username = ?", utils.NormalizeIdentity(username)).
+		Or("normalized_username IS NULL AND username = ?", username).
+		Order("normalized_username IS NULL DESC").
+		First(&user).Error
+	return &user, err
+}
+
+// FindByEmail 通过邮箱查找用户，按规范化的邮箱匹配，不区分大小写
+// 没有规范化邮箱的冲突记录只能按原始邮箱精确匹配，且优先于规范化匹配
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).
+		Where("normalized_email = ?", utils.NormalizeIdentity(email)).
+		Or("normalized_email IS NULL AND email = ?", email).
+		Order("normalized_email IS NULL DESC").
+		First(&user).Error
 	return &user, err
 }
 
+// FindByUsernameOrEmail 通过用户名或邮箱查找用户，包含 @ 的标识只按邮箱查找
+// 用户名不允许包含 @，升级前已存在的此类用户名不能再用于登录，避免与其他用户的邮箱混淆
+func (r *UserRepository) FindByUsernameOrEmail(ctx context.Context, identifier string) (*models.User, error) {
+	if strings.Contains(utils.NormalizeIdentity(identifier), "@") {
+		return r.FindByEmail(ctx, identifier)
+	}
+	return r.FindByUsername(ctx, identifier)
+}
+
+// UpdatePassword 更新用户的密码哈希
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID uint, hashedPassword string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+}
+
+// ReplacePassword 仅当密码哈希仍为 oldHash 时替换为 newHash，避免覆盖期间被修改的密码
+func (r *UserRepository) ReplacePassword(ctx context.Context, userID uint, oldHash, newHash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND password = ?", userID, oldHash).
+		Update("password", newHash).Error
+}
+
+// UpdateEmail 更换用户的邮箱，新邮箱需要重新验证
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID uint, email string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"email": email, "normalized_email": utils.NormalizeIdentity(email), "email_verified_at": nil}).Error
+}
+
+// UpdateEmailSpelling 修改邮箱的写法 (例如大小写)，规范化后的邮箱不变，验证状态保留
+func (r *UserRepository) UpdateEmailSpelling(ctx context.Context, userID uint, email string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"email": email, "normalized_email": utils.NormalizeIdentity(email)}).Error
+}
+
+// MarkEmailVerified 在邮箱未变更的前提下标记邮箱已验证，返回受影响的行数
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID uint, email string, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", userID, email).
+		Update("email_verified_at", at)
+	return result.RowsAffected, result.Error
+}
+
 // Update 更新用户信息
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	return r.db.WithContext(ctx).Save(user).Error
 }
+
+// ReplaceRoles 将用户的角色替换为给定的角色集合
+func (r *UserRepository) ReplaceRoles(ctx context.Context, user *models.User, roles []models.Role) error {
+	return r.db.WithContext(ctx).Model(user).Association("Roles").Replace(roles)
+}
+
+// AddRoles 为用户追加角色
+func (r *UserRepository) AddRoles(ctx context.Context, user *models.User, roles []models.Role) error {
+	return r.db.WithContext(ctx).Model(user).Association("Roles").Append(roles)
+}
+
+// UpdateTOTP 更新用户的 TOTP 密钥和启用时间
+func (r *UserRepository) UpdateTOTP(ctx context.Context, userID uint, secret string, enabledAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": enabledAt}).Error
+}
+
+// DeleteAccount 彻底删除用户及其登录凭据、会话和授权记录，用户名和邮箱随即可以重新注册
+// 模拟登录审计日志保留
+func (r *UserRepository) DeleteAccount(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owned := []interface{}{
+			&models.Identity{},
+			&models.PersonalAccessToken{},
+			&models.WebAuthnCredential{},
+			&models.RecoveryCode{},
+			&models.PasswordResetToken{},
+			&models.OAuthConsent{},
+			&models.Session{},
+		}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.User{}, userID).Error
+	})
+}
+
+// 回填规范化标识时发现的冲突类型
+const (
+	IdentityConflictDuplicate  = "duplicate"   // 多个用户的用户名或邮箱规范化后相同
+	IdentityConflictUsernameAt = "username_at" // 用户名包含 @，只能使用邮箱登录
+)
+
+// IdentityConflict 回填规范化标识时发现的需要人工处理 (例如改名或合并账号) 的用户
+// 规范化后重复时 KeptUserID 保留了规范化的值，其余用户的规范化值为空；
+// 用户名包含 @ 时 KeptUserID 为 0，每个用户名单独列出
+type IdentityConflict struct {
+	Kind       string
+	Field      string // username 或 email
+	Value      string // 规范化后的值
+	KeptUserID uint
+	UserIDs    []uint // 未能写入规范化值或用户名包含 @ 的用户
+}
+
+// BackfillNormalizedIdentities 为规范化用户名或邮箱为空的用户 (包括已软删除的) 回填规范化的值
+// 按用户ID升序处理，规范化后与已有值冲突的用户保持为空并在返回值中列出，已回填的用户不会重复处理；
+// 用户名包含 @ 的用户无论是否已回填都在返回值中列出，直到改名为止
+func (r *UserRepository) BackfillNormalizedIdentities(ctx context.Context) ([]IdentityConflict, error) {
+	var conflicts []IdentityConflict
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users []models.User
+		if err := tx.Unscoped().Order("id").Find(&users).Error; err != nil {
+			return err
+		}
+
+		// 已占用的规范化值及其所属用户
+		owners := map[string]map[string]uint{"username": {}, "email": {}}
+		for _, user := range users {
+			if user.NormalizedUsername != nil {
+				owners["username"][*user.NormalizedUsername] = user.ID
+			}
+			if user.NormalizedEmail != nil {
+				owners["email"][*user.NormalizedEmail] = user.ID
+			}
+		}
+
+		reported := map[string]int{} // field:value -> conflicts 中的下标
+		for _, user := range users {
+			if normalized := utils.NormalizeIdentity(user.Username); strings.Contains(normalized, "@") {
+				conflicts = append(conflicts, IdentityConflict{Kind: IdentityConflictUsernameAt, Field: "username", Value: normalized, UserIDs: []uint{user.ID}})
+			}
+
+			updates := map[string]interface{}{}
+			fields := []struct {
+				name    string
+				column  string
+				current *string
+				value   string
+			}{
+				{"username", "normalized_username", user.NormalizedUsername, utils.NormalizeIdentity(user.Username)},
+				{"email", "normalized_email", user.NormalizedEmail, utils.NormalizeIdentity(user.Email)},
+			}
+			for _, field := range fields {
+				if field.current != nil || field.value == "" {
+					continue
+				}
+				owner, taken := owners[field.name][field.value]
+				if !taken {
+					owners[field.name][field.value] = user.ID
+					updates[field.column] = field.value
+					continue
+				}
+				key := field.name + ":" + field.value
+				if i, ok := reported[key]; ok {
+					conflicts[i].UserIDs = append(conflicts[i].UserIDs, user.ID)
+					continue
+				}
+				reported[key] = len(conflicts)
+				conflicts = append(conflicts, IdentityConflict{Kind: IdentityConflictDuplicate, Field: field.name, Value: field.value, KeptUserID: owner, UserIDs: []uint{user.ID}})
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return conflicts, err
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCredentialRepository WebAuthn 凭据数据访问层
+type WebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnCredentialRepository 创建 WebAuthn 凭据仓库实例
+func NewWebAuthnCredentialRepository(db *gorm.DB) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{db: db}
+}
+
+// Create 保存新注册的凭据
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+// FindByCredentialID 通过凭据ID查找凭据
+func (r *WebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(&credential).Error
+	return &credential, err
+}
+
+// ListByUser 列出用户的全部凭据
+func (r *WebAuthnCredentialRepository) ListByUser(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&credentials).Error
+	return credentials, err
+}
+
+// UpdateAfterLogin 登录成功后更新签名计数器、备份状态和最近使用时间
+func (r *WebAuthnCredentialRepository) UpdateAfterLogin(ctx context.Context, id uint, signCount uint32, backupState bool, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": at,
+	}).Error
+}
+
+// DeleteByUser 删除属于指定用户的凭据，返回受影响的行数
+func (r *WebAuthnCredentialRepository) DeleteByUser(ctx context.Context, userID, id uint) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.WebAuthnCredential{}, id)
+	return result.RowsAffected, result.Error
+}
@@ -30,3 +30,12 @@ func Error(c *gin.Context, err error) {
 		Data: nil,
 	})
 }
+
+// ErrorWithData 发送一个携带附加数据的失败响应，例如字段级的校验错误
+func ErrorWithData(c *gin.Context, err error, data interface{}) {
+	c.JSON(http.StatusInternalServerError, Response{
+		Code: http.StatusInternalServerError,
+		Msg:  err.Error(),
+		Data: data,
+	})
+}
@@ -1,17 +1,27 @@
 package routes
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/plusone/di"
 	"github.com/plusone/middlewares"
+	"github.com/plusone/models"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// recentAuthMaxAge 敏感操作要求的最近验证身份时间
+const recentAuthMaxAge = 10 * time.Minute
+
 // SetupRouter 配置路由
-func SetupRouter(container *di.Container, jwtSecret string) *gin.Engine {
-	// 使用 gin.New() 创建一个不带默认中间件的引擎
-	r := gin.New()
+// trustedProxies 为可信的反向代理，为空时不采信任何 X-Forwarded-For，客户端 IP 取连接的对端地址
+func SetupRouter(container *di.Container, trustedProxies []string) (*gin.Engine, error) {
+	r, err := newEngine(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
 
 	// 全局中间件
 	// 1. 日志中间件
@@ -22,7 +32,53 @@ func SetupRouter(container *di.Container, jwtSecret string) *gin.Engine {
 	// 添加 Swagger 路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// 认证中间件，启用了 Cookie 会话时也接受 Cookie 中的访问令牌
+	authenticate := middlewares.Auth(container.AuthService, container.SessionCookies)
+
+	// 公开的 JWKS，供下游服务验证令牌签名
+	r.GET("/.well-known/jwks.json", container.JWKSController.JWKS)
+
+	// OpenID Connect 发现文档和用户信息端点
+	oidcController := container.OIDCController
+	if oidcController != nil {
+		r.GET("/.well-known/openid-configuration", oidcController.Discovery)
+		userinfo := r.Group("/userinfo", authenticate, middlewares.RequireScope(models.ScopeOpenID))
+		{
+			userinfo.GET("", oidcController.UserInfo)
+			userinfo.POST("", oidcController.UserInfo)
+		}
+	}
+
+	// SAML 2.0 单点登录，IdP 通过浏览器访问 SP 元数据、认证和断言消费端点
+	samlController := container.SAMLController
+	if samlController != nil {
+		saml := r.Group("/saml")
+		{
+			saml.GET("/metadata", samlController.Metadata)
+			saml.GET("/login", samlController.Login)
+			saml.POST("/acs", samlController.ACS)
+		}
+	}
+
+	// OAuth 2.0 授权服务器
+	// 授权确认由已登录的用户通过前端页面完成；令牌、撤销和自省端点由第三方客户端直接调用
+	oauthController := container.OAuthController
+	oauth := r.Group("/oauth")
+	{
+		oauth.POST("/token", oauthController.Token)
+		oauth.POST("/revoke", oauthController.Revoke)
+		oauth.POST("/introspect", oauthController.Introspect)
+
+		// 浏览器直接访问授权端点时跳转到前端授权确认页
+		authorize := oauth.Group("/authorize", oauthController.RedirectToConsent, authenticate, middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+		{
+			authorize.GET("", oauthController.Authorize)
+			authorize.POST("", oauthController.Decide)
+		}
+	}
+
 	userController := container.UserController
+	impersonationController := container.ImpersonationController
 
 	// API组
 	api := r.Group("/api")
@@ -30,14 +86,164 @@ func SetupRouter(container *di.Container, jwtSecret string) *gin.Engine {
 		// 公开路由
 		api.POST("/register", userController.Register)
 		api.POST("/login", userController.Login)
+		api.POST("/login/mfa", userController.LoginMFA)
+		api.POST("/token/refresh", userController.RefreshToken)
+
+		// 找回密码
+		passwordController := container.PasswordController
+		api.POST("/password/forgot", passwordController.Forgot)
+		api.POST("/password/reset", passwordController.Reset)
+
+		// 邮箱验证
+		emailVerificationController := container.EmailVerificationController
+		api.GET("/email/verify", emailVerificationController.Verify)
+		api.POST("/email/verify/resend", emailVerificationController.Resend)
+
+		// 外部身份提供方登录
+		externalLoginController := container.ExternalLoginController
+		api.GET("/auth/providers", externalLoginController.Providers)
+		api.GET("/auth/:provider/login", externalLoginController.Login)
+		api.POST("/auth/:provider/callback", externalLoginController.Callback)
+		if samlController != nil {
+			api.POST("/saml/exchange", samlController.Exchange)
+		}
+
+		// WebAuthn 通行密钥：登录为公开路由，注册和管理凭据需要登录会话，注册新的通行密钥还要求最近验证过身份
+		webAuthnController := container.WebAuthnController
+		webAuthn := api.Group("/webauthn")
+		{
+			webAuthn.POST("/login/begin", webAuthnController.BeginLogin)
+			webAuthn.POST("/login/finish", webAuthnController.FinishLogin)
+
+			credentials := webAuthn.Group("", authenticate, middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				register := credentials.Group("/register", middlewares.RequireRecentAuth(recentAuthMaxAge))
+				register.POST("/begin", webAuthnController.BeginRegistration)
+				register.POST("/finish", webAuthnController.FinishRegistration)
+				credentials.GET("/credentials", webAuthnController.ListCredentials)
+				credentials.DELETE("/credentials/:id", webAuthnController.DeleteCredential)
+			}
+		}
 
 		// 需要认证的路由
 		auth := api.Group("/user")
-		auth.Use(middlewares.Auth(jwtSecret))
+		auth.Use(authenticate)
 		{
-			auth.GET("/info", userController.GetUserInfo)
+			auth.GET("/info", middlewares.RequireScope(models.ScopeUserRead), userController.GetUserInfo)
+
+			// 注销、修改密码、更换邮箱和注销账号只能由用户本人的登录会话操作，API Key、客户端凭证令牌和模拟登录令牌都不可用；
+			// 修改密码、更换邮箱和注销账号还要求最近验证过身份，超时后需先重新验证身份换取短期令牌
+			account := auth.Group("", middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				account.POST("/logout", userController.Logout)
+				account.POST("/logout-all", userController.LogoutAll)
+				account.POST("/reauthenticate", userController.Reauthenticate)
+
+				recent := account.Group("", middlewares.RequireRecentAuth(recentAuthMaxAge))
+				recent.PUT("/password", userController.ChangePassword)
+				recent.PUT("/email", userController.ChangeEmail)
+				recent.DELETE("", userController.DeleteAccount)
+			}
+
+			// 管理员结束对当前用户的模拟登录
+			auth.POST("/impersonation/stop", impersonationController.Stop)
+
+			// 个人访问令牌管理
+			patController := container.PersonalAccessTokenController
+			tokens := auth.Group("/tokens", middlewares.RequireScope(models.ScopeTokensWrite), middlewares.DenyImpersonation())
+			{
+				tokens.POST("", patController.Create)
+				tokens.GET("", patController.List)
+				tokens.DELETE("/:id", patController.Revoke)
+			}
+
+			// 登录会话与设备管理，模拟登录期间不能查看或撤销被模拟用户的会话
+			sessionController := container.SessionController
+			sessions := auth.Group("/sessions", middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				sessions.GET("", sessionController.List)
+				sessions.DELETE("/:id", sessionController.Revoke)
+			}
+
+			// 已授权的第三方应用管理
+			consents := auth.Group("/oauth/consents", middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				consents.GET("", oauthController.ListConsents)
+				consents.DELETE("/:client_id", oauthController.RevokeConsent)
+			}
+
+			// 关联的外部账号管理，关联新的外部账号要求最近验证过身份
+			identities := auth.Group("/identities", middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				identities.GET("", externalLoginController.ListIdentities)
+				link := identities.Group("/:provider", middlewares.RequireRecentAuth(recentAuthMaxAge))
+				link.POST("", externalLoginController.BeginLink)
+				link.POST("/callback", externalLoginController.FinishLink)
+				identities.DELETE("/:id", externalLoginController.Unlink)
+			}
+
+			// 双因素认证管理，只能使用登录会话操作；停用 TOTP 和重新生成恢复码要求最近验证过身份
+			mfaController := container.MFAController
+			mfa := auth.Group("/mfa", middlewares.RequireUserSession(), middlewares.DenyImpersonation())
+			{
+				mfa.POST("/totp/enroll", mfaController.EnrollTOTP)
+				mfa.POST("/totp/confirm", mfaController.ConfirmTOTP)
+				mfaRecent := mfa.Group("", middlewares.RequireRecentAuth(recentAuthMaxAge))
+				mfaRecent.POST("/totp/disable", mfaController.DisableTOTP)
+				mfaRecent.POST("/recovery-codes", mfaController.RegenerateRecoveryCodes)
+			}
+		}
+
+		// 管理员路由，按权限分组
+		adminController := container.AdminController
+		admin := api.Group("/admin")
+		admin.Use(authenticate)
+		{
+			admin.GET("/roles", middlewares.RequirePermission(models.PermRolesRead), adminController.ListRoles)
+
+			users := admin.Group("/users")
+			{
+				users.GET("/:id", middlewares.RequirePermission(models.PermUsersRead), adminController.GetUser)
+				users.PUT("/:id/roles", middlewares.RequirePermission(models.PermRolesWrite), adminController.AssignRoles)
+				users.POST("/:id/unlock", middlewares.RequirePermission(models.PermUsersWrite), adminController.UnlockUser)
+				users.POST("/:id/impersonate", middlewares.RequirePermission(models.PermUsersImpersonate), middlewares.RequireUserSession(), middlewares.DenyImpersonation(), impersonationController.Start)
+			}
+			admin.GET("/impersonations", middlewares.RequirePermission(models.PermUsersRead), impersonationController.List)
+
+			// 服务账号与 API Key 管理
+			serviceAccountController := container.ServiceAccountController
+			serviceAccounts := admin.Group("", middlewares.RequirePermission(models.PermServiceAccountsWrite))
+			{
+				serviceAccounts.POST("/service-accounts", serviceAccountController.Create)
+				serviceAccounts.GET("/service-accounts", serviceAccountController.List)
+				serviceAccounts.POST("/service-accounts/:id/keys", serviceAccountController.IssueKey)
+				serviceAccounts.GET("/service-accounts/:id/keys", serviceAccountController.ListKeys)
+				serviceAccounts.POST("/api-keys/:id/rotate", serviceAccountController.RotateKey)
+				serviceAccounts.DELETE("/api-keys/:id", serviceAccountController.RevokeKey)
+			}
+
+			// OAuth 客户端管理
+			oauthClientController := container.OAuthClientController
+			oauthClients := admin.Group("/oauth/clients", middlewares.RequirePermission(models.PermOAuthClientsWrite))
+			{
+				oauthClients.POST("", oauthClientController.Create)
+				oauthClients.GET("", oauthClientController.List)
+				oauthClients.DELETE("/:id", oauthClientController.Delete)
+			}
 		}
 	}
 
-	return r
+	return r, nil
+}
+
+// newEngine 创建不带默认中间件的引擎
+// Gin 默认信任所有代理，客户端可以通过伪造 X-Forwarded-For 绕过按 IP 的登录限制，因此必须显式设置可信代理
+func newEngine(trustedProxies []string) (*gin.Engine, error) {
+	r := gin.New()
+	// 让 gin.Context 作为 context.Context 使用时回退到 Request 的 context，控制器日志才能带上 trace_id 等字段
+	r.ContextWithFallback = true
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("设置可信代理失败: %w", err)
+	}
+	return r, nil
 }
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/plusone/services"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewEngineClientIPForLoginThrottle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const ipMaxAttempts = 2
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string // 每次请求携带的 X-Forwarded-For
+		wantLocked     bool
+	}{
+		{
+			name:         "未配置可信代理时伪造的 X-Forwarded-For 不影响计数",
+			remoteAddr:   "198.51.100.7:40000",
+			forwardedFor: []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+			wantLocked:   true,
+		},
+		{
+			name:           "不是来自可信代理的请求同样忽略 X-Forwarded-For",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "198.51.100.7:40000",
+			forwardedFor:   []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+			wantLocked:     true,
+		},
+		{
+			name:           "可信代理转发的不同客户端分别计数",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.5:40000",
+			forwardedFor:   []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { rdb.Close() })
+			throttle := services.NewLoginThrottleService(rdb, services.LoginThrottleOptions{
+				MaxAttempts:     1000,
+				IPMaxAttempts:   ipMaxAttempts,
+				Window:          15 * time.Minute,
+				LockoutDuration: 15 * time.Minute,
+			})
+
+			r, err := newEngine(tt.trustedProxies)
+			if err != nil {
+				t.Fatalf("创建引擎失败: %v", err)
+			}
+			// 每次请求使用不同的账号，只有 IP 维度的计数会累计
+			r.POST("/api/login", func(ctx *gin.Context) {
+				client := services.ClientInfo{IP: ctx.ClientIP()}
+				account := ctx.GetHeader("X-Test-Account")
+				if err := throttle.Check(ctx, account, client); err != nil {
+					ctx.Status(http.StatusTooManyRequests)
+					return
+				}
+				if err := throttle.RecordFailure(ctx, account, client); err != nil {
+					t.Errorf("记录登录失败出错: %v", err)
+				}
+				ctx.Status(http.StatusUnauthorized)
+			})
+
+			var last int
+			for i, forwardedFor := range tt.forwardedFor {
+				req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				req.Header.Set("X-Test-Account", string(rune('a'+i)))
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				last = w.Code
+			}
+			if locked := last == http.StatusTooManyRequests; locked != tt.wantLocked {
+				t.Fatalf("期望锁定 %v，最后一次请求的状态码为 %d", tt.wantLocked, last)
+			}
+		})
+	}
+}
+
+func TestNewEngineRejectsInvalidTrustedProxies(t *testing.T) {
+	if _, err := newEngine([]string{"not-an-ip"}); err == nil {
+		t.Fatalf("期望拒绝无效的可信代理")
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/plusone/models"
+	"github.com/plusone/utils"
+)
+
+// Principal 经过认证的调用方，可能是用户，也可能是服务账号
+type Principal struct {
+	UserID           uint
+	ServiceAccountID uint
+	Roles            []string
+	Permissions      []string
+	// Scopes 为 nil 表示不受作用域限制 (登录会话签发的访问令牌)
+	Scopes []string
+	// Claims 仅在使用 JWT 认证时存在
+	Claims *utils.JWTClaims
+	// TokenID 仅在使用个人访问令牌认证时存在
+	TokenID uint
+	// ApiKeyID 仅在使用服务 API Key 认证时存在
+	ApiKeyID uint
+}
+
+// HasScope 判断调用方是否被授予了指定作用域
+func (p *Principal) HasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+// AuthService 认证服务，根据凭证类型分派到具体的验证逻辑
+type AuthService struct {
+	tokens          *TokenService
+	pats            *PersonalAccessTokenService
+	serviceAccounts *ServiceAccountService
+}
+
+// NewAuthService 创建认证服务实例
+func NewAuthService(tokens *TokenService, pats *PersonalAccessTokenService, serviceAccounts *ServiceAccountService) *AuthService {
+	return &AuthService{
+		tokens:          tokens,
+		pats:            pats,
+		serviceAccounts: serviceAccounts,
+	}
+}
+
+// AuthenticateAPIKey 验证服务账号的 API Key
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	return s.serviceAccounts.Authenticate(ctx, key)
+}
+
+// AuthenticateBearer 验证 Bearer 凭证，支持 JWT 访问令牌和个人访问令牌
+func (s *AuthService) AuthenticateBearer(ctx context.Context, token string) (*Principal, error) {
+	if strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+		return s.pats.Authenticate(ctx, token)
+	}
+
+	claims, err := s.tokens.ValidateAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	principal := &Principal{
+		UserID:      claims.UserID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		Claims:      claims,
+	}
+	// OAuth 客户端签发的令牌只能访问被授予的作用域
+	if claims.ClientID != "" {
+		principal.Scopes = append([]string{}, strings.Fields(claims.Scope)...)
+	}
+	return principal, nil
+}
+
+// TouchSession 记录调用方所属登录会话的活跃时间，非会话凭证直接忽略
+func (s *AuthService) TouchSession(ctx context.Context, principal *Principal, ip string) error {
+	if principal.Claims == nil || principal.Claims.SessionID == 0 {
+		return nil
+	}
+	return s.tokens.TouchSession(ctx, principal.Claims.SessionID, ip)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound  = errors.New("用户不存在")
+	ErrWrongPassword = errors.New("密码错误")
+	// ErrInvalidCredentials 登录接口对用户不存在和密码错误统一返回的错误，避免泄露账号是否存在
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+)
+
+// Authenticator 用户名密码认证后端
+// 认证成功时返回对应的本地用户；后端中没有该用户时返回 ErrUserNotFound，密码不正确时返回 ErrWrongPassword
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// AuthenticatorChain 按配置顺序依次尝试的认证后端，第一个认证成功的后端生效
+// 某个后端不可用时记录日志后继续尝试下一个，全部失败时优先返回密码错误，其次返回后端故障
+type AuthenticatorChain []Authenticator
+
+// Name 认证后端名称
+func (c AuthenticatorChain) Name() string {
+	return "chain"
+}
+
+// Authenticate 依次尝试各个认证后端
+func (c AuthenticatorChain) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	result := ErrUserNotFound
+	var backendErr error
+	for _, authenticator := range c {
+		user, err := authenticator.Authenticate(ctx, username, password)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, ErrWrongPassword):
+			result = ErrWrongPassword
+		case errors.Is(err, ErrUserNotFound):
+		default:
+			logger.CtxErrorf(ctx, "认证后端不可用, authenticator: %s, username: %s, error: %v", authenticator.Name(), username, err)
+			backendErr = err
+		}
+	}
+	if result == ErrUserNotFound && backendErr != nil {
+		return nil, backendErr
+	}
+	return nil, result
+}
+
+// DatabaseAuthenticator 使用 users 表中的密码哈希认证
+// 没有设置密码的用户 (通过外部身份或 LDAP 创建) 不能通过该后端登录
+type DatabaseAuthenticator struct {
+	users  *repositories.UserRepository
+	hasher *utils.PasswordHasher
+}
+
+// NewDatabaseAuthenticator 创建数据库认证后端实例
+func NewDatabaseAuthenticator(users *repositories.UserRepository, hasher *utils.PasswordHasher) *DatabaseAuthenticator {
+	return &DatabaseAuthenticator{users: users, hasher: hasher}
+}
+
+// Name 认证后端名称
+func (a *DatabaseAuthenticator) Name() string {
+	return "database"
+}
+
+// Authenticate 校验用户名 (或邮箱) 和密码，用户名和邮箱都不区分大小写
+// 用户不存在或没有设置密码时同样执行一次密码哈希校验，响应时间不会暴露账号是否存在
+func (a *DatabaseAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := a.users.FindByUsernameOrEmail(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.hasher.VerifyDummy(password)
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if !user.HasPassword() {
+		a.hasher.VerifyDummy(password)
+		return nil, ErrWrongPassword
+	}
+	if !user.CheckPassword(password) {
+		return nil, ErrWrongPassword
+	}
+	return user, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+)
+
+// stubAuthenticator 返回固定结果的认证后端，记录是否被调用
+type stubAuthenticator struct {
+	name   string
+	user   *models.User
+	err    error
+	called bool
+}
+
+func (a *stubAuthenticator) Name() string {
+	return a.name
+}
+
+func (a *stubAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	a.called = true
+	return a.user, a.err
+}
+
+func TestAuthenticatorChain(t *testing.T) {
+	alice := &models.User{Username: "alice"}
+	bob := &models.User{Username: "bob"}
+	errBackend := errors.New("连接 LDAP 服务器失败")
+
+	tests := []struct {
+		name     string
+		backends []*stubAuthenticator
+		wantUser *models.User
+		wantErr  error
+		// notCalled 认证成功后不应再尝试的后端下标
+		notCalled []int
+	}{
+		{
+			name:     "没有配置后端",
+			backends: nil,
+			wantErr:  ErrUserNotFound,
+		},
+		{
+			name: "第一个认证成功的后端生效",
+			backends: []*stubAuthenticator{
+				{name: "database", user: alice},
+				{name: "ldap", user: bob},
+			},
+			wantUser:  alice,
+			notCalled: []int{1},
+		},
+		{
+			name: "前一个后端没有该用户时尝试下一个",
+			backends: []*stubAuthenticator{
+				{name: "database", err: ErrUserNotFound},
+				{name: "ldap", user: bob},
+			},
+			wantUser: bob,
+		},
+		{
+			name: "前一个后端密码错误时仍尝试下一个",
+			backends: []*stubAuthenticator{
+				{name: "database", err: ErrWrongPassword},
+				{name: "ldap", user: bob},
+			},
+			wantUser: bob,
+		},
+		{
+			name: "后端不可用时继续尝试下一个",
+			backends: []*stubAuthenticator{
+				{name: "ldap", err: errBackend},
+				{name: "database", user: alice},
+			},
+			wantUser: alice,
+		},
+		{
+			name: "全部失败时优先返回密码错误",
+			backends: []*stubAuthenticator{
+				{name: "ldap", err: errBackend},
+				{name: "database", err: ErrWrongPassword},
+			},
+			wantErr: ErrWrongPassword,
+		},
+		{
+			name: "没有后端认识该用户时返回用户不存在",
+			backends: []*stubAuthenticator{
+				{name: "database", err: ErrUserNotFound},
+				{name: "ldap", err: ErrUserNotFound},
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "没有后端认识该用户且有后端故障时返回后端故障",
+			backends: []*stubAuthenticator{
+				{name: "database", err: ErrUserNotFound},
+				{name: "ldap", err: errBackend},
+			},
+			wantErr: errBackend,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := make(AuthenticatorChain, 0, len(tt.backends))
+			for _, backend := range tt.backends {
+				chain = append(chain, backend)
+			}
+
+			user, err := chain.Authenticate(context.Background(), "alice", "password")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+			} else if err != nil || user != tt.wantUser {
+				t.Fatalf("期望用户 %+v，实际为 %+v, %v", tt.wantUser, user, err)
+			}
+			for _, i := range tt.notCalled {
+				if tt.backends[i].called {
+					t.Errorf("认证成功后不应再尝试后端 %s", tt.backends[i].name)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabaseAuthenticator(t *testing.T) {
+	db := newTestDB(t)
+	alice := createTestUser(t, db, "Alice", "Alice@Example.com", "correct-password")
+	createTestUser(t, db, "federated", "federated@example.com", "")
+	// 升级前创建的用户名包含 @ 的用户，不能抢占其他用户的邮箱登录
+	createTestUser(t, db, "alice@example.com", "shadow@example.com", "correct-password")
+	authenticator := NewDatabaseAuthenticator(repositories.NewUserRepository(db), newTestHasher())
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{name: "用户名和密码正确", username: "Alice", password: "correct-password"},
+		{name: "用户名不区分大小写", username: "alice", password: "correct-password"},
+		{name: "使用邮箱登录", username: "alice@example.com", password: "correct-password"},
+		{name: "密码错误", username: "Alice", password: "wrong-password", wantErr: ErrWrongPassword},
+		{name: "用户不存在", username: "nobody", password: "correct-password", wantErr: ErrUserNotFound},
+		{name: "没有设置密码的用户不能使用密码登录", username: "federated", password: "", wantErr: ErrWrongPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := authenticator.Authenticate(context.Background(), tt.username, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("认证失败: %v", err)
+			}
+			if user.ID != alice.ID {
+				t.Fatalf("认证到了错误的用户: %+v", user)
+			}
+		})
+	}
+}
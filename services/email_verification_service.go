@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/plusone/utils/mailer"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const emailVerifyResendKeyPrefix = "email_verify_resend:"
+
+var (
+	ErrInvalidEmailVerification = errors.New("验证链接无效或已过期")
+	ErrEmailNotVerified         = errors.New("邮箱未验证，请先完成邮箱验证")
+	ErrTooManyRequests          = errors.New("请求过于频繁，请稍后再试")
+)
+
+// EmailVerificationService 邮箱验证服务
+// 验证链接是携带用户ID、邮箱和过期时间的 HMAC 签名令牌，无需在数据库中保存；
+// 用户更换邮箱后旧链接自然失效
+type EmailVerificationService struct {
+	userRepo       *repositories.UserRepository
+	mailer         mailer.Mailer
+	rdb            *redis.Client
+	secret         []byte
+	baseURL        string
+	ttl            time.Duration
+	resendInterval time.Duration
+}
+
+// NewEmailVerificationService 创建邮箱验证服务实例
+func NewEmailVerificationService(userRepo *repositories.UserRepository, m mailer.Mailer, rdb *redis.Client, secret, baseURL string, ttl, resendInterval time.Duration) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:       userRepo,
+		mailer:         m,
+		rdb:            rdb,
+		secret:         []byte(secret),
+		baseURL:        baseURL,
+		ttl:            ttl,
+		resendInterval: resendInterval,
+	}
+}
+
+// SendVerification 向用户当前的邮箱发送验证链接，邮件在后台发送
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *models.User) {
+	expiresAt := time.Now().Add(s.ttl).Unix()
+	payload := fmt.Sprintf("%d|%d|%s", user.ID, expiresAt, user.Email)
+	token := utils.SignPayload(s.secret, []byte(payload))
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "验证您的邮箱",
+		Body: fmt.Sprintf("您好 %s，\n\n请在 %d 小时内打开以下链接完成邮箱验证：\n%s/api/email/verify?token=%s\n\n如果这不是您本人的操作，请忽略本邮件。\n",
+			user.Username, int(s.ttl.Hours()), s.baseURL, url.QueryEscape(token)),
+	}
+	go func(ctx context.Context) {
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			logger.CtxErrorf(ctx, "发送验证邮件失败, userID: %d, error: %v", user.ID, err)
+		}
+	}(context.WithoutCancel(ctx))
+}
+
+// SendRegistrationConflict 防枚举模式下注册的用户名或邮箱已被使用时，向注册时填写的邮箱发送说明，邮件在后台发送
+// 注册接口对冲突和成功返回相同的响应，只有邮箱的所有者能从邮件中得知结果
+func (s *EmailVerificationService) SendRegistrationConflict(ctx context.Context, username, email string, emailTaken bool) {
+	body := fmt.Sprintf("您好，\n\n有人使用本邮箱申请注册用户名 %s，但该用户名已被占用，注册没有完成。请更换用户名后重新注册。\n\n如果这不是您本人的操作，请忽略本邮件。\n", username)
+	if emailTaken {
+		body = "您好，\n\n有人尝试使用本邮箱注册新账号，但本邮箱已经绑定了账号。如果是您本人，请直接登录，忘记密码时可以使用找回密码功能。\n\n如果这不是您本人的操作，请忽略本邮件，您的账号不受影响。\n"
+	}
+	msg := mailer.Message{
+		To:      email,
+		Subject: "注册未完成",
+		Body:    body,
+	}
+	go func(ctx context.Context) {
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			logger.CtxErrorf(ctx, "发送注册提醒邮件失败, error: %v", err)
+		}
+	}(context.WithoutCancel(ctx))
+}
+
+// Verify 校验验证链接中的令牌并标记邮箱已验证，重复验证视为成功
+func (s *EmailVerificationService) Verify(ctx context.Context, token string) error {
+	payload, err := utils.VerifySignedPayload(s.secret, token)
+	if err != nil {
+		return ErrInvalidEmailVerification
+	}
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		return ErrInvalidEmailVerification
+	}
+	userID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalidEmailVerification
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidEmailVerification
+	}
+	email := parts[2]
+
+	user, err := s.userRepo.FindByID(ctx, uint(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidEmailVerification
+		}
+		return err
+	}
+	if user.Email != email {
+		return ErrInvalidEmailVerification
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	_, err = s.userRepo.MarkEmailVerified(ctx, user.ID, email, time.Now())
+	return err
+}
+
+// Resend 重新发送验证邮件
+// 按规范化后的邮箱限制发送频率，大小写或全角写法不同的同一邮箱共用限制，并且无论邮箱是否存在或已验证都返回相同的结果
+func (s *EmailVerificationService) Resend(ctx context.Context, email string) error {
+	ok, err := s.rdb.SetNX(ctx, emailVerifyResendKeyPrefix+utils.HashToken(utils.NormalizeIdentity(email)), 1, s.resendInterval).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	s.SendVerification(ctx, user)
+	return nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/plusone/repositories"
+)
+
+func TestEmailVerificationServiceResendThrottlesEmailVariants(t *testing.T) {
+	db := newTestDB(t)
+	rdb, _ := newTestRedis(t)
+	service := NewEmailVerificationService(repositories.NewUserRepository(db), &testMailer{}, rdb, "verify-secret", "http://localhost", time.Hour, time.Minute)
+	createTestUser(t, db, "alice", "alice@example.com", "correct-password")
+	ctx := context.Background()
+
+	if err := service.Resend(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("重新发送验证邮件失败: %v", err)
+	}
+	// 大小写和全角写法不同的同一邮箱不能绕过发送间隔
+	for _, email := range []string{"ALICE@example.com", "ａｌｉｃｅ@example.com", " alice@example.com "} {
+		if err := service.Resend(ctx, email); !errors.Is(err, ErrTooManyRequests) {
+			t.Errorf("使用 %q 重新发送期望 %v，实际为 %v", email, ErrTooManyRequests, err)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	// ExternalLoginStateTTL 跳转到外部身份提供方后完成登录的时限
+	ExternalLoginStateTTL = 10 * time.Minute
+
+	externalLoginStateKeyPrefix = "external_login_state:"
+)
+
+var (
+	ErrExternalProviderNotFound = errors.New("不支持的登录方式")
+	ErrExternalStateInvalid     = errors.New("登录状态无效或已过期，请重新开始")
+	ErrExternalLoginFailed      = errors.New("外部账号验证失败")
+	ErrExternalEmailRequired    = errors.New("外部账号未提供邮箱，无法创建账号")
+	ErrExternalEmailInUse       = errors.New("该邮箱已被其他账号使用，请使用原账号登录后在账户设置中关联")
+	ErrIdentityLinkedElsewhere  = errors.New("该外部账号已关联到其他用户")
+	ErrLastLoginMethod          = errors.New("这是账号唯一的登录方式，请先通过找回密码设置密码")
+)
+
+// usernameInvalidChars 生成本地用户名时需要去掉的字符
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ExternalProviderOptions 外部身份提供方配置
+// 配置了 Issuer 时按 OpenID Connect 处理，否则作为普通 OAuth2 提供方通过 UserInfoURL 获取用户信息
+type ExternalProviderOptions struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	SubjectClaim  string
+	EmailClaim    string
+	UsernameClaim string
+	NameClaim     string
+}
+
+// externalProvider 外部身份提供方，OpenID Connect 提供方在第一次使用时才进行发现，避免启动时依赖外部网络
+type externalProvider struct {
+	opts ExternalProviderOptions
+
+	mu       sync.Mutex
+	config   *oauth2.Config
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+// externalLoginState 跳转到外部身份提供方前保存的登录状态，UserID 非零表示为该用户关联外部身份
+type externalLoginState struct {
+	Provider string `json:"provider"`
+	UserID   uint   `json:"user_id,omitempty"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// externalProfile 从外部身份提供方获取的用户信息
+type externalProfile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+}
+
+// ExternalLoginService 外部身份提供方登录与账号关联服务
+// 通过 (提供方, 外部账号标识) 查找本地用户，首次登录时自动创建用户；邮箱已被其他账号使用时拒绝，需要登录后手动关联
+type ExternalLoginService struct {
+	providers    map[string]*externalProvider
+	names        []string
+	users        *repositories.UserRepository
+	identities   *repositories.IdentityRepository
+	verification *EmailVerificationService
+	completer    *LoginCompleter
+	rdb          *redis.Client
+}
+
+// NewExternalLoginService 创建外部身份登录服务实例
+func NewExternalLoginService(providers []ExternalProviderOptions, users *repositories.UserRepository, identities *repositories.IdentityRepository, verification *EmailVerificationService, completer *LoginCompleter, rdb *redis.Client) *ExternalLoginService {
+	s := &ExternalLoginService{
+		providers:    make(map[string]*externalProvider, len(providers)),
+		users:        users,
+		identities:   identities,
+		verification: verification,
+		completer:    completer,
+		rdb:          rdb,
+	}
+	for _, opts := range providers {
+		s.providers[opts.Name] = &externalProvider{opts: opts}
+		s.names = append(s.names, opts.Name)
+	}
+	return s
+}
+
+// Providers 返回已配置的外部身份提供方名称
+func (s *ExternalLoginService) Providers() []string {
+	return s.names
+}
+
+// AuthorizationURL 生成跳转到外部身份提供方的授权地址
+// userID 为 0 时用于登录，否则用于为该用户关联外部身份；state 只能使用一次，并使用 PKCE 和 nonce 防止授权码被替换
+func (s *ExternalLoginService) AuthorizationURL(ctx context.Context, providerName string, userID uint) (string, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	record := externalLoginState{
+		Provider: providerName,
+		UserID:   userID,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	if err := s.rdb.Set(ctx, externalLoginStateKeyPrefix+utils.HashToken(state), data, ExternalLoginStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(record.Verifier)}
+	if provider.isOIDC() {
+		opts = append(opts, oidc.Nonce(nonce))
+	}
+	return provider.config.AuthCodeURL(state, opts...), nil
+}
+
+// Login 使用外部身份提供方回调的授权码登录
+// 已关联的外部身份直接登录；未关联时按外部账号的邮箱创建新用户，启用了双因素认证的用户同样需要完成第二步验证
+func (s *ExternalLoginService) Login(ctx context.Context, providerName, code, state string, client ClientInfo) (*LoginResult, error) {
+	profile, err := s.exchange(ctx, providerName, code, state, 0)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.resolveUser(ctx, providerName, profile)
+	if err != nil {
+		return nil, err
+	}
+	return s.completeLogin(ctx, user, client)
+}
+
+// resolveUser 查找外部身份关联的本地用户，未关联时创建新用户，并记录本次登录时间
+func (s *ExternalLoginService) resolveUser(ctx context.Context, providerName string, profile *externalProfile) (*models.User, error) {
+	var user *models.User
+	identity, err := s.identities.FindByProviderSubject(ctx, providerName, profile.Subject)
+	switch {
+	case err == nil:
+		if user, err = s.users.FindByID(ctx, identity.UserID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("用户不存在")
+			}
+			return nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if user, identity, err = s.createUser(ctx, providerName, profile); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.identities.UpdateLastLogin(ctx, identity.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// completeLogin 外部身份验证通过后完成登录：启用了双因素认证时返回 MFA 挑战令牌，否则签发令牌
+func (s *ExternalLoginService) completeLogin(ctx context.Context, user *models.User, client ClientInfo) (*LoginResult, error) {
+	return s.completer.Complete(ctx, user, client, utils.AMRFederated, false)
+}
+
+// Link 使用外部身份提供方回调的授权码为当前用户关联外部身份，state 必须由同一用户发起
+func (s *ExternalLoginService) Link(ctx context.Context, userID uint, providerName, code, state string) (*models.Identity, error) {
+	profile, err := s.exchange(ctx, providerName, code, state, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.identities.FindByProviderSubject(ctx, providerName, profile.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, ErrIdentityLinkedElsewhere
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	identity := &models.Identity{
+		UserID:   userID,
+		Provider: providerName,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+		Username: profile.Username,
+	}
+	if err := s.identities.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("关联外部账号失败: %w", err)
+	}
+	return identity, nil
+}
+
+// ListIdentities 列出用户关联的外部身份
+func (s *ExternalLoginService) ListIdentities(ctx context.Context, userID uint) ([]models.Identity, error) {
+	return s.identities.ListByUser(ctx, userID)
+}
+
+// Unlink 解除外部身份关联，没有设置密码的用户不能解除最后一个外部身份，目录服务管理的身份不能解除
+func (s *ExternalLoginService) Unlink(ctx context.Context, userID, id uint) error {
+	user, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+	if !user.HasPassword() {
+		count, err := s.identities.CountByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	identity, err := s.identities.FindByUser(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("外部账号不存在")
+		}
+		return err
+	}
+	// LDAP 等由目录管理的身份不是用户自己关联的，不能解除
+	if _, ok := s.providers[identity.Provider]; !ok {
+		return errors.New("该账号由目录服务管理，不能解除关联")
+	}
+
+	affected, err := s.identities.DeleteByUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("外部账号不存在")
+	}
+	return nil
+}
+
+// exchange 校验并取出登录状态，使用授权码换取令牌并获取外部账号信息
+func (s *ExternalLoginService) exchange(ctx context.Context, providerName, code, state string, userID uint) (*externalProfile, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.rdb.GetDel(ctx, externalLoginStateKeyPrefix+utils.HashToken(state)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrExternalStateInvalid
+		}
+		return nil, err
+	}
+	var record externalLoginState
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析登录状态失败: %w", err)
+	}
+	if record.Provider != providerName || record.UserID != userID {
+		return nil, ErrExternalStateInvalid
+	}
+
+	token, err := provider.config.Exchange(ctx, code, oauth2.VerifierOption(record.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+
+	var claims map[string]interface{}
+	if provider.isOIDC() {
+		claims, err = provider.idTokenClaims(ctx, token, record.Nonce)
+	} else {
+		claims, err = provider.userInfoClaims(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opts := provider.opts
+	profile := &externalProfile{
+		Subject:       claimString(claims, opts.SubjectClaim),
+		Email:         strings.TrimSpace(claimString(claims, opts.EmailClaim)),
+		EmailVerified: claimString(claims, "email_verified") == "true",
+		Username:      claimString(claims, opts.UsernameClaim),
+		Name:          claimString(claims, opts.NameClaim),
+	}
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("%w: 缺少账号标识", ErrExternalLoginFailed)
+	}
+	return profile, nil
+}
+
+// createUser 为首次登录的外部账号创建本地用户并关联外部身份，新用户没有密码
+func (s *ExternalLoginService) createUser(ctx context.Context, providerName string, profile *externalProfile) (*models.User, *models.Identity, error) {
+	if profile.Email == "" {
+		return nil, nil, ErrExternalEmailRequired
+	}
+
+	var user *models.User
+	var identity *models.Identity
+	err := s.users.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewUserRepository(tx)
+
+		_, err := txRepo.FindByEmail(ctx, profile.Email)
+		if err == nil {
+			return ErrExternalEmailInUse
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		username, err := s.availableUsername(ctx, txRepo, profile)
+		if err != nil {
+			return err
+		}
+		newUser := &models.User{
+			Username: username,
+			Email:    profile.Email,
+			Nickname: profile.Name,
+		}
+		if len([]rune(newUser.Nickname)) > 50 {
+			newUser.Nickname = string([]rune(newUser.Nickname)[:50])
+		}
+		if profile.EmailVerified {
+			now := time.Now()
+			newUser.EmailVerifiedAt = &now
+		}
+		if err := createUserWithDefaultRole(ctx, tx, newUser); err != nil {
+			return err
+		}
+
+		newIdentity := &models.Identity{
+			UserID:   newUser.ID,
+			Provider: providerName,
+			Subject:  profile.Subject,
+			Email:    profile.Email,
+			Username: profile.Username,
+		}
+		if err := repositories.NewIdentityRepository(tx).Create(ctx, newIdentity); err != nil {
+			return err
+		}
+
+		user, identity = newUser, newIdentity
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.EmailVerified() {
+		s.verification.SendVerification(ctx, user)
+	}
+	return user, identity, nil
+}
+
+// availableUsername 根据外部账号的用户名或邮箱前缀生成未被占用的本地用户名
+func (s *ExternalLoginService) availableUsername(ctx context.Context, repo *repositories.UserRepository, profile *externalProfile) (string, error) {
+	base := profile.Username
+	if base == "" {
+		base, _, _ = strings.Cut(profile.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		_, err := repo.FindByUsername(ctx, candidate)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + strings.ToLower(suffix)
+	}
+	return "", errors.New("无法生成可用的用户名")
+}
+
+// provider 返回已配置的外部身份提供方，并在需要时完成 OpenID Connect 发现
+func (s *ExternalLoginService) provider(ctx context.Context, name string) (*externalProvider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, ErrExternalProviderNotFound
+	}
+	if err := provider.init(ctx); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// isOIDC 判断提供方是否按 OpenID Connect 处理
+func (p *externalProvider) isOIDC() bool {
+	return p.opts.Issuer != ""
+}
+
+// init 初始化 OAuth2 客户端配置，OpenID Connect 提供方从发现文档读取端点，发现失败时下次调用会重试
+func (p *externalProvider) init(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config != nil {
+		return nil
+	}
+
+	config := &oauth2.Config{
+		ClientID:     p.opts.ClientID,
+		ClientSecret: p.opts.ClientSecret,
+		RedirectURL:  p.opts.RedirectURL,
+		Scopes:       p.opts.Scopes,
+		Endpoint:     oauth2.Endpoint{AuthURL: p.opts.AuthURL, TokenURL: p.opts.TokenURL},
+	}
+	if p.isOIDC() {
+		provider, err := oidc.NewProvider(ctx, p.opts.Issuer)
+		if err != nil {
+			return fmt.Errorf("获取 %s 的 OpenID Connect 配置失败: %w", p.opts.Name, err)
+		}
+		config.Endpoint = provider.Endpoint()
+		p.provider = provider
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.opts.ClientID})
+	}
+	p.config = config
+	return nil
+}
+
+// idTokenClaims 校验 ID Token 的签名、受众和 nonce，返回其中的声明
+// ID Token 中没有邮箱时再从用户信息端点补充
+func (p *externalProvider) idTokenClaims(ctx context.Context, token *oauth2.Token, nonce string) (map[string]interface{}, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%w: 缺少 ID Token", ErrExternalLoginFailed)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce 不匹配", ErrExternalLoginFailed)
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+	if claimString(claims, p.opts.EmailClaim) != "" || p.provider.UserInfoEndpoint() == "" {
+		return claims, nil
+	}
+
+	userInfo, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+	if userInfo.Subject != idToken.Subject {
+		return nil, fmt.Errorf("%w: 用户信息与 ID Token 不一致", ErrExternalLoginFailed)
+	}
+	var extra map[string]interface{}
+	if err := userInfo.Claims(&extra); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+	for key, value := range extra {
+		if _, exists := claims[key]; !exists {
+			claims[key] = value
+		}
+	}
+	return claims, nil
+}
+
+// userInfoClaims 使用访问令牌从普通 OAuth2 提供方的用户信息端点获取声明
+func (p *externalProvider) userInfoClaims(ctx context.Context, token *oauth2.Token) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.Client(ctx, token).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalLoginFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: 获取用户信息返回 %s", ErrExternalLoginFailed, resp.Status)
+	}
+
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: 解析用户信息失败: %v", ErrExternalLoginFailed, err)
+	}
+	return claims, nil
+}
+
+// claimString 以字符串形式读取声明，数字类型的账号标识 (如 GitHub 的 id) 转换为十进制字符串
+func claimString(claims map[string]interface{}, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return ""
+	}
+}
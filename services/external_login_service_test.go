@@ -0,0 +1,598 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"gorm.io/gorm"
+)
+
+const fakeIdPClientID = "plusone-client"
+
+// fakeIdentityProvider 测试中启动的外部身份提供方，同时提供 OpenID Connect 和普通 OAuth2 的端点
+// 授权码校验 PKCE，ID Token 使用 RS256 签名并带上授权请求中的 nonce
+type fakeIdentityProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]fakeGrant              // 授权码 -> 授权信息
+	tokens map[string]map[string]interface{} // 访问令牌 -> 用户信息
+}
+
+type fakeGrant struct {
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成签名密钥失败: %v", err)
+	}
+	idp := &fakeIdentityProvider{
+		key:    key,
+		grants: make(map[string]fakeGrant),
+		tokens: make(map[string]map[string]interface{}),
+	}
+
+	mux := http.NewServeMux()
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	issuer := idp.server.URL
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                issuer,
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"userinfo_endpoint":                     issuer + "/userinfo",
+			"jwks_uri":                              issuer + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		code := r.PostForm.Get("code")
+		idp.mu.Lock()
+		grant, ok := idp.grants[code]
+		delete(idp.grants, code)
+		idp.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   issuer,
+			"aud":   fakeIdPClientID,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": grant.nonce,
+		}
+		for name, value := range grant.claims {
+			claims[name] = value
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = "test"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+			return
+		}
+
+		accessToken := "access-" + code
+		idp.mu.Lock()
+		idp.tokens[accessToken] = grant.claims
+		idp.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		claims, ok := idp.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		idp.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, claims)
+	})
+	return idp
+}
+
+// authorize 模拟用户在身份提供方同意授权，返回回调中的授权码和 state
+func (idp *fakeIdentityProvider) authorize(t *testing.T, authURL string, claims map[string]interface{}) (string, string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil || !strings.HasPrefix(authURL, idp.server.URL+"/authorize") {
+		t.Fatalf("授权地址不正确: %s", authURL)
+	}
+	query := u.Query()
+	if query.Get("client_id") != fakeIdPClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("授权请求缺少客户端或 PKCE 参数: %s", authURL)
+	}
+
+	code, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		t.Fatalf("生成授权码失败: %v", err)
+	}
+	idp.mu.Lock()
+	idp.grants[code] = fakeGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		claims:    claims,
+	}
+	idp.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (idp *fakeIdentityProvider) oidcOptions() ExternalProviderOptions {
+	return ExternalProviderOptions{
+		Name:          "fake-oidc",
+		Issuer:        idp.server.URL,
+		ClientID:      fakeIdPClientID,
+		ClientSecret:  "secret",
+		Scopes:        []string{"openid", "email", "profile"},
+		RedirectURL:   "http://localhost/callback",
+		SubjectClaim:  "sub",
+		EmailClaim:    "email",
+		UsernameClaim: "preferred_username",
+		NameClaim:     "name",
+	}
+}
+
+func (idp *fakeIdentityProvider) oauth2Options() ExternalProviderOptions {
+	return ExternalProviderOptions{
+		Name:          "fake-oauth2",
+		ClientID:      fakeIdPClientID,
+		ClientSecret:  "secret",
+		RedirectURL:   "http://localhost/callback",
+		AuthURL:       idp.server.URL + "/authorize",
+		TokenURL:      idp.server.URL + "/token",
+		UserInfoURL:   idp.server.URL + "/userinfo",
+		SubjectClaim:  "id",
+		EmailClaim:    "email",
+		UsernameClaim: "login",
+		NameClaim:     "name",
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+type externalLoginFixture struct {
+	service    *ExternalLoginService
+	idp        *fakeIdentityProvider
+	db         *gorm.DB
+	users      *repositories.UserRepository
+	identities *repositories.IdentityRepository
+	tokens     *TokenService
+}
+
+func newExternalLoginFixture(t *testing.T) *externalLoginFixture {
+	t.Helper()
+	idp := newFakeIdentityProvider(t)
+	db := newTestDB(t)
+	rdb, _ := newTestRedis(t)
+	users := repositories.NewUserRepository(db)
+	identities := repositories.NewIdentityRepository(db)
+	verification := NewEmailVerificationService(users, &testMailer{}, rdb, "verify-secret", "http://localhost", time.Hour, time.Minute)
+	completer := newTestLoginCompleter(db, rdb, false)
+	service := NewExternalLoginService([]ExternalProviderOptions{idp.oidcOptions(), idp.oauth2Options()}, users, identities, verification, completer, rdb)
+	return &externalLoginFixture{
+		service:    service,
+		idp:        idp,
+		db:         db,
+		users:      users,
+		identities: identities,
+		tokens:     completer.tokens,
+	}
+}
+
+// callback 发起授权并模拟身份提供方回调，返回授权码和 state
+func (f *externalLoginFixture) callback(t *testing.T, provider string, userID uint, claims map[string]interface{}) (string, string) {
+	t.Helper()
+	authURL, err := f.service.AuthorizationURL(context.Background(), provider, userID)
+	if err != nil {
+		t.Fatalf("生成授权地址失败: %v", err)
+	}
+	return f.idp.authorize(t, authURL, claims)
+}
+
+// loggedInUser 校验登录签发的访问令牌，返回令牌所属的用户
+func (f *externalLoginFixture) loggedInUser(t *testing.T, result *LoginResult) *models.User {
+	t.Helper()
+	if result == nil || result.Tokens == nil {
+		t.Fatalf("登录没有签发令牌: %+v", result)
+	}
+	claims, err := f.tokens.ValidateAccessToken(context.Background(), result.Tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("登录签发的令牌无效: %v", err)
+	}
+	if len(claims.AMR) != 1 || claims.AMR[0] != utils.AMRFederated {
+		t.Fatalf("外部登录的认证方式应为 %s，实际为 %v", utils.AMRFederated, claims.AMR)
+	}
+	user, err := f.users.FindByID(context.Background(), claims.UserID)
+	if err != nil {
+		t.Fatalf("查找登录用户失败: %v", err)
+	}
+	return user
+}
+
+func TestExternalLoginServiceLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		claims   map[string]interface{}
+		setup    func(t *testing.T, f *externalLoginFixture)
+		// state 非空时替换回调中的 state，code 非空时替换授权码
+		state    string
+		code     string
+		linkUser bool
+		wantErr  error
+		check    func(t *testing.T, f *externalLoginFixture, user *models.User)
+	}{
+		{
+			name:     "首次登录按外部账号创建用户",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-1", "email": "alice@example.com", "email_verified": true, "preferred_username": "Alice Smith!", "name": "Alice"},
+			check: func(t *testing.T, f *externalLoginFixture, user *models.User) {
+				if user.Username != "AliceSmith" || user.Email != "alice@example.com" || user.Nickname != "Alice" {
+					t.Errorf("创建的用户不正确: %+v", user)
+				}
+				if user.HasPassword() {
+					t.Error("外部登录创建的用户不应有密码")
+				}
+				if !user.EmailVerified() {
+					t.Error("身份提供方验证过的邮箱应标记为已验证")
+				}
+				identity, err := f.identities.FindByProviderSubject(context.Background(), "fake-oidc", "oidc-1")
+				if err != nil || identity.UserID != user.ID || identity.LastLoginAt == nil {
+					t.Errorf("外部身份关联不正确: %+v, %v", identity, err)
+				}
+			},
+		},
+		{
+			name:     "身份提供方未验证的邮箱需要重新验证",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-2", "email": "bob@example.com", "email_verified": false},
+			check: func(t *testing.T, f *externalLoginFixture, user *models.User) {
+				if user.Username != "bob" {
+					t.Errorf("没有用户名时应使用邮箱前缀，实际为 %s", user.Username)
+				}
+				if user.EmailVerified() {
+					t.Error("未验证的邮箱不应标记为已验证")
+				}
+			},
+		},
+		{
+			name:     "已关联的外部身份登录到原用户",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-3", "email": "other@example.com", "email_verified": true},
+			setup: func(t *testing.T, f *externalLoginFixture) {
+				user := createTestUser(t, f.db, "carol", "carol@example.com", "password")
+				if err := f.identities.Create(context.Background(), &models.Identity{UserID: user.ID, Provider: "fake-oidc", Subject: "oidc-3"}); err != nil {
+					t.Fatalf("创建外部身份失败: %v", err)
+				}
+			},
+			check: func(t *testing.T, f *externalLoginFixture, user *models.User) {
+				if user.Username != "carol" {
+					t.Errorf("应登录到已关联的用户，实际为 %s", user.Username)
+				}
+			},
+		},
+		{
+			name:     "用户名已被占用时追加随机后缀",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-4", "email": "dave@example.org", "preferred_username": "dave"},
+			setup: func(t *testing.T, f *externalLoginFixture) {
+				createTestUser(t, f.db, "dave", "dave@example.com", "password")
+			},
+			check: func(t *testing.T, f *externalLoginFixture, user *models.User) {
+				if !strings.HasPrefix(user.Username, "dave_") {
+					t.Errorf("用户名冲突时应追加后缀，实际为 %s", user.Username)
+				}
+			},
+		},
+		{
+			name:     "OAuth2 提供方从用户信息端点读取数字账号标识",
+			provider: "fake-oauth2",
+			claims:   map[string]interface{}{"id": 12345, "email": "erin@example.com", "login": "erin"},
+			check: func(t *testing.T, f *externalLoginFixture, user *models.User) {
+				if user.Username != "erin" {
+					t.Errorf("创建的用户不正确: %+v", user)
+				}
+				if _, err := f.identities.FindByProviderSubject(context.Background(), "fake-oauth2", "12345"); err != nil {
+					t.Errorf("数字账号标识应按十进制字符串保存: %v", err)
+				}
+			},
+		},
+		{
+			name:     "邮箱已被本地用户使用时拒绝自动关联",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-5", "email": "Frank@Example.com", "email_verified": true},
+			setup: func(t *testing.T, f *externalLoginFixture) {
+				createTestUser(t, f.db, "frank", "frank@example.com", "password")
+			},
+			wantErr: ErrExternalEmailInUse,
+		},
+		{
+			name:     "外部账号没有邮箱时不能创建用户",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-6"},
+			wantErr:  ErrExternalEmailRequired,
+		},
+		{
+			name:     "未知的 state",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-7", "email": "grace@example.com"},
+			state:    "unknown-state",
+			wantErr:  ErrExternalStateInvalid,
+		},
+		{
+			name:     "关联外部身份的 state 不能用于登录",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-8", "email": "heidi@example.com"},
+			linkUser: true,
+			wantErr:  ErrExternalStateInvalid,
+		},
+		{
+			name:     "身份提供方拒绝授权码",
+			provider: "fake-oidc",
+			claims:   map[string]interface{}{"sub": "oidc-9", "email": "ivan@example.com"},
+			code:     "forged-code",
+			wantErr:  ErrExternalLoginFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newExternalLoginFixture(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			var userID uint
+			if tt.linkUser {
+				userID = createTestUser(t, f.db, "linker", "linker@example.com", "password").ID
+			}
+			code, state := f.callback(t, tt.provider, userID, tt.claims)
+			if tt.state != "" {
+				state = tt.state
+			}
+			if tt.code != "" {
+				code = tt.code
+			}
+
+			result, err := f.service.Login(context.Background(), tt.provider, code, state, ClientInfo{IP: "127.0.0.1"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("登录失败: %v", err)
+			}
+			user := f.loggedInUser(t, result)
+			if tt.check != nil {
+				tt.check(t, f, user)
+			}
+		})
+	}
+}
+
+func TestExternalLoginServiceStateIsSingleUse(t *testing.T) {
+	f := newExternalLoginFixture(t)
+	claims := map[string]interface{}{"sub": "oidc-1", "email": "alice@example.com"}
+	code, state := f.callback(t, "fake-oidc", 0, claims)
+	if _, err := f.service.Login(context.Background(), "fake-oidc", code, state, ClientInfo{}); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	// 身份提供方重新签发授权码后，旧的 state 仍然不能再次使用
+	authURL, err := f.service.AuthorizationURL(context.Background(), "fake-oidc", 0)
+	if err != nil {
+		t.Fatalf("生成授权地址失败: %v", err)
+	}
+	code, _ = f.idp.authorize(t, authURL, claims)
+	if _, err := f.service.Login(context.Background(), "fake-oidc", code, state, ClientInfo{}); !errors.Is(err, ErrExternalStateInvalid) {
+		t.Fatalf("重复使用 state 应返回 %v，实际为 %v", ErrExternalStateInvalid, err)
+	}
+
+	if _, err := f.service.AuthorizationURL(context.Background(), "unknown", 0); !errors.Is(err, ErrExternalProviderNotFound) {
+		t.Fatalf("未配置的提供方应返回 %v，实际为 %v", ErrExternalProviderNotFound, err)
+	}
+}
+
+func TestExternalLoginServiceLink(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup 返回发起关联的用户和完成回调的用户
+		setup   func(t *testing.T, f *externalLoginFixture) (initiator, completer uint)
+		claims  map[string]interface{}
+		wantErr error
+	}{
+		{
+			name: "为当前用户关联外部身份",
+			setup: func(t *testing.T, f *externalLoginFixture) (uint, uint) {
+				user := createTestUser(t, f.db, "alice", "alice@example.com", "password")
+				return user.ID, user.ID
+			},
+			claims: map[string]interface{}{"sub": "oidc-1", "email": "alice@elsewhere.com"},
+		},
+		{
+			name: "重复关联同一外部身份返回已有记录",
+			setup: func(t *testing.T, f *externalLoginFixture) (uint, uint) {
+				user := createTestUser(t, f.db, "alice", "alice@example.com", "password")
+				if err := f.identities.Create(context.Background(), &models.Identity{UserID: user.ID, Provider: "fake-oidc", Subject: "oidc-1"}); err != nil {
+					t.Fatalf("创建外部身份失败: %v", err)
+				}
+				return user.ID, user.ID
+			},
+			claims: map[string]interface{}{"sub": "oidc-1"},
+		},
+		{
+			name: "外部身份已关联到其他用户",
+			setup: func(t *testing.T, f *externalLoginFixture) (uint, uint) {
+				owner := createTestUser(t, f.db, "owner", "owner@example.com", "password")
+				if err := f.identities.Create(context.Background(), &models.Identity{UserID: owner.ID, Provider: "fake-oidc", Subject: "oidc-1"}); err != nil {
+					t.Fatalf("创建外部身份失败: %v", err)
+				}
+				user := createTestUser(t, f.db, "alice", "alice@example.com", "password")
+				return user.ID, user.ID
+			},
+			claims:  map[string]interface{}{"sub": "oidc-1"},
+			wantErr: ErrIdentityLinkedElsewhere,
+		},
+		{
+			name: "其他用户不能完成关联",
+			setup: func(t *testing.T, f *externalLoginFixture) (uint, uint) {
+				alice := createTestUser(t, f.db, "alice", "alice@example.com", "password")
+				mallory := createTestUser(t, f.db, "mallory", "mallory@example.com", "password")
+				return alice.ID, mallory.ID
+			},
+			claims:  map[string]interface{}{"sub": "oidc-1"},
+			wantErr: ErrExternalStateInvalid,
+		},
+		{
+			name: "登录用的 state 不能用于关联",
+			setup: func(t *testing.T, f *externalLoginFixture) (uint, uint) {
+				user := createTestUser(t, f.db, "alice", "alice@example.com", "password")
+				return 0, user.ID
+			},
+			claims:  map[string]interface{}{"sub": "oidc-1"},
+			wantErr: ErrExternalStateInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newExternalLoginFixture(t)
+			initiator, completer := tt.setup(t, f)
+			code, state := f.callback(t, "fake-oidc", initiator, tt.claims)
+
+			identity, err := f.service.Link(context.Background(), completer, "fake-oidc", code, state)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("关联失败: %v", err)
+			}
+			if identity.UserID != completer || identity.Subject != tt.claims["sub"] {
+				t.Fatalf("关联的外部身份不正确: %+v", identity)
+			}
+
+			// 关联后使用该外部身份登录到同一用户
+			code, state = f.callback(t, "fake-oidc", 0, tt.claims)
+			result, err := f.service.Login(context.Background(), "fake-oidc", code, state, ClientInfo{})
+			if err != nil {
+				t.Fatalf("使用关联的外部身份登录失败: %v", err)
+			}
+			if user := f.loggedInUser(t, result); user.ID != completer {
+				t.Fatalf("应登录到关联的用户 %d，实际为 %d", completer, user.ID)
+			}
+		})
+	}
+}
+
+func TestExternalLoginServiceUnlink(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		providers  []string
+		unlink     int // 要解除的身份在 providers 中的下标，-1 表示不存在的身份
+		wantErr    error
+		wantErrMsg string
+	}{
+		{name: "有密码的用户可以解除唯一的外部身份", password: "password", providers: []string{"fake-oidc"}},
+		{name: "没有密码的用户可以解除多余的外部身份", providers: []string{"fake-oidc", "fake-oauth2"}},
+		{name: "没有密码的用户不能解除唯一的外部身份", providers: []string{"fake-oidc"}, wantErr: ErrLastLoginMethod},
+		{name: "目录服务管理的身份不能解除", password: "password", providers: []string{"ldap"}, wantErrMsg: "目录服务"},
+		{name: "不存在的外部身份", password: "password", providers: []string{"fake-oidc"}, unlink: -1, wantErrMsg: "不存在"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newExternalLoginFixture(t)
+			ctx := context.Background()
+			user := createTestUser(t, f.db, "alice", "alice@example.com", tt.password)
+			var ids []uint
+			for i, provider := range tt.providers {
+				identity := &models.Identity{UserID: user.ID, Provider: provider, Subject: "subject-" + string(rune('a'+i))}
+				if err := f.identities.Create(ctx, identity); err != nil {
+					t.Fatalf("创建外部身份失败: %v", err)
+				}
+				ids = append(ids, identity.ID)
+			}
+			id := uint(9999)
+			if tt.unlink >= 0 {
+				id = ids[tt.unlink]
+			}
+
+			err := f.service.Unlink(ctx, user.ID, id)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+			case tt.wantErrMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("期望包含 %q 的错误，实际为 %v", tt.wantErrMsg, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("解除关联失败: %v", err)
+				}
+				count, err := f.identities.CountByUser(ctx, user.ID)
+				if err != nil || count != int64(len(ids)-1) {
+					t.Fatalf("解除关联后剩余 %d 个外部身份，期望 %d", count, len(ids)-1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/mailer"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建迁移好表结构并写入默认角色的内存数据库，每个测试独占一个库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.RecoveryCode{}, &models.Session{}, &models.Identity{}); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	roles := NewRoleService(repositories.NewRoleRepository(db), repositories.NewUserRepository(db), newTestHasher(), nil)
+	if err := roles.EnsureDefaults(context.Background()); err != nil {
+		t.Fatalf("初始化默认角色失败: %v", err)
+	}
+	return db
+}
+
+// newTestRedis 启动进程内的 Redis 服务
+func newTestRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb, mr
+}
+
+// newTestHasher 使用最低成本的 bcrypt，避免拖慢测试
+func newTestHasher() *utils.PasswordHasher {
+	return utils.NewPasswordHasher(utils.PasswordHasherOptions{Algorithm: "bcrypt", BcryptCost: 4})
+}
+
+// newTestLoginCompleter 创建使用 HMAC 签名的令牌服务和登录收尾步骤
+func newTestLoginCompleter(db *gorm.DB, rdb *redis.Client, requireVerifiedEmail bool) *LoginCompleter {
+	users := repositories.NewUserRepository(db)
+	tokens := NewTokenService(utils.NewHMACKeyring("test-secret"), utils.TokenOptions{
+		Issuer:   "plusone-test",
+		Audience: "plusone-test",
+		TTL:      15 * time.Minute,
+	}, users, repositories.NewSessionRepository(db), rdb)
+	mfa := NewMFAService(users, repositories.NewRecoveryCodeRepository(db), rdb, "PlusOne")
+	return NewLoginCompleter(tokens, mfa, requireVerifiedEmail)
+}
+
+// createTestUser 创建带默认角色的用户，password 为空时用户没有密码
+func createTestUser(t *testing.T, db *gorm.DB, username, email, password string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: email}
+	if password != "" {
+		if err := user.SetPassword(newTestHasher(), password); err != nil {
+			t.Fatalf("设置密码失败: %v", err)
+		}
+	}
+	if err := createUserWithDefaultRole(context.Background(), db, user); err != nil {
+		t.Fatalf("创建用户 %s 失败: %v", username, err)
+	}
+	return user
+}
+
+// testMailer 记录发送的邮件
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
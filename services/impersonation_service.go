@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"gorm.io/gorm"
+)
+
+// impersonationLogListLimit 查询审计记录时返回的最大条数
+const impersonationLogListLimit = 100
+
+var (
+	ErrImpersonationForbidden = errors.New("模拟登录期间不允许执行该操作")
+	ErrNotImpersonating       = errors.New("当前令牌不是模拟登录令牌")
+)
+
+// ImpersonationResult 发起模拟登录的结果
+type ImpersonationResult struct {
+	Token     string
+	ExpiresIn time.Duration
+	Log       *models.ImpersonationLog
+}
+
+// ImpersonationService 管理员模拟用户登录服务，每次模拟的开始和结束都会留下审计记录
+type ImpersonationService struct {
+	users  *repositories.UserRepository
+	logs   *repositories.ImpersonationLogRepository
+	tokens *TokenService
+	ttl    time.Duration
+}
+
+// NewImpersonationService 创建模拟登录服务实例，ttl 为模拟令牌的有效期
+func NewImpersonationService(users *repositories.UserRepository, logs *repositories.ImpersonationLogRepository, tokens *TokenService, ttl time.Duration) *ImpersonationService {
+	return &ImpersonationService{
+		users:  users,
+		logs:   logs,
+		tokens: tokens,
+		ttl:    ttl,
+	}
+}
+
+// Start 以目标用户的身份签发模拟令牌
+// 不允许模拟自己、不允许在模拟期间再次模拟，也不允许模拟拥有自己所没有的权限的用户
+func (s *ImpersonationService) Start(ctx context.Context, actor *Principal, targetUserID uint, reason string, client ClientInfo) (*ImpersonationResult, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("请填写模拟登录的原因")
+	}
+	if actor.Claims == nil || actor.UserID == 0 {
+		return nil, errors.New("该操作需要使用登录会话")
+	}
+	if actor.Claims.ActorID != 0 {
+		return nil, errors.New("模拟登录期间不能再次模拟其他用户")
+	}
+	if actor.UserID == targetUserID {
+		return nil, errors.New("不能模拟自己")
+	}
+
+	target, err := s.users.FindByIDWithRoles(ctx, targetUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	for _, perm := range target.PermissionCodes() {
+		if !slices.Contains(actor.Permissions, perm) {
+			return nil, errors.New("不能模拟拥有更高权限的用户")
+		}
+	}
+
+	token, tokenID, err := s.tokens.IssueImpersonationToken(ctx, target.ID, actor.UserID, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	record := &models.ImpersonationLog{
+		ActorID:      actor.UserID,
+		TargetUserID: target.ID,
+		Reason:       reason,
+		TokenID:      tokenID,
+		IP:           client.IP,
+		UserAgent:    userAgent,
+		ExpiresAt:    time.Now().Add(s.ttl),
+	}
+	if err := s.logs.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	logger.CtxInfof(ctx, "开始模拟登录, actor: %d, target: %d, impersonation: %d, reason: %s", actor.UserID, target.ID, record.ID, reason)
+	return &ImpersonationResult{Token: token, ExpiresIn: s.ttl, Log: record}, nil
+}
+
+// Stop 结束模拟登录：作废模拟令牌并记录结束时间
+func (s *ImpersonationService) Stop(ctx context.Context, claims *utils.JWTClaims) (*models.ImpersonationLog, error) {
+	if claims == nil || claims.ActorID == 0 {
+		return nil, ErrNotImpersonating
+	}
+
+	record, err := s.logs.FindByTokenID(ctx, claims.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotImpersonating
+		}
+		return nil, err
+	}
+
+	if err := s.tokens.RevokeAccessToken(ctx, claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if _, err := s.logs.MarkEnded(ctx, record.ID, now); err != nil {
+		return nil, err
+	}
+	record.EndedAt = &now
+
+	logger.CtxInfof(ctx, "结束模拟登录, actor: %d, target: %d, impersonation: %d", record.ActorID, record.TargetUserID, record.ID)
+	return record, nil
+}
+
+// List 查询模拟登录审计记录，actorID 或 targetUserID 为 0 时不过滤
+func (s *ImpersonationService) List(ctx context.Context, actorID, targetUserID uint) ([]models.ImpersonationLog, error) {
+	return s.logs.List(ctx, actorID, targetUserID, impersonationLogListLimit)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"gorm.io/gorm"
+)
+
+// LDAPIdentityProvider LDAP 用户在外部身份表中的提供方名称，外部账号标识为用户的 DN
+const LDAPIdentityProvider = "ldap"
+
+// LDAPOptions LDAP 认证配置
+type LDAPOptions struct {
+	URL            string
+	StartTLS       bool
+	BindDN         string // 查找用户使用的服务账号，为空时匿名查找
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string // %s 替换为转义后的用户名
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+	GroupRoles     map[string]string // 小写的组 DN 到角色名的映射，为空时不同步角色
+	Timeout        time.Duration
+}
+
+// LDAPAuthenticator 使用 LDAP 目录认证：服务账号按过滤条件查找用户，再以用户的 DN 和密码绑定校验
+// 首次登录时自动创建本地用户，并通过外部身份表记录与目录条目的对应关系；配置了组映射时每次登录按组同步角色
+type LDAPAuthenticator struct {
+	opts       LDAPOptions
+	users      *repositories.UserRepository
+	identities *repositories.IdentityRepository
+	roles      *repositories.RoleRepository
+}
+
+// ldapEntry 从目录中读取的用户信息
+type ldapEntry struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// NewLDAPAuthenticator 创建 LDAP 认证后端实例
+func NewLDAPAuthenticator(opts LDAPOptions, users *repositories.UserRepository, identities *repositories.IdentityRepository, roles *repositories.RoleRepository) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		opts:       opts,
+		users:      users,
+		identities: identities,
+		roles:      roles,
+	}
+}
+
+// Name 认证后端名称
+func (a *LDAPAuthenticator) Name() string {
+	return LDAPIdentityProvider
+}
+
+// Authenticate 在目录中校验用户名和密码，返回对应的本地用户
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// 空密码绑定在 LDAP 中是匿名绑定，会被服务器视为成功
+	if password == "" {
+		return nil, ErrWrongPassword
+	}
+
+	entry, err := a.verify(username, password)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.provision(ctx, username, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.syncRoles(ctx, user, entry.Groups); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verify 查找用户条目并以用户身份绑定校验密码
+func (a *LDAPAuthenticator) verify(username, password string) (*ldapEntry, error) {
+	conn, err := ldap.DialURL(a.opts.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.opts.Timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 服务器失败: %w", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(a.opts.Timeout)
+
+	if a.opts.StartTLS {
+		serverURL, err := url.Parse(a.opts.URL)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 LDAP 地址: %w", err)
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: serverURL.Hostname()}); err != nil {
+			return nil, fmt.Errorf("LDAP StartTLS 失败: %w", err)
+		}
+	}
+
+	if a.opts.BindDN != "" {
+		if err := conn.Bind(a.opts.BindDN, a.opts.BindPassword); err != nil {
+			return nil, fmt.Errorf("LDAP 服务账号绑定失败: %w", err)
+		}
+	}
+
+	attributes := []string{a.opts.EmailAttribute, a.opts.NameAttribute, a.opts.GroupAttribute}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.opts.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.opts.Timeout.Seconds()), false,
+		fmt.Sprintf(a.opts.UserFilter, ldap.EscapeFilter(username)), attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("查找 LDAP 用户失败: %w", err)
+	}
+	// 找不到或匹配到多个条目都视为用户不存在，避免过滤条件配置不当时登录到错误的账号
+	if len(result.Entries) != 1 {
+		return nil, ErrUserNotFound
+	}
+
+	found := result.Entries[0]
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrWrongPassword
+		}
+		return nil, fmt.Errorf("LDAP 用户绑定失败: %w", err)
+	}
+
+	return &ldapEntry{
+		DN:     found.DN,
+		Email:  strings.TrimSpace(found.GetAttributeValue(a.opts.EmailAttribute)),
+		Name:   strings.TrimSpace(found.GetAttributeValue(a.opts.NameAttribute)),
+		Groups: found.GetAttributeValues(a.opts.GroupAttribute),
+	}, nil
+}
+
+// provision 查找目录条目对应的本地用户，首次登录时创建用户并记录对应关系
+// 用户名已被本地账号占用时拒绝登录，避免目录用户接管同名的本地账号
+func (a *LDAPAuthenticator) provision(ctx context.Context, username string, entry *ldapEntry) (*models.User, error) {
+	subject := strings.ToLower(entry.DN)
+	identity, err := a.identities.FindByProviderSubject(ctx, LDAPIdentityProvider, subject)
+	if err == nil {
+		if err := a.identities.UpdateLastLogin(ctx, identity.ID, time.Now()); err != nil {
+			return nil, err
+		}
+		user, err := a.users.FindByID(ctx, identity.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return user, err
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if entry.Email == "" {
+		return nil, errors.New("目录中的用户缺少邮箱，无法创建账号")
+	}
+	if err := validateUsername(username); err != nil {
+		return nil, fmt.Errorf("登录名不能用作本地用户名，无法创建账号: %w", err)
+	}
+
+	var user *models.User
+	err = a.users.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewUserRepository(tx)
+		if _, err := txRepo.FindByUsername(ctx, username); err == nil {
+			return errors.New("用户名已被本地账号使用，请联系管理员")
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if _, err := txRepo.FindByEmail(ctx, entry.Email); err == nil {
+			return errors.New("邮箱已被本地账号使用，请联系管理员")
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 目录中的邮箱由管理员维护，视为已验证
+		now := time.Now()
+		newUser := &models.User{
+			Username:        username,
+			Email:           entry.Email,
+			Nickname:        entry.Name,
+			EmailVerifiedAt: &now,
+		}
+		if len([]rune(newUser.Nickname)) > 50 {
+			newUser.Nickname = string([]rune(newUser.Nickname)[:50])
+		}
+		if err := createUserWithDefaultRole(ctx, tx, newUser); err != nil {
+			return err
+		}
+		if err := repositories.NewIdentityRepository(tx).Create(ctx, &models.Identity{
+			UserID:      newUser.ID,
+			Provider:    LDAPIdentityProvider,
+			Subject:     subject,
+			Email:       entry.Email,
+			Username:    username,
+			LastLoginAt: &now,
+		}); err != nil {
+			return err
+		}
+
+		user = newUser
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncRoles 按目录中的组同步用户的角色，未匹配任何组时为普通用户
+func (a *LDAPAuthenticator) syncRoles(ctx context.Context, user *models.User, groups []string) error {
+	if len(a.opts.GroupRoles) == 0 {
+		return nil
+	}
+
+	names := []string{models.RoleUser}
+	seen := map[string]bool{models.RoleUser: true}
+	for _, group := range groups {
+		role, ok := a.opts.GroupRoles[strings.ToLower(strings.TrimSpace(group))]
+		if ok && !seen[role] {
+			seen[role] = true
+			names = append(names, role)
+		}
+	}
+	roles, err := a.roles.FindByNames(ctx, names)
+	if err != nil {
+		return err
+	}
+	return a.users.ReplaceRoles(ctx, user, roles)
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+)
+
+// LDAP 协议操作的应用标签
+const (
+	ldapBindRequest       ber.Tag = 0
+	ldapBindResponse      ber.Tag = 1
+	ldapUnbindRequest     ber.Tag = 2
+	ldapSearchRequest     ber.Tag = 3
+	ldapSearchResultEntry ber.Tag = 4
+	ldapSearchResultDone  ber.Tag = 5
+
+	ldapFilterEqualityMatch ber.Tag = 3
+
+	ldapResultSuccess            int64 = 0
+	ldapResultInvalidCredentials int64 = 49
+)
+
+const (
+	ldapStubServiceDN       = "cn=service,dc=example,dc=com"
+	ldapStubServicePassword = "service-password"
+)
+
+type ldapStubUser struct {
+	DN       string
+	Password string
+	Mail     string
+	Name     string
+	Groups   []string
+}
+
+// ldapStub 测试中启动的最小 LDAP 服务器，只支持简单绑定和按 uid 的相等过滤查找
+type ldapStub struct {
+	listener net.Listener
+
+	mu    sync.Mutex
+	users map[string]ldapStubUser // uid -> 目录条目
+}
+
+func newLDAPStub(t *testing.T, users map[string]ldapStubUser) *ldapStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动 LDAP 测试服务器失败: %v", err)
+	}
+	stub := &ldapStub{listener: listener, users: users}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *ldapStub) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// setUser 修改目录中的条目，模拟管理员在目录中调整用户
+func (s *ldapStub) setUser(uid string, user ldapStubUser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[uid] = user
+}
+
+func (s *ldapStub) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldapBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			code := ldapResultInvalidCredentials
+			if s.checkPassword(dn, op.Children[2].Data.String()) {
+				code = ldapResultSuccess
+			}
+			conn.Write(ldapResponse(messageID, ldapBindResponse, code).Bytes())
+		case ldapSearchRequest:
+			if user, ok := s.search(op.Children[6]); ok {
+				conn.Write(ldapEntryPacket(messageID, user).Bytes())
+			}
+			conn.Write(ldapResponse(messageID, ldapSearchResultDone, ldapResultSuccess).Bytes())
+		case ldapUnbindRequest:
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (s *ldapStub) checkPassword(dn, password string) bool {
+	if dn == ldapStubServiceDN {
+		return password == ldapStubServicePassword
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.DN == dn && user.Password == password {
+			return true
+		}
+	}
+	return false
+}
+
+// search 只匹配 (uid=value) 形式的过滤条件，其他过滤条件找不到任何条目
+func (s *ldapStub) search(filter *ber.Packet) (ldapStubUser, bool) {
+	if filter.Tag != ldapFilterEqualityMatch || len(filter.Children) != 2 || filter.Children[0].Data.String() != "uid" {
+		return ldapStubUser{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[filter.Children[1].Data.String()]
+	return user, ok
+}
+
+func ldapResponse(messageID int64, tag ber.Tag, code int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, ""))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	packet.AppendChild(result)
+	return packet
+}
+
+func ldapEntryPacket(messageID int64, user ldapStubUser) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, ""))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchResultEntry, nil, "")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, user.DN, ""))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	addAttribute := func(name string, values ...string) {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	if user.Mail != "" {
+		addAttribute("mail", user.Mail)
+	}
+	addAttribute("displayName", user.Name)
+	addAttribute("memberOf", user.Groups...)
+	entry.AppendChild(attributes)
+	packet.AppendChild(entry)
+	return packet
+}
+
+const ldapAdminsGroup = "CN=Admins,OU=Groups,DC=example,DC=com"
+
+type ldapFixture struct {
+	stub       *ldapStub
+	opts       LDAPOptions
+	users      *repositories.UserRepository
+	identities *repositories.IdentityRepository
+	roles      *repositories.RoleRepository
+}
+
+func newLDAPFixture(t *testing.T) *ldapFixture {
+	t.Helper()
+	stub := newLDAPStub(t, map[string]ldapStubUser{
+		"alice":  {DN: "uid=alice,ou=People,dc=example,dc=com", Password: "alice-password", Mail: "alice@example.com", Name: "Alice", Groups: []string{ldapAdminsGroup}},
+		"carol":  {DN: "uid=carol,ou=People,dc=example,dc=com", Password: "carol-password", Mail: "carol@example.com", Name: "Carol"},
+		"local":  {DN: "uid=local,ou=People,dc=example,dc=com", Password: "local-password", Mail: "local@example.com"},
+		"nomail": {DN: "uid=nomail,ou=People,dc=example,dc=com", Password: "nomail-password"},
+	})
+	db := newTestDB(t)
+	createTestUser(t, db, "local", "local-user@example.com", "database-password")
+
+	return &ldapFixture{
+		stub:       stub,
+		users:      repositories.NewUserRepository(db),
+		identities: repositories.NewIdentityRepository(db),
+		roles:      repositories.NewRoleRepository(db),
+		opts: LDAPOptions{
+			URL:            stub.URL(),
+			BindDN:         ldapStubServiceDN,
+			BindPassword:   ldapStubServicePassword,
+			BaseDN:         "dc=example,dc=com",
+			UserFilter:     "(uid=%s)",
+			EmailAttribute: "mail",
+			NameAttribute:  "displayName",
+			GroupAttribute: "memberOf",
+			GroupRoles:     map[string]string{strings.ToLower(ldapAdminsGroup): models.RoleAdmin},
+			Timeout:        2 * time.Second,
+		},
+	}
+}
+
+func (f *ldapFixture) authenticator(opts LDAPOptions) *LDAPAuthenticator {
+	return NewLDAPAuthenticator(opts, f.users, f.identities, f.roles)
+}
+
+func (f *ldapFixture) roleNames(t *testing.T, userID uint) []string {
+	t.Helper()
+	user, err := f.users.FindByIDWithRoles(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("查找用户角色失败: %v", err)
+	}
+	return user.RoleNames()
+}
+
+func hasRole(names []string, role string) bool {
+	for _, name := range names {
+		if name == role {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLDAPAuthenticator(t *testing.T) {
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		modify     func(opts *LDAPOptions)
+		wantErr    error
+		wantErrMsg string
+		check      func(t *testing.T, f *ldapFixture, user *models.User)
+	}{
+		{
+			name:     "首次登录创建本地用户并按组同步角色",
+			username: "alice",
+			password: "alice-password",
+			check: func(t *testing.T, f *ldapFixture, user *models.User) {
+				if user.Username != "alice" || user.Email != "alice@example.com" || user.Nickname != "Alice" {
+					t.Errorf("创建的用户不正确: %+v", user)
+				}
+				if user.HasPassword() || !user.EmailVerified() {
+					t.Errorf("目录用户不应有本地密码，邮箱应视为已验证: %+v", user)
+				}
+				if roles := f.roleNames(t, user.ID); !hasRole(roles, models.RoleAdmin) || !hasRole(roles, models.RoleUser) {
+					t.Errorf("按组映射的角色不正确: %v", roles)
+				}
+				identity, err := f.identities.FindByProviderSubject(context.Background(), LDAPIdentityProvider, "uid=alice,ou=people,dc=example,dc=com")
+				if err != nil || identity.UserID != user.ID {
+					t.Errorf("目录条目的对应关系不正确: %+v, %v", identity, err)
+				}
+			},
+		},
+		{
+			name:     "不在映射组中的用户只有普通用户角色",
+			username: "carol",
+			password: "carol-password",
+			check: func(t *testing.T, f *ldapFixture, user *models.User) {
+				if roles := f.roleNames(t, user.ID); len(roles) != 1 || roles[0] != models.RoleUser {
+					t.Errorf("角色不正确: %v", roles)
+				}
+			},
+		},
+		{
+			name:     "密码错误",
+			username: "alice",
+			password: "wrong-password",
+			wantErr:  ErrWrongPassword,
+		},
+		{
+			name:     "空密码不会被当作匿名绑定",
+			username: "alice",
+			password: "",
+			wantErr:  ErrWrongPassword,
+		},
+		{
+			name:     "目录中没有该用户",
+			username: "nobody",
+			password: "password",
+			wantErr:  ErrUserNotFound,
+		},
+		{
+			name:     "用户名中的过滤条件特殊字符被转义",
+			username: "*",
+			password: "password",
+			wantErr:  ErrUserNotFound,
+		},
+		{
+			name:       "目录用户不能接管同名的本地账号",
+			username:   "local",
+			password:   "local-password",
+			wantErrMsg: "本地账号",
+			check: func(t *testing.T, f *ldapFixture, user *models.User) {
+				if _, err := f.identities.FindByProviderSubject(context.Background(), LDAPIdentityProvider, "uid=local,ou=people,dc=example,dc=com"); err == nil {
+					t.Error("不应为本地账号记录目录条目的对应关系")
+				}
+			},
+		},
+		{
+			name:       "目录中的用户缺少邮箱",
+			username:   "nomail",
+			password:   "nomail-password",
+			wantErrMsg: "缺少邮箱",
+		},
+		{
+			name:       "服务账号绑定失败视为后端故障",
+			username:   "alice",
+			password:   "alice-password",
+			modify:     func(opts *LDAPOptions) { opts.BindPassword = "wrong" },
+			wantErrMsg: "服务账号绑定失败",
+		},
+		{
+			name:       "服务器不可用视为后端故障",
+			username:   "alice",
+			password:   "alice-password",
+			modify:     func(opts *LDAPOptions) { opts.URL = "ldap://127.0.0.1:1" },
+			wantErrMsg: "连接 LDAP 服务器失败",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newLDAPFixture(t)
+			opts := f.opts
+			if tt.modify != nil {
+				tt.modify(&opts)
+			}
+
+			user, err := f.authenticator(opts).Authenticate(context.Background(), tt.username, tt.password)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+			case tt.wantErrMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("期望包含 %q 的错误，实际为 %v", tt.wantErrMsg, err)
+				}
+				// 后端故障和账号冲突不能被当作用户不存在或密码错误，否则认证链会掩盖问题
+				if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrWrongPassword) {
+					t.Fatalf("不应返回用户不存在或密码错误: %v", err)
+				}
+			case err != nil:
+				t.Fatalf("认证失败: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, f, user)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticatorSyncsRolesOnEveryLogin(t *testing.T) {
+	f := newLDAPFixture(t)
+	ldap := f.authenticator(f.opts)
+	ctx := context.Background()
+
+	first, err := ldap.Authenticate(ctx, "alice", "alice-password")
+	if err != nil {
+		t.Fatalf("首次登录失败: %v", err)
+	}
+	if !hasRole(f.roleNames(t, first.ID), models.RoleAdmin) {
+		t.Fatal("首次登录应获得管理员角色")
+	}
+
+	// 管理员在目录中把用户移出组后，下次登录撤销对应的角色
+	f.stub.setUser("alice", ldapStubUser{DN: "uid=alice,ou=People,dc=example,dc=com", Password: "alice-password", Mail: "alice@example.com", Name: "Alice"})
+	second, err := ldap.Authenticate(ctx, "alice", "alice-password")
+	if err != nil {
+		t.Fatalf("再次登录失败: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("再次登录应使用同一本地用户，实际为 %d 和 %d", first.ID, second.ID)
+	}
+	if roles := f.roleNames(t, second.ID); hasRole(roles, models.RoleAdmin) {
+		t.Fatalf("移出组后不应保留管理员角色: %v", roles)
+	}
+}
+
+func TestLDAPAuthenticatorInChain(t *testing.T) {
+	f := newLDAPFixture(t)
+	chain := AuthenticatorChain{
+		NewDatabaseAuthenticator(f.users, newTestHasher()),
+		f.authenticator(f.opts),
+	}
+	ctx := context.Background()
+
+	// 本地账号使用数据库密码登录，同名目录用户的密码不能登录本地账号
+	if user, err := chain.Authenticate(ctx, "local", "database-password"); err != nil || user.Username != "local" {
+		t.Fatalf("本地账号登录失败: %+v, %v", user, err)
+	}
+	if _, err := chain.Authenticate(ctx, "local", "local-password"); err == nil {
+		t.Fatal("目录密码不应能登录同名的本地账号")
+	}
+	// 数据库中没有的用户由目录认证
+	if user, err := chain.Authenticate(ctx, "carol", "carol-password"); err != nil || user.Username != "carol" {
+		t.Fatalf("目录用户登录失败: %+v, %v", user, err)
+	}
+	// 目录创建的用户没有本地密码，目录密码错误时返回密码错误
+	if _, err := chain.Authenticate(ctx, "carol", "wrong-password"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("期望错误 %v，实际为 %v", ErrWrongPassword, err)
+	}
+
+	// 目录不可用时本地账号仍然可以登录
+	down := f.opts
+	down.URL = "ldap://127.0.0.1:1"
+	chain = AuthenticatorChain{f.authenticator(down), NewDatabaseAuthenticator(f.users, newTestHasher())}
+	if _, err := chain.Authenticate(ctx, "local", "database-password"); err != nil {
+		t.Fatalf("目录不可用时本地账号登录失败: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+
+	"github.com/plusone/models"
+)
+
+// LoginCompleter 各登录方式 (密码、外部身份、SAML、通行密钥) 在第一步认证通过后共用的收尾流程，
+// 保证邮箱验证、双因素认证等登录策略对每种登录方式都生效
+type LoginCompleter struct {
+	tokens *TokenService
+	mfa    *MFAService
+
+	requireVerifiedEmail bool // 为 true 时拒绝邮箱未验证的用户登录
+}
+
+// NewLoginCompleter 创建登录收尾流程实例
+func NewLoginCompleter(tokens *TokenService, mfa *MFAService, requireVerifiedEmail bool) *LoginCompleter {
+	return &LoginCompleter{tokens: tokens, mfa: mfa, requireVerifiedEmail: requireVerifiedEmail}
+}
+
+// Complete 检查登录策略后完成登录，firstFactor 为第一步使用的认证方式 (utils.AMR*)
+// 用户启用了双因素认证时返回 MFA 挑战令牌，否则直接签发令牌；
+// multiFactor 为 true 表示第一步本身已满足多因素要求 (例如经过用户验证的通行密钥)，不再要求 TOTP
+func (c *LoginCompleter) Complete(ctx context.Context, user *models.User, client ClientInfo, firstFactor string, multiFactor bool) (*LoginResult, error) {
+	if c.requireVerifiedEmail && !user.EmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.TOTPEnabled() && !multiFactor {
+		mfaToken, err := c.mfa.CreateChallenge(ctx, user.ID, firstFactor)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := c.tokens.IssueTokens(ctx, user.ID, client, []string{firstFactor})
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
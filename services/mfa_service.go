@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// MFAChallengeTTL 登录第一步返回的 MFA 挑战令牌的有效期
+	MFAChallengeTTL = 5 * time.Minute
+	// mfaChallengeMaxAttempts 单个挑战令牌允许的最大验证失败次数
+	mfaChallengeMaxAttempts = 5
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+
+	// 同一用户第二因素 (验证码或恢复码) 的失败次数限制，登录、重新验证身份和 MFA 管理共用
+	mfaMaxFailures     = 5
+	mfaFailureWindow   = 15 * time.Minute
+	mfaLockoutDuration = 15 * time.Minute
+
+	mfaChallengeKeyPrefix = "mfa_challenge:"
+	totpLastStepKeyPrefix = "totp_last_step:"
+	mfaFailuresKeyPrefix  = "mfa_failures:user:"
+	mfaLockKeyPrefix      = "mfa_lock:user:"
+)
+
+var (
+	ErrInvalidMFAChallenge = errors.New("MFA 验证已过期，请重新登录")
+	ErrInvalidMFACode      = errors.New("验证码错误")
+	ErrInvalidRecoveryCode = errors.New("恢复码无效或已使用")
+	ErrTOTPNotEnabled      = errors.New("未启用双因素认证")
+)
+
+// MFALockedError 第二因素验证失败次数过多，RetryAfter 之后才能再次尝试
+type MFALockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *MFALockedError) Error() string {
+	return fmt.Sprintf("验证码错误次数过多，双因素认证已被临时锁定，请在 %d 秒后重试", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// totpAcceptScript 原子地记录已使用的时间步，同一时间步或更早的验证码不能再次使用
+var totpAcceptScript = redis.NewScript(`
+local last = tonumber(redis.call('GET', KEYS[1]) or '-1')
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// MFAService 双因素认证服务
+type MFAService struct {
+	userRepo     *repositories.UserRepository
+	recoveryRepo *repositories.RecoveryCodeRepository
+	rdb          *redis.Client
+	issuer       string
+}
+
+// NewMFAService 创建双因素认证服务实例，issuer 显示在验证器应用中
+func NewMFAService(userRepo *repositories.UserRepository, recoveryRepo *repositories.RecoveryCodeRepository, rdb *redis.Client, issuer string) *MFAService {
+	return &MFAService{
+		userRepo:     userRepo,
+		recoveryRepo: recoveryRepo,
+		rdb:          rdb,
+		issuer:       issuer,
+	}
+}
+
+// BeginTOTPEnrollment 开始绑定 TOTP，生成待确认的密钥和 otpauth:// URI
+func (s *MFAService) BeginTOTPEnrollment(ctx context.Context, userID uint) (secret, uri string, err error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled() {
+		return "", "", errors.New("已启用双因素认证，请先停用")
+	}
+
+	secret, err = utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.userRepo.UpdateTOTP(ctx, userID, secret, nil); err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPURI(s.issuer, user.Username, secret), nil
+}
+
+// ConfirmTOTPEnrollment 使用验证码确认绑定，成功后启用 TOTP 并返回一组新的恢复码
+func (s *MFAService) ConfirmTOTPEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled() {
+		return nil, errors.New("已启用双因素认证")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先开始绑定双因素认证")
+	}
+
+	if err := s.guardSecondFactor(ctx, user.ID, func() error {
+		return s.acceptTOTP(ctx, user, code)
+	}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.userRepo.UpdateTOTP(ctx, userID, user.TOTPSecret, &now); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(ctx, userID)
+}
+
+// DisableTOTP 停用 TOTP，需要提供有效的验证码或恢复码
+func (s *MFAService) DisableTOTP(ctx context.Context, userID uint, code, recoveryCode string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.VerifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateTOTP(ctx, userID, "", nil); err != nil {
+		return err
+	}
+	return s.recoveryRepo.Replace(ctx, userID, nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废，需要提供有效的验证码
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled() {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err := s.guardSecondFactor(ctx, user.ID, func() error {
+		return s.acceptTOTP(ctx, user, code)
+	}); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(ctx, userID)
+}
+
+// VerifySecondFactor 验证 TOTP 验证码或一次性恢复码，二者提供其一即可
+// 同一用户的失败次数跨登录挑战和各个调用方累计，达到阈值后返回 *MFALockedError
+func (s *MFAService) VerifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	if !user.TOTPEnabled() {
+		return ErrTOTPNotEnabled
+	}
+	if code == "" && recoveryCode == "" {
+		return errors.New("请提供验证码或恢复码")
+	}
+	return s.guardSecondFactor(ctx, user.ID, func() error {
+		if code != "" {
+			return s.acceptTOTP(ctx, user, code)
+		}
+		affected, err := s.recoveryRepo.MarkUsed(ctx, user.ID, hashRecoveryCode(recoveryCode), time.Now())
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrInvalidRecoveryCode
+		}
+		return nil
+	})
+}
+
+// Unlock 解除用户第二因素的锁定并清除失败记录
+func (s *MFAService) Unlock(ctx context.Context, userID uint) error {
+	id := strconv.FormatUint(uint64(userID), 10)
+	return s.rdb.Del(ctx, mfaFailuresKeyPrefix+id, mfaLockKeyPrefix+id).Err()
+}
+
+// guardSecondFactor 在用户未被锁定时执行 verify，验证码或恢复码错误时累计失败次数，成功后清除失败记录
+func (s *MFAService) guardSecondFactor(ctx context.Context, userID uint, verify func() error) error {
+	id := strconv.FormatUint(uint64(userID), 10)
+	locked, err := s.rdb.PTTL(ctx, mfaLockKeyPrefix+id).Result()
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return &MFALockedError{RetryAfter: locked}
+	}
+
+	err = verify()
+	if err == nil {
+		return s.rdb.Del(ctx, mfaFailuresKeyPrefix+id).Err()
+	}
+	if !errors.Is(err, ErrInvalidMFACode) && !errors.Is(err, ErrInvalidRecoveryCode) {
+		return err
+	}
+
+	n, scriptErr := loginFailureScript.Run(ctx, s.rdb,
+		[]string{mfaFailuresKeyPrefix + id, mfaLockKeyPrefix + id},
+		mfaFailureWindow.Milliseconds(), mfaMaxFailures, mfaLockoutDuration.Milliseconds(), 0, 0,
+	).Int()
+	if scriptErr != nil {
+		return scriptErr
+	}
+	if n >= mfaMaxFailures {
+		logger.CtxWarnf(ctx, "第二因素验证失败次数过多，锁定用户: %d", userID)
+		return &MFALockedError{RetryAfter: mfaLockoutDuration}
+	}
+	return err
+}
+
+// CreateChallenge 为已通过第一步验证的用户创建 MFA 挑战令牌，firstFactor 为第一步使用的认证方式
+func (s *MFAService) CreateChallenge(ctx context.Context, userID uint, firstFactor string) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	key := mfaChallengeKeyPrefix + utils.HashToken(token)
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userID, "first_factor", firstFactor, "attempts", 0)
+		pipe.Expire(ctx, key, MFAChallengeTTL)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteChallenge 使用挑战令牌和第二因素完成登录，返回用户和两步使用的认证方式
+// 挑战令牌验证成功后立即作废，失败次数过多也会作废
+func (s *MFAService) CompleteChallenge(ctx context.Context, challengeToken, code, recoveryCode string) (*models.User, []string, error) {
+	key := mfaChallengeKeyPrefix + utils.HashToken(challengeToken)
+
+	fields, err := s.rdb.HMGet(ctx, key, "user_id", "first_factor").Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	rawUserID, _ := fields[0].(string)
+	userID, err := strconv.ParseUint(rawUserID, 10, 64)
+	if err != nil {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	firstFactor, _ := fields[1].(string)
+	if firstFactor == "" {
+		firstFactor = utils.AMRPassword
+	}
+
+	attempts, err := s.rdb.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		s.rdb.Del(ctx, key)
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.findUser(ctx, uint(userID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.VerifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		return nil, nil, err
+	}
+
+	// 只有删除成功的一方完成登录，防止同一挑战令牌被并发使用
+	deleted, err := s.rdb.Del(ctx, key).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if deleted == 0 {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	return user, []string{firstFactor, utils.AMROTP}, nil
+}
+
+// acceptTOTP 验证 TOTP 验证码，并拒绝已使用过的时间步
+func (s *MFAService) acceptTOTP(ctx context.Context, user *models.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	ttl := utils.TOTPPeriod * time.Duration(2*utils.TOTPSkew+1)
+	accepted, err := totpAcceptScript.Run(ctx, s.rdb,
+		[]string{totpLastStepKeyPrefix + strconv.FormatUint(uint64(user.ID), 10)},
+		step, ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if accepted != 1 {
+		return errors.New("验证码已使用，请等待下一个验证码")
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成并保存一组新的恢复码，返回明文
+func (s *MFAService) generateRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	plaintexts := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(secret[:5] + "-" + secret[5:10])
+		plaintexts = append(plaintexts, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := s.recoveryRepo.Replace(ctx, userID, records); err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+	return plaintexts, nil
+}
+
+// findUser 查找用户
+func (s *MFAService) findUser(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// hashRecoveryCode 规范化恢复码 (忽略大小写、空格和连字符) 后计算哈希
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return utils.HashToken(normalized)
+}
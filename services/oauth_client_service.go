@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"gorm.io/gorm"
+)
+
+// oauthClientIDBytes client_id 的随机字节数，编码后为 32 位十六进制字符
+const oauthClientIDBytes = 16
+
+// supportedGrantTypes 授权服务器支持的授权类型
+var supportedGrantTypes = []string{
+	models.GrantTypeAuthorizationCode,
+	models.GrantTypeRefreshToken,
+	models.GrantTypeClientCredentials,
+}
+
+// oidcScopes OpenID Connect 作用域
+var oidcScopes = []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail}
+
+// OAuthClientService OAuth 客户端注册服务
+type OAuthClientService struct {
+	repo *repositories.OAuthRepository
+}
+
+// NewOAuthClientService 创建 OAuth 客户端注册服务实例
+func NewOAuthClientService(repo *repositories.OAuthRepository) *OAuthClientService {
+	return &OAuthClientService{repo: repo}
+}
+
+// Create 注册客户端，机密客户端的密钥明文只会返回这一次
+// grantTypes 为空时默认使用授权码和刷新令牌；作用域只能是自助类作用域或系统内置权限
+func (s *OAuthClientService) Create(ctx context.Context, name string, redirectURIs, scopes, grantTypes []string, confidential bool) (*models.OAuthClient, string, error) {
+	grantTypes = uniqueStrings(grantTypes)
+	if len(grantTypes) == 0 {
+		grantTypes = []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}
+	}
+	for _, grantType := range grantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return nil, "", fmt.Errorf("不支持的授权类型: %s", grantType)
+		}
+	}
+	if slices.Contains(grantTypes, models.GrantTypeRefreshToken) && !slices.Contains(grantTypes, models.GrantTypeAuthorizationCode) {
+		return nil, "", errors.New("refresh_token 必须与 authorization_code 一起使用")
+	}
+	if !confidential && slices.Contains(grantTypes, models.GrantTypeClientCredentials) {
+		return nil, "", errors.New("公开客户端不能使用 client_credentials")
+	}
+
+	redirectURIs = uniqueStrings(redirectURIs)
+	if slices.Contains(grantTypes, models.GrantTypeAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, "", errors.New("授权码模式至少需要一个回调地址")
+	}
+	for _, redirectURI := range redirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, "", err
+		}
+	}
+
+	scopes = uniqueStrings(scopes)
+	if len(scopes) == 0 {
+		return nil, "", errors.New("至少需要一个作用域")
+	}
+	for _, scope := range scopes {
+		if !isGrantableScope(scope) {
+			return nil, "", fmt.Errorf("无效的作用域: %s", scope)
+		}
+	}
+
+	idBytes := make([]byte, oauthClientIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, "", err
+	}
+	client := &models.OAuthClient{
+		ClientID:     hex.EncodeToString(idBytes),
+		Name:         name,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		GrantTypes:   strings.Join(grantTypes, " "),
+	}
+
+	var secret string
+	if confidential {
+		var err error
+		if secret, err = utils.GenerateRandomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := s.repo.CreateClient(ctx, client); err != nil {
+		return nil, "", fmt.Errorf("注册客户端失败: %w", err)
+	}
+	return client, secret, nil
+}
+
+// List 列出全部客户端
+func (s *OAuthClientService) List(ctx context.Context) ([]models.OAuthClient, error) {
+	return s.repo.ListClients(ctx)
+}
+
+// Delete 删除客户端及用户对其的全部授权，客户端持有的刷新令牌随之失效
+func (s *OAuthClientService) Delete(ctx context.Context, id uint) error {
+	return s.repo.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewOAuthRepository(tx)
+		affected, err := txRepo.DeleteClient(ctx, id)
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return errors.New("客户端不存在")
+		}
+		return txRepo.DeleteConsentsByClient(ctx, id)
+	})
+}
+
+// OAuthScopes 返回可以授予 OAuth 客户端的全部作用域：OpenID Connect 作用域、自助类作用域和系统内置权限
+func OAuthScopes() []string {
+	scopes := append(append([]string{}, oidcScopes...), selfServiceScopes...)
+	for _, perm := range builtinPermissions {
+		scopes = append(scopes, perm.Code)
+	}
+	return scopes
+}
+
+// isGrantableScope 判断作用域是否可以授予 OAuth 客户端
+func isGrantableScope(scope string) bool {
+	return slices.Contains(OAuthScopes(), scope)
+}
+
+// validateRedirectURI 校验回调地址：必须是不含片段的绝对地址
+// 只接受 https、本机回环地址上的 http，以及原生应用使用的私有 scheme (RFC 8252，形如 com.example.app)
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return fmt.Errorf("无效的回调地址: %s", redirectURI)
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("无效的回调地址: %s", redirectURI)
+		}
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("http 回调地址只能使用本机回环地址: %s", redirectURI)
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("不支持的回调地址 scheme: %s", redirectURI)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,870 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// oauthCodeTTL 授权码的有效期，授权码只能使用一次
+	oauthCodeTTL = 5 * time.Minute
+
+	oauthCodeKeyPrefix    = "oauth_code:"
+	oauthRefreshKeyPrefix = "oauth_refresh:"
+	oauthGrantKeyPrefix   = "oauth_grant:" // 用户对某个客户端的全部刷新令牌哈希，撤销授权时使用
+)
+
+// OAuth 2.0 错误码 (RFC 6749 第 4.1.2.1 节和第 5.2 节)
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+)
+
+// OAuthError 按 RFC 6749 返回给客户端的错误，Code 为标准错误码，Description 为可读说明
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// newOAuthError 创建 OAuth 错误
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// AuthorizeRequest 授权端点的请求参数
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// AuthorizationPrompt 授权请求的校验结果
+// ConsentRequired 为 true 时需要用户确认授权；否则前端直接跳转到 RedirectTo (携带授权码或错误)
+type AuthorizationPrompt struct {
+	Client          *models.OAuthClient
+	Scopes          []string
+	ConsentRequired bool
+	RedirectTo      string
+}
+
+// TokenRequest 令牌端点的请求参数
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// OAuthTokenResult 令牌端点签发的令牌，客户端凭证模式没有刷新令牌，授予了 openid 作用域时包含 ID Token
+type OAuthTokenResult struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	ExpiresIn    time.Duration
+	Scopes       []string
+}
+
+// IntrospectionResult 令牌自省结果 (RFC 7662)，Active 为 false 时其余字段均为空
+type IntrospectionResult struct {
+	Active    bool
+	Scope     string
+	ClientID  string
+	Username  string
+	TokenType string
+	Subject   string
+	ExpiresAt int64
+	IssuedAt  int64
+	Audience  []string
+	Issuer    string
+	TokenID   string
+}
+
+// authorizationCode 保存在 Redis 中的授权码信息
+type authorizationCode struct {
+	ClientID         string `json:"client_id"`
+	UserID           uint   `json:"user_id"`
+	RedirectURI      string `json:"redirect_uri"`
+	RedirectURIGiven bool   `json:"redirect_uri_given"` // 授权请求中显式提供了 redirect_uri，换取令牌时必须再次提供
+	Scope            string `json:"scope"`
+	CodeChallenge    string `json:"code_challenge"`
+	Nonce            string `json:"nonce,omitempty"`
+	AuthTime         int64  `json:"auth_time"`
+}
+
+// oauthRefreshRecord 保存在 Redis 中的 OAuth 刷新令牌信息
+type oauthRefreshRecord struct {
+	ClientID     string `json:"client_id"`
+	UserID       uint   `json:"user_id"`
+	Scope        string `json:"scope"`
+	TokenVersion int64  `json:"ver"` // 签发时用户的令牌版本，用户退出所有设备或修改密码后失效
+	IssuedAt     int64  `json:"iat"`
+	AuthTime     int64  `json:"auth_time"`
+}
+
+// oauthGrant 一次授权的内容，用于签发令牌
+type oauthGrant struct {
+	UserID   uint
+	Scopes   []string // 用户授予的完整作用域，访问令牌可以只使用其中一部分
+	Nonce    string
+	AuthTime int64
+}
+
+// authorizeTarget 已通过校验的授权请求
+type authorizeTarget struct {
+	client      *models.OAuthClient
+	redirectURI string
+	scopes      []string
+}
+
+// OAuthService OAuth 2.0 授权服务器：授权码 (PKCE)、客户端凭证、刷新令牌、撤销与自省
+// 同时作为 OpenID Connect 身份提供方签发 ID Token 并提供用户信息
+type OAuthService struct {
+	repo     *repositories.OAuthRepository
+	users    *repositories.UserRepository
+	sessions *repositories.SessionRepository
+	tokens   *TokenService
+	rdb      *redis.Client
+	issuer   string // OpenID Connect 签发者，ID Token 的 iss
+
+	oidcEnabled bool // 未启用 OpenID Connect 身份提供方时不接受 openid 作用域，也不签发 ID Token
+}
+
+// NewOAuthService 创建 OAuth 授权服务实例
+func NewOAuthService(repo *repositories.OAuthRepository, users *repositories.UserRepository, sessions *repositories.SessionRepository, tokens *TokenService, rdb *redis.Client, issuer string, oidcEnabled bool) *OAuthService {
+	return &OAuthService{
+		repo:     repo,
+		users:    users,
+		sessions: sessions,
+		tokens:   tokens,
+		rdb:      rdb,
+		issuer:   issuer,
+
+		oidcEnabled: oidcEnabled,
+	}
+}
+
+// PrepareAuthorization 校验授权请求
+// 用户此前已授权过全部所申请的作用域时直接签发授权码；客户端或回调地址无效时返回错误，不会跳转到不可信的地址
+func (s *OAuthService) PrepareAuthorization(ctx context.Context, principal *Principal, req AuthorizeRequest) (*AuthorizationPrompt, error) {
+	target, err := s.validateAuthorizeRequest(ctx, req)
+	if err != nil {
+		return s.authorizeErrorPrompt(target, req, err)
+	}
+
+	consent, err := s.repo.FindConsent(ctx, principal.UserID, target.client.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && containsAll(consent.ScopeList(), target.scopes) {
+		redirectTo, err := s.issueCode(ctx, principal, target, req)
+		if err != nil {
+			return nil, err
+		}
+		return &AuthorizationPrompt{Client: target.client, Scopes: target.scopes, RedirectTo: redirectTo}, nil
+	}
+
+	return &AuthorizationPrompt{Client: target.client, Scopes: target.scopes, ConsentRequired: true}, nil
+}
+
+// DecideAuthorization 记录用户对授权请求的决定，返回前端应跳转的地址
+// 同意时保存授权记录并签发授权码，拒绝时携带 access_denied 错误跳转回客户端
+func (s *OAuthService) DecideAuthorization(ctx context.Context, principal *Principal, req AuthorizeRequest, approved bool) (string, error) {
+	target, err := s.validateAuthorizeRequest(ctx, req)
+	if err != nil {
+		prompt, err := s.authorizeErrorPrompt(target, req, err)
+		if err != nil {
+			return "", err
+		}
+		return prompt.RedirectTo, nil
+	}
+	if !approved {
+		return errorRedirect(target.redirectURI, newOAuthError(OAuthErrAccessDenied, "用户拒绝了授权"), req.State), nil
+	}
+
+	scopes := target.scopes
+	consent, err := s.repo.FindConsent(ctx, principal.UserID, target.client.ID)
+	if err == nil {
+		scopes = uniqueStrings(append(consent.ScopeList(), scopes...))
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err := s.repo.SaveConsent(ctx, &models.OAuthConsent{
+		UserID:   principal.UserID,
+		ClientID: target.client.ID,
+		Scopes:   strings.Join(scopes, " "),
+	}); err != nil {
+		return "", fmt.Errorf("保存授权记录失败: %w", err)
+	}
+
+	return s.issueCode(ctx, principal, target, req)
+}
+
+// Token 令牌端点，按授权类型签发令牌
+func (s *OAuthService) Token(ctx context.Context, req TokenRequest) (*OAuthTokenResult, error) {
+	if req.GrantType == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 grant_type")
+	}
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials:
+	default:
+		return nil, newOAuthError(OAuthErrUnsupportedGrantType, "不支持的授权类型")
+	}
+	if !client.AllowsGrantType(req.GrantType) {
+		return nil, newOAuthError(OAuthErrUnauthorizedClient, "客户端未被允许使用该授权类型")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case models.GrantTypeRefreshToken:
+		return s.refresh(ctx, client, req)
+	default:
+		return s.clientCredentials(ctx, client, req)
+	}
+}
+
+// Revoke 撤销令牌 (RFC 7009)
+// 只撤销签发给该客户端的令牌；令牌无效、已过期或属于其他客户端时同样视为成功，不泄露令牌状态
+func (s *OAuthService) Revoke(ctx context.Context, clientID, clientSecret, token string) error {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return newOAuthError(OAuthErrInvalidRequest, "缺少 token")
+	}
+
+	hash := utils.HashToken(token)
+	record, err := s.findRefreshToken(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		if record.ClientID != client.ClientID {
+			return nil
+		}
+		_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, oauthRefreshKeyPrefix+hash)
+			pipe.SRem(ctx, oauthGrantKey(record.UserID, record.ClientID), hash)
+			return nil
+		})
+		return err
+	}
+
+	claims, err := s.tokens.ValidateAccessToken(ctx, token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return nil
+	}
+	return s.tokens.RevokeAccessToken(ctx, claims)
+}
+
+// Introspect 令牌自省 (RFC 7662)，只对 OAuth 签发给调用方客户端本身的访问令牌和刷新令牌返回 active
+// 登录会话、个人访问令牌和其他客户端的令牌不对调用方公开
+func (s *OAuthService) Introspect(ctx context.Context, clientID, clientSecret, token string) (*IntrospectionResult, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 token")
+	}
+
+	hash := utils.HashToken(token)
+	record, err := s.findRefreshToken(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		if record.ClientID != client.ClientID {
+			return &IntrospectionResult{}, nil
+		}
+		return s.introspectRefreshToken(ctx, hash, record)
+	}
+
+	claims, err := s.tokens.ValidateAccessToken(ctx, token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return &IntrospectionResult{}, nil
+	}
+	result := &IntrospectionResult{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Subject:   claims.ClientID,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.UserID != 0 {
+		user, err := s.users.FindByID(ctx, claims.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &IntrospectionResult{}, nil
+			}
+			return nil, err
+		}
+		result.Subject = fmt.Sprint(user.ID)
+		result.Username = user.Username
+	}
+	return result, nil
+}
+
+// ListConsents 列出用户已授权的第三方应用
+func (s *OAuthService) ListConsents(ctx context.Context, userID uint) ([]models.OAuthConsent, error) {
+	return s.repo.ListConsentsByUser(ctx, userID)
+}
+
+// RevokeConsent 撤销用户对客户端的授权，并作废该客户端持有的用户刷新令牌
+// 已签发的访问令牌在到期前仍然有效
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID uint, clientID string) error {
+	client, err := s.repo.FindClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("授权记录不存在")
+		}
+		return err
+	}
+
+	affected, err := s.repo.DeleteConsent(ctx, userID, client.ID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("授权记录不存在")
+	}
+	return s.revokeGrant(ctx, userID, client.ClientID)
+}
+
+// validateAuthorizeRequest 校验授权请求
+// 客户端和回调地址无效时返回的 target 为 nil；其余错误为 *OAuthError 且 target 不为 nil，可以跳转回客户端
+func (s *OAuthService) validateAuthorizeRequest(ctx context.Context, req AuthorizeRequest) (*authorizeTarget, error) {
+	if req.ClientID == "" {
+		return nil, errors.New("缺少 client_id")
+	}
+	client, err := s.repo.FindClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("未知的客户端")
+		}
+		return nil, err
+	}
+
+	redirectURIs := client.RedirectURIList()
+	redirectURI := req.RedirectURI
+	if redirectURI == "" {
+		if len(redirectURIs) != 1 {
+			return nil, errors.New("缺少 redirect_uri")
+		}
+		redirectURI = redirectURIs[0]
+	} else if !slices.Contains(redirectURIs, redirectURI) {
+		return nil, errors.New("redirect_uri 未注册")
+	}
+
+	target := &authorizeTarget{client: client, redirectURI: redirectURI}
+	if req.ResponseType != "code" {
+		return target, newOAuthError(OAuthErrUnsupportedResponseType, "仅支持 response_type=code")
+	}
+	if !client.AllowsGrantType(models.GrantTypeAuthorizationCode) {
+		return target, newOAuthError(OAuthErrUnauthorizedClient, "客户端未被允许使用授权码模式")
+	}
+	if req.CodeChallenge == "" {
+		return target, newOAuthError(OAuthErrInvalidRequest, "缺少 code_challenge，必须使用 PKCE")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return target, newOAuthError(OAuthErrInvalidRequest, "code_challenge_method 仅支持 S256")
+	}
+
+	scopes, err := resolveScopes(client, req.Scope)
+	if err != nil {
+		return target, err
+	}
+	if !s.oidcEnabled && slices.Contains(scopes, models.ScopeOpenID) {
+		if req.Scope != "" {
+			return target, newOAuthError(OAuthErrInvalidScope, "未启用 OpenID Connect，不能申请 openid 作用域")
+		}
+		scopes = slices.DeleteFunc(scopes, func(scope string) bool { return scope == models.ScopeOpenID })
+	}
+	target.scopes = scopes
+	return target, nil
+}
+
+// authorizeErrorPrompt 将授权请求的校验错误转换为结果：可以安全跳转时携带错误跳转回客户端，否则直接返回错误
+func (s *OAuthService) authorizeErrorPrompt(target *authorizeTarget, req AuthorizeRequest, err error) (*AuthorizationPrompt, error) {
+	var oauthErr *OAuthError
+	if target == nil || !errors.As(err, &oauthErr) {
+		return nil, err
+	}
+	return &AuthorizationPrompt{
+		Client:     target.client,
+		RedirectTo: errorRedirect(target.redirectURI, oauthErr, req.State),
+	}, nil
+}
+
+// issueCode 签发授权码，返回携带授权码的回调地址
+func (s *OAuthService) issueCode(ctx context.Context, principal *Principal, target *authorizeTarget, req AuthorizeRequest) (string, error) {
+	authTime, err := s.authTime(ctx, principal)
+	if err != nil {
+		return "", err
+	}
+	code, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(authorizationCode{
+		ClientID:         target.client.ClientID,
+		UserID:           principal.UserID,
+		RedirectURI:      target.redirectURI,
+		RedirectURIGiven: req.RedirectURI != "",
+		Scope:            strings.Join(target.scopes, " "),
+		CodeChallenge:    req.CodeChallenge,
+		Nonce:            req.Nonce,
+		AuthTime:         authTime,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.rdb.Set(ctx, oauthCodeKeyPrefix+utils.HashToken(code), data, oauthCodeTTL).Err(); err != nil {
+		return "", err
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return appendQuery(target.redirectURI, params), nil
+}
+
+// exchangeCode 授权码模式：校验授权码、回调地址和 PKCE 后签发令牌，授权码随即作废
+func (s *OAuthService) exchangeCode(ctx context.Context, client *models.OAuthClient, req TokenRequest) (*OAuthTokenResult, error) {
+	if req.Code == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 code")
+	}
+	data, err := s.rdb.GetDel(ctx, oauthCodeKeyPrefix+utils.HashToken(req.Code)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, newOAuthError(OAuthErrInvalidGrant, "授权码无效或已使用")
+		}
+		return nil, err
+	}
+
+	var code authorizationCode
+	if err := json.Unmarshal(data, &code); err != nil {
+		return nil, fmt.Errorf("解析授权码失败: %w", err)
+	}
+	if code.ClientID != client.ClientID {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "授权码不属于该客户端")
+	}
+	if (code.RedirectURIGiven || req.RedirectURI != "") && req.RedirectURI != code.RedirectURI {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "redirect_uri 与授权请求不一致")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "code_verifier 校验失败")
+	}
+
+	grant := oauthGrant{
+		UserID:   code.UserID,
+		Scopes:   strings.Fields(code.Scope),
+		Nonce:    code.Nonce,
+		AuthTime: code.AuthTime,
+	}
+	return s.issueUserTokens(ctx, client, grant, grant.Scopes)
+}
+
+// refresh 刷新令牌模式：刷新令牌每次使用后轮换，可以申请原作用域的子集
+func (s *OAuthService) refresh(ctx context.Context, client *models.OAuthClient, req TokenRequest) (*OAuthTokenResult, error) {
+	if req.RefreshToken == "" {
+		return nil, newOAuthError(OAuthErrInvalidRequest, "缺少 refresh_token")
+	}
+	hash := utils.HashToken(req.RefreshToken)
+	data, err := s.rdb.GetDel(ctx, oauthRefreshKeyPrefix+hash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌无效或已使用")
+		}
+		return nil, err
+	}
+
+	var record oauthRefreshRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	s.rdb.SRem(ctx, oauthGrantKey(record.UserID, record.ClientID), hash)
+	if record.ClientID != client.ClientID {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "刷新令牌不属于该客户端")
+	}
+
+	version, err := s.tokens.TokenVersion(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if version > record.TokenVersion {
+		return nil, newOAuthError(OAuthErrInvalidGrant, "授权已失效，请重新授权")
+	}
+	if _, err := s.repo.FindConsent(ctx, record.UserID, client.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newOAuthError(OAuthErrInvalidGrant, "授权已被撤销")
+		}
+		return nil, err
+	}
+
+	granted := strings.Fields(record.Scope)
+	scopes := granted
+	if req.Scope != "" {
+		scopes = uniqueStrings(strings.Fields(req.Scope))
+		if !containsAll(granted, scopes) {
+			return nil, newOAuthError(OAuthErrInvalidScope, "申请的作用域超出了原授权范围")
+		}
+	}
+	grant := oauthGrant{UserID: record.UserID, Scopes: granted, AuthTime: record.AuthTime}
+	return s.issueUserTokens(ctx, client, grant, scopes)
+}
+
+// clientCredentials 客户端凭证模式：只有机密客户端可以使用，令牌代表客户端本身，没有刷新令牌
+func (s *OAuthService) clientCredentials(ctx context.Context, client *models.OAuthClient, req TokenRequest) (*OAuthTokenResult, error) {
+	if !client.Confidential() {
+		return nil, newOAuthError(OAuthErrUnauthorizedClient, "公开客户端不能使用客户端凭证模式")
+	}
+	scopes, err := resolveScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokens.IssueOAuthAccessToken(ctx, 0, client.ClientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenResult{AccessToken: accessToken, ExpiresIn: s.tokens.AccessTokenTTL(), Scopes: scopes}, nil
+}
+
+// issueUserTokens 签发代表用户的访问令牌，scopes 为访问令牌的作用域
+// 包含 openid 作用域时同时签发 ID Token；客户端允许时签发保留完整授权范围的刷新令牌
+func (s *OAuthService) issueUserTokens(ctx context.Context, client *models.OAuthClient, grant oauthGrant, scopes []string) (*OAuthTokenResult, error) {
+	userID := grant.UserID
+	accessToken, err := s.tokens.IssueOAuthAccessToken(ctx, userID, client.ClientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	result := &OAuthTokenResult{AccessToken: accessToken, ExpiresIn: s.tokens.AccessTokenTTL(), Scopes: scopes}
+
+	if s.oidcEnabled && slices.Contains(scopes, models.ScopeOpenID) {
+		if result.IDToken, err = s.issueIDToken(ctx, client, grant, scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	if !client.AllowsGrantType(models.GrantTypeRefreshToken) {
+		return result, nil
+	}
+	version, err := s.tokens.TokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(oauthRefreshRecord{
+		ClientID:     client.ClientID,
+		UserID:       userID,
+		Scope:        strings.Join(grant.Scopes, " "),
+		TokenVersion: version,
+		IssuedAt:     time.Now().Unix(),
+		AuthTime:     grant.AuthTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hash := utils.HashToken(refreshToken)
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		grantKey := oauthGrantKey(userID, client.ClientID)
+		pipe.Set(ctx, oauthRefreshKeyPrefix+hash, data, RefreshTokenTTL)
+		pipe.SAdd(ctx, grantKey, hash)
+		pipe.Expire(ctx, grantKey, RefreshTokenTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.RefreshToken = refreshToken
+	return result, nil
+}
+
+// UserInfo 返回访问令牌所代表用户的 OpenID Connect 标准声明 (sub 为用户ID)
+// OAuth 令牌只返回被授予作用域对应的声明，登录会话令牌返回全部声明
+func (s *OAuthService) UserInfo(ctx context.Context, principal *Principal) (string, utils.UserInfoClaims, error) {
+	if principal.UserID == 0 {
+		return "", utils.UserInfoClaims{}, errors.New("令牌不代表任何用户")
+	}
+	user, err := s.users.FindByID(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", utils.UserInfoClaims{}, errors.New("用户不存在")
+		}
+		return "", utils.UserInfoClaims{}, err
+	}
+	return fmt.Sprint(user.ID), userInfoClaims(user, principal.HasScope), nil
+}
+
+// issueIDToken 签发 ID Token，用户声明按授予的作用域填充
+func (s *OAuthService) issueIDToken(ctx context.Context, client *models.OAuthClient, grant oauthGrant, scopes []string) (string, error) {
+	user, err := s.users.FindByID(ctx, grant.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", newOAuthError(OAuthErrInvalidGrant, "用户不存在")
+		}
+		return "", err
+	}
+
+	claims := utils.IDTokenClaims{
+		Nonce:    grant.Nonce,
+		AuthTime: grant.AuthTime,
+		UserInfoClaims: userInfoClaims(user, func(scope string) bool {
+			return slices.Contains(scopes, scope)
+		}),
+	}
+	claims.Subject = fmt.Sprint(user.ID)
+	return s.tokens.IssueIDToken(claims, s.issuer, client.ClientID)
+}
+
+// authTime 返回用户完成登录的时间：优先取访问令牌的 auth_time，其次取所属登录会话的创建时间，都没有时取访问令牌的签发时间
+func (s *OAuthService) authTime(ctx context.Context, principal *Principal) (int64, error) {
+	if principal.Claims == nil {
+		return time.Now().Unix(), nil
+	}
+	if principal.Claims.AuthTime != 0 {
+		return principal.Claims.AuthTime, nil
+	}
+	if principal.Claims.SessionID != 0 {
+		session, err := s.sessions.FindByUser(ctx, principal.UserID, principal.Claims.SessionID)
+		if err == nil {
+			return session.CreatedAt.Unix(), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	}
+	if principal.Claims.IssuedAt != nil {
+		return principal.Claims.IssuedAt.Unix(), nil
+	}
+	return time.Now().Unix(), nil
+}
+
+// introspectRefreshToken 返回刷新令牌的自省结果，用户令牌版本已递增的刷新令牌视为无效
+func (s *OAuthService) introspectRefreshToken(ctx context.Context, hash string, record *oauthRefreshRecord) (*IntrospectionResult, error) {
+	version, err := s.tokens.TokenVersion(ctx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if version > record.TokenVersion {
+		return &IntrospectionResult{}, nil
+	}
+	user, err := s.users.FindByID(ctx, record.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &IntrospectionResult{}, nil
+		}
+		return nil, err
+	}
+	ttl, err := s.rdb.TTL(ctx, oauthRefreshKeyPrefix+hash).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntrospectionResult{
+		Active:    true,
+		Scope:     record.Scope,
+		ClientID:  record.ClientID,
+		Username:  user.Username,
+		TokenType: "refresh_token",
+		Subject:   fmt.Sprint(user.ID),
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		IssuedAt:  record.IssuedAt,
+	}, nil
+}
+
+// authenticateClient 验证客户端身份：机密客户端必须提供正确的密钥，公开客户端不能提供密钥
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, newOAuthError(OAuthErrInvalidClient, "缺少客户端凭证")
+	}
+	client, err := s.repo.FindClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newOAuthError(OAuthErrInvalidClient, "客户端认证失败")
+		}
+		return nil, err
+	}
+
+	if client.Confidential() {
+		if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(clientSecret))) != 1 {
+			return nil, newOAuthError(OAuthErrInvalidClient, "客户端认证失败")
+		}
+	} else if clientSecret != "" {
+		return nil, newOAuthError(OAuthErrInvalidClient, "公开客户端不能使用客户端密钥")
+	}
+	return client, nil
+}
+
+// findRefreshToken 查找 OAuth 刷新令牌，不存在时返回 nil
+func (s *OAuthService) findRefreshToken(ctx context.Context, hash string) (*oauthRefreshRecord, error) {
+	data, err := s.rdb.Get(ctx, oauthRefreshKeyPrefix+hash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var record oauthRefreshRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	return &record, nil
+}
+
+// revokeGrant 删除用户对客户端的全部刷新令牌
+func (s *OAuthService) revokeGrant(ctx context.Context, userID uint, clientID string) error {
+	grantKey := oauthGrantKey(userID, clientID)
+	hashes, err := s.rdb.SMembers(ctx, grantKey).Result()
+	if err != nil {
+		return err
+	}
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, hash := range hashes {
+			pipe.Del(ctx, oauthRefreshKeyPrefix+hash)
+		}
+		pipe.Del(ctx, grantKey)
+		return nil
+	})
+	return err
+}
+
+// userInfoClaims 按作用域从用户信息中提取 OpenID Connect 标准声明
+// preferred_username 为用户名，name 为昵称 (未设置时使用用户名)
+func userInfoClaims(user *models.User, hasScope func(string) bool) utils.UserInfoClaims {
+	var claims utils.UserInfoClaims
+	if hasScope(models.ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.Name = user.Nickname
+		if claims.Name == "" {
+			claims.Name = user.Username
+		}
+	}
+	if hasScope(models.ScopeEmail) && user.Email != "" {
+		verified := user.EmailVerified()
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	return claims
+}
+
+// resolveScopes 解析申请的作用域，未申请时使用客户端允许的全部作用域
+func resolveScopes(client *models.OAuthClient, scope string) ([]string, error) {
+	allowed := client.ScopeList()
+	requested := uniqueStrings(strings.Fields(scope))
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+	for _, scope := range requested {
+		if !slices.Contains(allowed, scope) {
+			return nil, newOAuthError(OAuthErrInvalidScope, "客户端无权申请作用域: "+scope)
+		}
+	}
+	return requested, nil
+}
+
+// verifyCodeChallenge 校验 PKCE: BASE64URL(SHA256(code_verifier)) 必须等于 code_challenge (RFC 7636)
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// errorRedirect 生成携带 OAuth 错误的回调地址
+func errorRedirect(redirectURI string, err *OAuthError, state string) string {
+	params := url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return appendQuery(redirectURI, params)
+}
+
+// appendQuery 在回调地址原有的查询参数之后追加参数
+func appendQuery(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// containsAll 判断 set 是否包含 subset 中的全部元素
+func containsAll(set, subset []string) bool {
+	for _, v := range subset {
+		if !slices.Contains(set, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// oauthGrantKey 用户对客户端的刷新令牌集合的键
+func oauthGrantKey(userID uint, clientID string) string {
+	return fmt.Sprintf("%s%d:%s", oauthGrantKeyPrefix, userID, clientID)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ccojocar/zxcvbn-go"
+	"github.com/plusone/utils"
+)
+
+// userInfoMinLength 用户名或邮箱至少有这么多字符时才检查密码是否包含它们，避免过短的用户名误伤
+const userInfoMinLength = 3
+
+// PasswordPolicyOptions 密码策略配置
+type PasswordPolicyOptions struct {
+	MinLength      int
+	MaxLength      int
+	MaxBytes       int     // 密码的最大字节数，为 0 时不限制；使用 bcrypt 时为 72，多字节字符较多的密码可能先达到该上限
+	MinCharClasses int     // 至少包含的字符类别数：小写字母、大写字母、数字、其他符号
+	MinEntropy     float64 // zxcvbn 估算的最低熵 (bit)，为 0 时不检查
+	RejectUserInfo bool    // 拒绝包含用户名或邮箱的密码
+}
+
+// PasswordPolicyError 密码不符合策略，Violations 列出每一项不满足的要求
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码不符合安全要求: " + strings.Join(e.Violations, "；")
+}
+
+// PasswordPolicy 注册、重置和修改密码时使用的密码强度策略
+type PasswordPolicy struct {
+	opts     PasswordPolicyOptions
+	breached *utils.BreachedPasswords // 为空时不检查泄露密码
+}
+
+// NewPasswordPolicy 创建密码策略实例
+func NewPasswordPolicy(opts PasswordPolicyOptions, breached *utils.BreachedPasswords) *PasswordPolicy {
+	return &PasswordPolicy{opts: opts, breached: breached}
+}
+
+// Validate 校验密码是否满足策略，不满足时返回 *PasswordPolicyError
+// username 和 email 用于拒绝与账号信息相似的密码，也作为强度估算的字典输入
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	length := utf8.RuneCountInString(password)
+	if p.opts.MaxLength > 0 && length > p.opts.MaxLength {
+		// 过长的密码不再做后续检查，强度估算的开销随长度增长
+		return &PasswordPolicyError{Violations: []string{fmt.Sprintf("密码长度不能超过 %d 个字符", p.opts.MaxLength)}}
+	}
+	if p.opts.MaxBytes > 0 && len(password) > p.opts.MaxBytes {
+		return &PasswordPolicyError{Violations: []string{fmt.Sprintf("密码不能超过 %d 字节 (中文等字符每个占 3 字节)", p.opts.MaxBytes)}}
+	}
+
+	var violations []string
+	if length < p.opts.MinLength {
+		violations = append(violations, fmt.Sprintf("密码长度至少为 %d 个字符", p.opts.MinLength))
+	}
+	if charClasses(password) < p.opts.MinCharClasses {
+		violations = append(violations, fmt.Sprintf("密码需要包含小写字母、大写字母、数字、符号中的至少 %d 类", p.opts.MinCharClasses))
+	}
+
+	userInputs := userInfoInputs(username, email)
+	if p.opts.RejectUserInfo && containsUserInfo(password, userInputs) {
+		violations = append(violations, "密码不能包含用户名或邮箱")
+	}
+	if p.opts.MinEntropy > 0 && length > 0 {
+		if strength := zxcvbn.PasswordStrength(password, userInputs); strength.Entropy < p.opts.MinEntropy {
+			violations = append(violations, "密码过于简单，容易被猜到，请避免常见单词、键盘序列和重复字符")
+		}
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, "该密码出现在已泄露的密码库中，请更换")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// charClasses 统计密码包含的字符类别数
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// userInfoInputs 返回小写的用户名、邮箱及邮箱的用户部分
+func userInfoInputs(username, email string) []string {
+	var inputs []string
+	for _, value := range []string{username, email} {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			inputs = append(inputs, value)
+		}
+	}
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && local != "" {
+		inputs = append(inputs, local)
+	}
+	return slices.Compact(inputs)
+}
+
+// containsUserInfo 判断密码 (或其倒序) 与用户名、邮箱是否互相包含
+func containsUserInfo(password string, inputs []string) bool {
+	lowered := strings.ToLower(password)
+	reversed := []rune(lowered)
+	slices.Reverse(reversed)
+	for _, input := range inputs {
+		if utf8.RuneCountInString(input) < userInfoMinLength {
+			continue
+		}
+		if strings.Contains(lowered, input) || strings.Contains(string(reversed), input) {
+			return true
+		}
+		if len(reversed) >= userInfoMinLength && strings.Contains(input, lowered) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/plusone/utils"
+)
+
+func TestPasswordPolicyMaxBytes(t *testing.T) {
+	policy := NewPasswordPolicy(PasswordPolicyOptions{MinLength: 1, MaxLength: 128, MaxBytes: utils.BcryptMaxPasswordBytes}, nil)
+	hasher := utils.NewPasswordHasher(utils.PasswordHasherOptions{Algorithm: utils.PasswordAlgorithmBcrypt, BcryptCost: 4})
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "恰好 72 字节", password: strings.Repeat("a", 72)},
+		{name: "超过 72 字节", password: strings.Repeat("a", 73), wantErr: true},
+		// 25 个汉字占 75 字节，字符数未超过上限
+		{name: "多字节字符超过 72 字节", password: strings.Repeat("密", 25), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "alice", "alice@example.com")
+			if tt.wantErr {
+				var policyErr *PasswordPolicyError
+				if !errors.As(err, &policyErr) {
+					t.Fatalf("期望 *PasswordPolicyError，实际为 %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("密码校验失败: %v", err)
+			}
+			// 通过策略的密码必须能够哈希
+			if _, err := hasher.Hash(tt.password); err != nil {
+				t.Fatalf("通过策略的密码哈希失败: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/plusone/utils/mailer"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const passwordResetRequestKeyPrefix = "password_reset_request:"
+
+var ErrInvalidPasswordResetToken = errors.New("重置链接无效或已过期")
+
+// PasswordResetService 找回密码服务
+type PasswordResetService struct {
+	userRepo *repositories.UserRepository
+	repo     *repositories.PasswordResetTokenRepository
+	tokens   *TokenService
+	hasher   *utils.PasswordHasher
+	policy   *PasswordPolicy
+	mailer   mailer.Mailer
+	rdb      *redis.Client
+	baseURL  string
+	ttl      time.Duration
+
+	requestInterval time.Duration
+}
+
+// NewPasswordResetService 创建找回密码服务实例
+// baseURL 为前端页面地址，重置链接形如 "<baseURL>/reset-password?token=..."；requestInterval 为同一邮箱两次请求的最小间隔
+func NewPasswordResetService(userRepo *repositories.UserRepository, repo *repositories.PasswordResetTokenRepository, tokens *TokenService, hasher *utils.PasswordHasher, policy *PasswordPolicy, m mailer.Mailer, rdb *redis.Client, baseURL string, ttl, requestInterval time.Duration) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo: userRepo,
+		repo:     repo,
+		tokens:   tokens,
+		hasher:   hasher,
+		policy:   policy,
+		mailer:   m,
+		rdb:      rdb,
+		baseURL:  baseURL,
+		ttl:      ttl,
+
+		requestInterval: requestInterval,
+	}
+}
+
+// RequestReset 为邮箱对应的用户生成重置令牌并发送邮件
+// 邮箱不存在时不返回错误，调用方无法据此判断账号是否存在；
+// 邮件在后台发送，避免响应时间暴露账号是否存在；
+// 按邮箱限制请求频率 (与邮箱是否存在无关)，超过频率时返回 ErrTooManyRequests，旧的重置链接不受影响
+func (s *PasswordResetService) RequestReset(ctx context.Context, email string) error {
+	ok, err := s.rdb.SetNX(ctx, passwordResetRequestKeyPrefix+utils.HashToken(utils.NormalizeIdentity(email)), 1, s.requestInterval).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.CtxInfof(ctx, "找回密码的邮箱未注册")
+			return nil
+		}
+		return err
+	}
+
+	plaintext, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	// 同一用户只保留最新的一个重置令牌
+	err = s.repo.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewPasswordResetTokenRepository(tx)
+		if err := txRepo.DeleteByUser(ctx, user.ID); err != nil {
+			return err
+		}
+		return txRepo.Create(ctx, &models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(plaintext),
+			ExpiresAt: time.Now().Add(s.ttl),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("保存重置令牌失败: %w", err)
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("您好 %s，\n\n请在 %d 分钟内打开以下链接重置密码：\n%s/reset-password?token=%s\n\n如果这不是您本人的操作，请忽略本邮件。\n",
+			user.Username, int(s.ttl.Minutes()), s.baseURL, url.QueryEscape(plaintext)),
+	}
+	go func(ctx context.Context) {
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			logger.CtxErrorf(ctx, "发送重置密码邮件失败, userID: %d, error: %v", user.ID, err)
+		}
+	}(context.WithoutCancel(ctx))
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，令牌只能使用一次
+// 成功后撤销用户已签发的全部访问令牌和刷新令牌；新密码不符合密码策略时返回 *PasswordPolicyError
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	record, err := s.repo.FindByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+	if record.UsedAt != nil || record.Expired() {
+		return ErrInvalidPasswordResetToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, record.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+	if err := s.policy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	if err := user.SetPassword(s.hasher, newPassword); err != nil {
+		return err
+	}
+
+	err = s.repo.Transaction(func(tx *gorm.DB) error {
+		affected, err := repositories.NewPasswordResetTokenRepository(tx).MarkUsed(ctx, record.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrInvalidPasswordResetToken
+		}
+		return repositories.NewUserRepository(tx).UpdatePassword(ctx, record.UserID, user.Password)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.tokens.RevokeAllTokens(ctx, record.UserID)
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"gorm.io/gorm"
+)
+
+// patLastUsedInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const patLastUsedInterval = time.Minute
+
+// selfServiceScopes 任何用户都可以授予的自助类作用域
+var selfServiceScopes = []string{models.ScopeUserRead, models.ScopeTokensWrite}
+
+var ErrInvalidPersonalAccessToken = errors.New("无效的个人访问令牌")
+
+// PersonalAccessTokenService 个人访问令牌服务
+type PersonalAccessTokenService struct {
+	repo     *repositories.PersonalAccessTokenRepository
+	userRepo *repositories.UserRepository
+}
+
+// NewPersonalAccessTokenService 创建个人访问令牌服务实例
+func NewPersonalAccessTokenService(repo *repositories.PersonalAccessTokenRepository, userRepo *repositories.UserRepository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// Create 为调用方创建个人访问令牌，返回的明文令牌只会出现这一次
+// 作用域只能是自助类作用域或调用方当前拥有的权限；受作用域限制的调用方不能授予超出自身范围的作用域
+func (s *PersonalAccessTokenService) Create(ctx context.Context, principal *Principal, name string, scopes []string, expiresIn time.Duration) (string, *models.PersonalAccessToken, error) {
+	scopes = uniqueStrings(scopes)
+	for _, scope := range scopes {
+		if strings.Contains(scope, ",") {
+			return "", nil, fmt.Errorf("无效的作用域: %s", scope)
+		}
+		if !slices.Contains(selfServiceScopes, scope) && !slices.Contains(principal.Permissions, scope) {
+			return "", nil, fmt.Errorf("无法授予作用域: %s", scope)
+		}
+		if !principal.HasScope(scope) {
+			return "", nil, fmt.Errorf("无法授予超出当前令牌范围的作用域: %s", scope)
+		}
+	}
+
+	random, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := models.PersonalAccessTokenPrefix + random
+
+	token := &models.PersonalAccessToken{
+		UserID:    principal.UserID,
+		Name:      name,
+		TokenHash: utils.HashToken(plaintext),
+		Scopes:    strings.Join(scopes, ","),
+	}
+	if expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.Create(ctx, token); err != nil {
+		return "", nil, err
+	}
+	return plaintext, token, nil
+}
+
+// List 列出用户的个人访问令牌
+func (s *PersonalAccessTokenService) List(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Revoke 撤销用户的个人访问令牌
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, userID, tokenID uint) error {
+	affected, err := s.repo.DeleteByUser(ctx, userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("令牌不存在")
+	}
+	return nil
+}
+
+// Authenticate 验证个人访问令牌
+// 调用方的有效权限为用户当前权限与令牌作用域的交集
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, plaintext string) (*Principal, error) {
+	token, err := s.repo.FindByHash(ctx, utils.HashToken(plaintext))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, err
+	}
+	if token.Expired() {
+		return nil, errors.New("个人访问令牌已过期")
+	}
+
+	user, err := s.userRepo.FindByIDWithRoles(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, err
+	}
+
+	scopes := token.ScopeList()
+	var permissions []string
+	for _, perm := range user.PermissionCodes() {
+		if slices.Contains(scopes, perm) {
+			permissions = append(permissions, perm)
+		}
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > patLastUsedInterval {
+		if err := s.repo.TouchLastUsed(ctx, token.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Principal{
+		UserID:      user.ID,
+		Roles:       user.RoleNames(),
+		Permissions: permissions,
+		Scopes:      scopes,
+		TokenID:     token.ID,
+	}, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"gorm.io/gorm"
+)
+
+// builtinPermissions 系统内置权限
+var builtinPermissions = []models.Permission{
+	{Code: models.PermUsersRead, Description: "查看用户"},
+	{Code: models.PermUsersWrite, Description: "管理用户"},
+	{Code: models.PermRolesRead, Description: "查看角色"},
+	{Code: models.PermRolesWrite, Description: "分配角色"},
+	{Code: models.PermUsersImpersonate, Description: "模拟用户登录"},
+	{Code: models.PermServiceAccountsWrite, Description: "管理服务账号和 API Key"},
+	{Code: models.PermOAuthClientsWrite, Description: "管理 OAuth 客户端"},
+}
+
+// RoleService 角色权限服务
+type RoleService struct {
+	repo     *repositories.RoleRepository
+	userRepo *repositories.UserRepository
+	hasher   *utils.PasswordHasher // 创建初始管理员时设置密码
+	tokens   *TokenService         // 移除角色后撤销用户的令牌
+}
+
+// NewRoleService 创建角色权限服务实例
+func NewRoleService(repo *repositories.RoleRepository, userRepo *repositories.UserRepository, hasher *utils.PasswordHasher, tokens *TokenService) *RoleService {
+	return &RoleService{
+		repo:     repo,
+		userRepo: userRepo,
+		hasher:   hasher,
+		tokens:   tokens,
+	}
+}
+
+// EnsureDefaults 确保内置权限和角色存在，admin 角色拥有全部内置权限
+func (s *RoleService) EnsureDefaults(ctx context.Context) error {
+	return s.repo.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewRoleRepository(tx)
+
+		perms := make([]models.Permission, len(builtinPermissions))
+		for i, perm := range builtinPermissions {
+			perms[i] = perm
+			if err := txRepo.FirstOrCreatePermission(ctx, &perms[i]); err != nil {
+				return err
+			}
+		}
+
+		admin := &models.Role{Name: models.RoleAdmin, Description: "系统管理员"}
+		if err := txRepo.FirstOrCreate(ctx, admin); err != nil {
+			return err
+		}
+		if err := txRepo.AddPermissions(ctx, admin, perms); err != nil {
+			return err
+		}
+
+		user := &models.Role{Name: models.RoleUser, Description: "普通用户"}
+		return txRepo.FirstOrCreate(ctx, user)
+	})
+}
+
+// BootstrapAdmin 在系统中还没有管理员时创建第一个管理员
+// 同名用户已存在时，只有配置的密码与该用户的密码一致才授予 admin 角色，
+// 否则返回错误，避免抢先注册了该用户名的人成为管理员；password 为空时跳过
+func (s *RoleService) BootstrapAdmin(ctx context.Context, username, password, email string) error {
+	count, err := s.repo.CountUsers(ctx, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if username == "" || password == "" {
+		logger.CtxWarnf(ctx, "系统中没有管理员，请配置 BOOTSTRAP_ADMIN_USERNAME 和 BOOTSTRAP_ADMIN_PASSWORD")
+		return nil
+	}
+
+	return s.userRepo.Transaction(func(tx *gorm.DB) error {
+		txUserRepo := repositories.NewUserRepository(tx)
+		txRoleRepo := repositories.NewRoleRepository(tx)
+
+		roles, err := txRoleRepo.FindByNames(ctx, []string{models.RoleAdmin, models.RoleUser})
+		if err != nil {
+			return err
+		}
+
+		user, err := txUserRepo.FindByUsername(ctx, username)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err := validateUsername(username); err != nil {
+				return fmt.Errorf("初始管理员用户名无效: %w", err)
+			}
+			// 初始管理员由部署方配置，邮箱视为已验证
+			now := time.Now()
+			user = &models.User{Username: username, Email: email, Nickname: username, EmailVerifiedAt: &now}
+			if err := user.SetPassword(s.hasher, password); err != nil {
+				return err
+			}
+			if err := txUserRepo.Create(ctx, user); err != nil {
+				return err
+			}
+		} else if !user.HasPassword() || !user.CheckPassword(password) {
+			return fmt.Errorf("用户 %s 已存在且密码与配置的初始管理员密码不一致，拒绝授予管理员角色，请更换初始管理员用户名", username)
+		}
+
+		if err := txUserRepo.AddRoles(ctx, user, roles); err != nil {
+			return err
+		}
+		logger.CtxInfof(ctx, "已创建初始管理员: %s", username)
+		return nil
+	})
+}
+
+// ListRoles 列出全部角色及其权限
+func (s *RoleService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	return s.repo.List(ctx)
+}
+
+// AssignRoles 将用户的角色替换为给定的角色集合
+// 新增的角色在用户下次刷新令牌或重新登录后生效；移除了角色时撤销用户的全部令牌，
+// 已签发的访问令牌中的角色和权限随即失效，用户需要重新登录
+func (s *RoleService) AssignRoles(ctx context.Context, userID uint, roleNames []string) (*models.User, error) {
+	roles, err := s.repo.FindByNames(ctx, roleNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(uniqueStrings(roleNames)) {
+		return nil, errors.New("包含不存在的角色")
+	}
+
+	user, err := s.userRepo.FindByIDWithRoles(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	// 不允许移除最后一个管理员的 admin 角色
+	if slices.Contains(user.RoleNames(), models.RoleAdmin) && !slices.Contains(roleNames, models.RoleAdmin) {
+		count, err := s.repo.CountUsers(ctx, models.RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 1 {
+			return nil, errors.New("不能移除最后一个管理员")
+		}
+	}
+
+	removed := false
+	for _, name := range user.RoleNames() {
+		if !slices.Contains(roleNames, name) {
+			removed = true
+			break
+		}
+	}
+
+	if err := s.userRepo.ReplaceRoles(ctx, user, roles); err != nil {
+		return nil, fmt.Errorf("分配角色失败: %w", err)
+	}
+	if removed {
+		if err := s.tokens.RevokeAllTokens(ctx, userID); err != nil {
+			return nil, fmt.Errorf("撤销用户令牌失败: %w", err)
+		}
+	}
+
+	return s.userRepo.FindByIDWithRoles(ctx, userID)
+}
+
+// uniqueStrings 去除重复的字符串
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
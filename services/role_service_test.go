@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+)
+
+func TestRoleServiceAssignRolesRevokesTokensWhenRolesRemoved(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        []string
+		to          []string
+		wantRevoked bool
+	}{
+		{name: "移除管理员角色", from: []string{models.RoleAdmin, models.RoleUser}, to: []string{models.RoleUser}, wantRevoked: true},
+		{name: "新增管理员角色", from: []string{models.RoleUser}, to: []string{models.RoleAdmin, models.RoleUser}},
+		{name: "角色不变", from: []string{models.RoleUser}, to: []string{models.RoleUser}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			rdb, _ := newTestRedis(t)
+			tokens := newTestLoginCompleter(db, rdb, false).tokens
+			roleRepo := repositories.NewRoleRepository(db)
+			userRepo := repositories.NewUserRepository(db)
+			service := NewRoleService(roleRepo, userRepo, newTestHasher(), tokens)
+			ctx := context.Background()
+
+			// 另一个管理员，保证移除管理员角色时不是最后一个
+			admins, err := roleRepo.FindByNames(ctx, []string{models.RoleAdmin})
+			if err != nil {
+				t.Fatalf("查询管理员角色失败: %v", err)
+			}
+			if err := userRepo.AddRoles(ctx, createTestUser(t, db, "root", "root@example.com", "correct-password"), admins); err != nil {
+				t.Fatalf("分配管理员角色失败: %v", err)
+			}
+			bob := createTestUser(t, db, "bob", "bob@example.com", "correct-password")
+			if _, err := service.AssignRoles(ctx, bob.ID, tt.from); err != nil {
+				t.Fatalf("分配初始角色失败: %v", err)
+			}
+
+			pair, err := tokens.IssueTokens(ctx, bob.ID, ClientInfo{IP: "192.0.2.1"}, nil)
+			if err != nil {
+				t.Fatalf("签发令牌失败: %v", err)
+			}
+			if _, err := service.AssignRoles(ctx, bob.ID, tt.to); err != nil {
+				t.Fatalf("分配角色失败: %v", err)
+			}
+
+			_, accessErr := tokens.ValidateAccessToken(ctx, pair.AccessToken)
+			_, refreshErr := tokens.Refresh(ctx, pair.RefreshToken)
+			if tt.wantRevoked {
+				if !errors.Is(accessErr, ErrTokenRevoked) || refreshErr == nil {
+					t.Fatalf("移除角色后令牌应失效，访问令牌: %v，刷新令牌: %v", accessErr, refreshErr)
+				}
+				return
+			}
+			if accessErr != nil || refreshErr != nil {
+				t.Fatalf("没有移除角色时令牌不应失效，访问令牌: %v，刷新令牌: %v", accessErr, refreshErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// SAMLIdentityProvider SAML 用户在外部身份表中的提供方名称，外部账号标识为断言中的 NameID
+	SAMLIdentityProvider = "saml"
+
+	// SAMLRequestTTL 跳转到 IdP 后完成登录的时限
+	SAMLRequestTTL = 10 * time.Minute
+	// SAMLLoginCodeTTL ACS 跳转回前端后换取令牌的时限
+	SAMLLoginCodeTTL = time.Minute
+
+	samlRequestKeyPrefix   = "saml_request:"
+	samlAssertionKeyPrefix = "saml_assertion:"
+	samlLoginKeyPrefix     = "saml_login:"
+)
+
+var (
+	ErrSAMLRequestInvalid = errors.New("SAML 登录请求无效或已过期，请重新开始")
+	ErrSAMLLoginFailed    = errors.New("SAML 断言验证失败")
+	ErrSAMLCodeInvalid    = errors.New("登录码无效或已过期，请重新登录")
+)
+
+// SAMLOptions SAML 登录配置
+type SAMLOptions struct {
+	IDPMetadataURL    string // 服务提供方创建时没有加载 IdP 元数据时，首次使用时从该地址获取
+	UsernameAttribute string // 为空时使用 NameID
+	EmailAttribute    string
+	NicknameAttribute string
+	CallbackURL       string // ACS 处理完成后跳转的前端页面
+}
+
+// SAMLService SAML 2.0 单点登录服务，本服务作为 SP
+// 断言验证通过后按外部身份查找或创建本地用户，再跳转到前端页面携带一次性的登录码换取令牌，
+// 令牌不会出现在浏览器地址中
+type SAMLService struct {
+	sp       *saml.ServiceProvider
+	opts     SAMLOptions
+	external *ExternalLoginService
+	users    *repositories.UserRepository
+	rdb      *redis.Client
+
+	mu sync.Mutex
+}
+
+// NewSAMLService 创建 SAML 登录服务实例
+func NewSAMLService(sp *saml.ServiceProvider, opts SAMLOptions, external *ExternalLoginService, users *repositories.UserRepository, rdb *redis.Client) *SAMLService {
+	return &SAMLService{
+		sp:       sp,
+		opts:     opts,
+		external: external,
+		users:    users,
+		rdb:      rdb,
+	}
+}
+
+// Metadata 返回 SP 元数据 XML，提供给 IdP 管理员配置
+func (s *SAMLService) Metadata() ([]byte, error) {
+	data, err := xml.MarshalIndent(s.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// LoginURL 生成 SP 发起登录的 IdP 跳转地址，认证请求 ID 按 RelayState 记录，ACS 中校验断言是对该请求的响应
+func (s *SAMLService) LoginURL(ctx context.Context) (string, error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", fmt.Errorf("生成 SAML 认证请求失败: %w", err)
+	}
+	relayState, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.rdb.Set(ctx, samlRequestKeyPrefix+utils.HashToken(relayState), req.ID, SAMLRequestTTL).Err(); err != nil {
+		return "", err
+	}
+
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", fmt.Errorf("生成 SAML 认证请求失败: %w", err)
+	}
+	return redirectURL.String(), nil
+}
+
+// ACS 处理 IdP 提交的断言，验证通过后返回跳转到前端页面的地址，其中携带一次性的登录码
+// RelayState 对应 SP 发起的登录时断言必须是对该请求的响应；没有对应的请求时仅在允许 IdP 发起登录时接受
+func (s *SAMLService) ACS(ctx context.Context, samlResponse, relayState string) (string, error) {
+	sp, err := s.serviceProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var possibleRequestIDs []string
+	if relayState != "" {
+		requestID, err := s.rdb.GetDel(ctx, samlRequestKeyPrefix+utils.HashToken(relayState)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+		if requestID != "" {
+			possibleRequestIDs = []string{requestID}
+		}
+	}
+	if possibleRequestIDs == nil && !sp.AllowIDPInitiated {
+		return "", ErrSAMLRequestInvalid
+	}
+	if possibleRequestIDs != nil && sp.AllowIDPInitiated {
+		// 允许 IdP 发起登录时库不会校验 InResponseTo，SP 发起的登录使用严格校验的副本
+		strict := *sp
+		strict.AllowIDPInitiated = false
+		sp = &strict
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return "", fmt.Errorf("%w: 无法解码 SAMLResponse", ErrSAMLLoginFailed)
+	}
+	assertion, err := sp.ParseXMLResponse(raw, possibleRequestIDs)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			logger.CtxErrorf(ctx, "SAML 断言验证失败: %v", invalid.PrivateErr)
+		}
+		return "", ErrSAMLLoginFailed
+	}
+
+	// 断言只能使用一次，记录到断言过期为止
+	if assertion.ID == "" || assertion.Conditions == nil {
+		return "", fmt.Errorf("%w: 断言缺少 ID 或有效期", ErrSAMLLoginFailed)
+	}
+	ttl := time.Until(assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew))
+	if ttl <= 0 {
+		ttl = saml.MaxClockSkew
+	}
+	fresh, err := s.rdb.SetNX(ctx, samlAssertionKeyPrefix+utils.HashToken(assertion.ID), 1, ttl).Result()
+	if err != nil {
+		return "", err
+	}
+	if !fresh {
+		return "", fmt.Errorf("%w: 断言已被使用", ErrSAMLLoginFailed)
+	}
+
+	profile, err := s.profile(assertion)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.external.resolveUser(ctx, SAMLIdentityProvider, profile)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.rdb.Set(ctx, samlLoginKeyPrefix+utils.HashToken(code), user.ID, SAMLLoginCodeTTL).Err(); err != nil {
+		return "", err
+	}
+	return s.CallbackURL(url.Values{"code": {code}}), nil
+}
+
+// Exchange 使用 ACS 签发的一次性登录码完成登录，启用了双因素认证时返回 MFA 挑战令牌
+func (s *SAMLService) Exchange(ctx context.Context, code string, client ClientInfo) (*LoginResult, error) {
+	value, err := s.rdb.GetDel(ctx, samlLoginKeyPrefix+utils.HashToken(code)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSAMLCodeInvalid
+		}
+		return nil, err
+	}
+	userID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, ErrSAMLCodeInvalid
+	}
+
+	user, err := s.users.FindByID(ctx, uint(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.external.completeLogin(ctx, user, client)
+}
+
+// CallbackURL 返回携带指定参数的前端回调地址，ACS 失败时用于带上错误信息
+func (s *SAMLService) CallbackURL(params url.Values) string {
+	separator := "?"
+	if strings.Contains(s.opts.CallbackURL, "?") {
+		separator = "&"
+	}
+	return s.opts.CallbackURL + separator + params.Encode()
+}
+
+// profile 按配置的属性名从断言中读取用户信息，IdP 负责邮箱的真实性，视为已验证
+func (s *SAMLService) profile(assertion *saml.Assertion) (*externalProfile, error) {
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("%w: 缺少 NameID", ErrSAMLLoginFailed)
+	}
+	nameID := strings.TrimSpace(assertion.Subject.NameID.Value)
+
+	profile := &externalProfile{
+		Subject:       nameID,
+		Email:         samlAttribute(assertion, s.opts.EmailAttribute),
+		EmailVerified: true,
+		Username:      nameID,
+		Name:          samlAttribute(assertion, s.opts.NicknameAttribute),
+	}
+	if s.opts.UsernameAttribute != "" {
+		profile.Username = samlAttribute(assertion, s.opts.UsernameAttribute)
+	}
+	return profile, nil
+}
+
+// serviceProvider 返回 SP，配置了元数据地址时在首次使用时获取 IdP 元数据，获取失败时下次调用会重试
+func (s *SAMLService) serviceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sp.IDPMetadata != nil {
+		return s.sp, nil
+	}
+
+	metadataURL, err := url.Parse(s.opts.IDPMetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 IdP 元数据地址: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	metadata, err := samlsp.FetchMetadata(ctx, http.DefaultClient, *metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("获取 IdP 元数据失败: %w", err)
+	}
+	s.sp.IDPMetadata = metadata
+	return s.sp, nil
+}
+
+// samlAttribute 读取断言中按名称或友好名称匹配的第一个属性值
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if attribute.Name != name && attribute.FriendlyName != name {
+				continue
+			}
+			for _, value := range attribute.Values {
+				if v := strings.TrimSpace(value.Value); v != "" {
+					return v
+				}
+			}
+		}
+	}
+	return ""
+}
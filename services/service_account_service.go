@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefixBytes API Key 前缀的随机字节数，编码后为 12 位十六进制字符
+const apiKeyPrefixBytes = 6
+
+// apiKeyLastUsedInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const apiKeyLastUsedInterval = time.Minute
+
+var ErrInvalidApiKey = errors.New("无效的 API Key")
+
+// ServiceAccountService 服务账号与 API Key 服务
+type ServiceAccountService struct {
+	repo     *repositories.ServiceAccountRepository
+	roleRepo *repositories.RoleRepository
+}
+
+// NewServiceAccountService 创建服务账号服务实例
+func NewServiceAccountService(repo *repositories.ServiceAccountRepository, roleRepo *repositories.RoleRepository) *ServiceAccountService {
+	return &ServiceAccountService{
+		repo:     repo,
+		roleRepo: roleRepo,
+	}
+}
+
+// Create 创建服务账号并分配角色
+func (s *ServiceAccountService) Create(ctx context.Context, name, description string, roleNames []string) (*models.ServiceAccount, error) {
+	roles, err := s.roleRepo.FindByNames(ctx, roleNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(uniqueStrings(roleNames)) {
+		return nil, errors.New("包含不存在的角色")
+	}
+
+	account := &models.ServiceAccount{
+		Name:        name,
+		Description: description,
+		Roles:       roles,
+	}
+	if err := s.repo.Create(ctx, account); err != nil {
+		return nil, fmt.Errorf("创建服务账号失败: %w", err)
+	}
+	return account, nil
+}
+
+// List 列出全部服务账号
+func (s *ServiceAccountService) List(ctx context.Context) ([]models.ServiceAccount, error) {
+	return s.repo.List(ctx)
+}
+
+// IssueKey 为服务账号签发 API Key，返回的明文只会出现这一次
+func (s *ServiceAccountService) IssueKey(ctx context.Context, accountID uint, name string, expiresIn time.Duration) (string, *models.ApiKey, error) {
+	if _, err := s.repo.FindByID(ctx, accountID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, errors.New("服务账号不存在")
+		}
+		return "", nil, err
+	}
+	return s.issueKey(ctx, s.repo, accountID, name, expiresIn)
+}
+
+// ListKeys 列出服务账号的 API Key
+func (s *ServiceAccountService) ListKeys(ctx context.Context, accountID uint) ([]models.ApiKey, error) {
+	return s.repo.ListKeys(ctx, accountID)
+}
+
+// RotateKey 轮换 API Key：签发一个同名新 Key，旧 Key 在宽限期后失效
+// 宽限期为 0 时旧 Key 立即撤销
+func (s *ServiceAccountService) RotateKey(ctx context.Context, keyID uint, gracePeriod time.Duration) (string, *models.ApiKey, error) {
+	var plaintext string
+	var newKey *models.ApiKey
+
+	err := s.repo.Transaction(func(tx *gorm.DB) error {
+		txRepo := repositories.NewServiceAccountRepository(tx)
+
+		oldKey, err := txRepo.FindKeyByID(ctx, keyID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("API Key 不存在")
+			}
+			return err
+		}
+
+		var expiresIn time.Duration
+		if oldKey.ExpiresAt != nil {
+			expiresIn = time.Until(*oldKey.ExpiresAt)
+			if expiresIn <= 0 {
+				return errors.New("API Key 已过期，请重新签发")
+			}
+		}
+		plaintext, newKey, err = s.issueKey(ctx, txRepo, oldKey.ServiceAccountID, oldKey.Name, expiresIn)
+		if err != nil {
+			return err
+		}
+
+		if gracePeriod <= 0 {
+			_, err = txRepo.DeleteKey(ctx, oldKey.ID)
+			return err
+		}
+		graceEnd := time.Now().Add(gracePeriod)
+		if oldKey.ExpiresAt != nil && oldKey.ExpiresAt.Before(graceEnd) {
+			return nil
+		}
+		return txRepo.UpdateKeyExpiry(ctx, oldKey.ID, graceEnd)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, newKey, nil
+}
+
+// RevokeKey 立即撤销 API Key
+func (s *ServiceAccountService) RevokeKey(ctx context.Context, keyID uint) error {
+	affected, err := s.repo.DeleteKey(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("API Key 不存在")
+	}
+	return nil
+}
+
+// Authenticate 验证 API Key，先按前缀查找再以恒定时间比对哈希
+func (s *ServiceAccountService) Authenticate(ctx context.Context, plaintext string) (*Principal, error) {
+	prefix, ok := parseApiKeyPrefix(plaintext)
+	if !ok {
+		return nil, ErrInvalidApiKey
+	}
+
+	key, err := s.repo.FindKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidApiKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(plaintext))) != 1 {
+		return nil, ErrInvalidApiKey
+	}
+	if key.Expired() {
+		return nil, errors.New("API Key 已过期")
+	}
+
+	account, err := s.repo.FindByID(ctx, key.ServiceAccountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidApiKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyLastUsedInterval {
+		if err := s.repo.TouchKeyLastUsed(ctx, key.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// 服务账号不是自然人，不授予任何自助类作用域
+	return &Principal{
+		ServiceAccountID: account.ID,
+		ApiKeyID:         key.ID,
+		Roles:            account.RoleNames(),
+		Permissions:      account.PermissionCodes(),
+		Scopes:           []string{},
+	}, nil
+}
+
+// issueKey 生成并保存一个新的 API Key
+func (s *ServiceAccountService) issueKey(ctx context.Context, repo *repositories.ServiceAccountRepository, accountID uint, name string, expiresIn time.Duration) (string, *models.ApiKey, error) {
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := models.ApiKeyPrefix + prefix + "_" + secret
+
+	key := &models.ApiKey{
+		ServiceAccountID: accountID,
+		Name:             name,
+		Prefix:           prefix,
+		KeyHash:          utils.HashToken(plaintext),
+	}
+	if expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := repo.CreateKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// parseApiKeyPrefix 从 "pk_<prefix>_<secret>" 中解析出前缀
+func parseApiKeyPrefix(plaintext string) (string, bool) {
+	rest, ok := strings.CutPrefix(plaintext, models.ApiKeyPrefix)
+	prefixLen := apiKeyPrefixBytes * 2
+	if !ok || len(rest) <= prefixLen+1 || rest[prefixLen] != '_' {
+		return "", false
+	}
+	return rest[:prefixLen], true
+}
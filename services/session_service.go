@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+)
+
+// ClientInfo 发起请求的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionService 登录会话管理服务
+type SessionService struct {
+	repo   *repositories.SessionRepository
+	tokens *TokenService
+}
+
+// NewSessionService 创建登录会话服务实例
+func NewSessionService(repo *repositories.SessionRepository, tokens *TokenService) *SessionService {
+	return &SessionService{
+		repo:   repo,
+		tokens: tokens,
+	}
+}
+
+// List 列出用户当前有效的会话，长期未活跃的会话其刷新令牌已过期，不再列出
+func (s *SessionService) List(ctx context.Context, userID uint) ([]models.Session, error) {
+	return s.repo.ListActiveByUser(ctx, userID, time.Now().Add(-RefreshTokenTTL))
+}
+
+// Revoke 撤销用户的一个会话，该会话上的访问令牌和刷新令牌立即失效
+func (s *SessionService) Revoke(ctx context.Context, userID, sessionID uint) error {
+	return s.tokens.RevokeSession(ctx, userID, sessionID)
+}
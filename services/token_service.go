@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenTTL 刷新令牌的有效期，每次轮换都会重新计时
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// sessionTouchInterval 会话最近活跃时间的更新间隔，避免每个请求都写数据库
+const sessionTouchInterval = time.Minute
+
+const (
+	refreshTokenKeyPrefix        = "refresh_token:"
+	refreshFamilyKeyPrefix       = "refresh_family:"
+	refreshUserFamiliesKeyPrefix = "refresh_user_families:"
+	tokenDenylistKeyPrefix       = "token_denylist:"
+	tokenVersionKeyPrefix        = "token_version:"
+	sessionRevokedKeyPrefix      = "session_revoked:"
+	sessionSeenKeyPrefix         = "session_seen:"
+)
+
+var (
+	ErrInvalidToken        = errors.New("无效的令牌")
+	ErrTokenRevoked        = errors.New("令牌已被撤销")
+	ErrInvalidRefreshToken = errors.New("无效的刷新令牌")
+	ErrRefreshTokenRevoked = errors.New("刷新令牌已失效，请重新登录")
+	ErrRefreshTokenReused  = errors.New("检测到刷新令牌被重复使用，相关会话已全部撤销，请重新登录")
+	ErrSessionNotFound     = errors.New("会话不存在")
+)
+
+// rotateScript 原子地轮换令牌族中的当前刷新令牌，成功后令牌族重新登记到用户的令牌族集合并延长有效期
+// KEYS: 令牌族, 用户的令牌族集合
+// ARGV: 旧令牌哈希, 新令牌哈希, 有效期(毫秒), 令牌族ID
+// 返回 1 表示轮换成功，0 表示令牌族已不存在，-1 表示检测到重放并已撤销整个令牌族
+var rotateScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+redis.call('SADD', KEYS[2], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+return 1
+`)
+
+// TokenPair 登录或刷新后签发的一组令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	SessionID    uint
+}
+
+// refreshTokenRecord 保存在 Redis 中的刷新令牌信息
+type refreshTokenRecord struct {
+	UserID    uint     `json:"user_id"`
+	FamilyID  string   `json:"family_id"`
+	SessionID uint     `json:"session_id"`
+	AuthTime  int64    `json:"auth_time,omitempty"` // 登录时间，刷新后签发的访问令牌沿用
+	AMR       []string `json:"amr,omitempty"`
+	// 登录时用户的令牌版本，用户撤销全部令牌后不能再用于刷新
+	TokenVersion int64 `json:"token_version"`
+}
+
+// TokenService 令牌服务，负责签发访问令牌和管理刷新令牌
+type TokenService struct {
+	keys     *utils.Keyring
+	opts     utils.TokenOptions
+	users    *repositories.UserRepository
+	sessions *repositories.SessionRepository
+	rdb      *redis.Client
+}
+
+// NewTokenService 创建令牌服务实例
+func NewTokenService(keys *utils.Keyring, opts utils.TokenOptions, users *repositories.UserRepository, sessions *repositories.SessionRepository, rdb *redis.Client) *TokenService {
+	return &TokenService{
+		keys:     keys,
+		opts:     opts,
+		users:    users,
+		sessions: sessions,
+		rdb:      rdb,
+	}
+}
+
+// JWKS 返回用于验证访问令牌的公钥集合
+func (s *TokenService) JWKS() utils.JWKSet {
+	return s.keys.JWKS()
+}
+
+// SigningAlgorithm 返回签发令牌使用的算法
+func (s *TokenService) SigningAlgorithm() string {
+	return s.keys.SigningAlgorithm()
+}
+
+// IssueIDToken 签发 OpenID Connect ID Token，有效期与访问令牌相同
+func (s *TokenService) IssueIDToken(claims utils.IDTokenClaims, issuer, clientID string) (string, error) {
+	return utils.GenerateIDToken(claims, s.keys, issuer, clientID, s.opts.TTL)
+}
+
+// AccessTokenTTL 返回访问令牌的有效期
+func (s *TokenService) AccessTokenTTL() time.Duration {
+	return s.opts.TTL
+}
+
+// IssueTokens 为用户创建一个登录会话，签发访问令牌并开启对应的刷新令牌族
+// amr 为本次登录使用的认证方式，与登录时间一起写入该会话签发的全部访问令牌
+func (s *TokenService) IssueTokens(ctx context.Context, userID uint, client ClientInfo, amr []string) (*TokenPair, error) {
+	userAgent := client.UserAgent
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	session := &models.Session{
+		UserID:      userID,
+		FamilyID:    uuid.NewString(),
+		DeviceLabel: utils.DeviceLabel(client.UserAgent),
+		UserAgent:   userAgent,
+		IP:          client.IP,
+		LastSeenAt:  time.Now(),
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+
+	version, err := s.TokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, refreshTokenRecord{
+		UserID:       userID,
+		FamilyID:     session.FamilyID,
+		SessionID:    session.ID,
+		AuthTime:     session.CreatedAt.Unix(),
+		AMR:          amr,
+		TokenVersion: version,
+	})
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧的刷新令牌随即作废
+// 如果一个已被轮换掉的刷新令牌再次出现，则视为令牌被盗用，撤销整个令牌族；
+// 所属会话已注销或用户此后撤销过全部令牌时，刷新令牌同样失效
+func (s *TokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	oldHash := utils.HashToken(refreshToken)
+
+	data, err := s.rdb.Get(ctx, refreshTokenKeyPrefix+oldHash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	var record refreshTokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	if err := s.checkRefreshable(ctx, record); err != nil {
+		if errors.Is(err, ErrRefreshTokenRevoked) {
+			s.rdb.Del(ctx, refreshTokenKeyPrefix+oldHash, refreshFamilyKeyPrefix+record.FamilyID)
+		}
+		return nil, err
+	}
+
+	newToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	newHash := utils.HashToken(newToken)
+
+	// 先写入新令牌，再切换令牌族指向，保证令牌族指向的令牌始终存在
+	if err := s.rdb.Set(ctx, refreshTokenKeyPrefix+newHash, data, RefreshTokenTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := rotateScript.Run(ctx, s.rdb,
+		[]string{refreshFamilyKeyPrefix + record.FamilyID, refreshUserFamiliesKeyPrefix + fmt.Sprint(record.UserID)},
+		oldHash, newHash, RefreshTokenTTL.Milliseconds(), record.FamilyID,
+	).Int()
+	if err != nil {
+		s.rdb.Del(ctx, refreshTokenKeyPrefix+newHash)
+		return nil, err
+	}
+
+	switch result {
+	case 1:
+	case -1:
+		s.rdb.Del(ctx, refreshTokenKeyPrefix+newHash)
+		logger.CtxWarnf(ctx, "检测到刷新令牌重放, userID: %d, family: %s", record.UserID, record.FamilyID)
+		if err := s.revokeSession(ctx, record.UserID, record.SessionID, record.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	default:
+		s.rdb.Del(ctx, refreshTokenKeyPrefix+newHash)
+		return nil, ErrRefreshTokenRevoked
+	}
+
+	accessToken, err := s.newAccessToken(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    s.opts.TTL,
+		SessionID:    record.SessionID,
+	}, nil
+}
+
+// checkRefreshable 检查刷新令牌所属的会话是否已注销，以及用户是否在签发之后撤销过全部令牌
+func (s *TokenService) checkRefreshable(ctx context.Context, record refreshTokenRecord) error {
+	version, err := s.TokenVersion(ctx, record.UserID)
+	if err != nil {
+		return err
+	}
+	if record.TokenVersion < version {
+		return ErrRefreshTokenRevoked
+	}
+	if record.SessionID == 0 {
+		return nil
+	}
+
+	revoked, err := s.rdb.Exists(ctx, sessionRevokedKeyPrefix+fmt.Sprint(record.SessionID)).Result()
+	if err != nil {
+		return err
+	}
+	if revoked > 0 {
+		return ErrRefreshTokenRevoked
+	}
+	session, err := s.sessions.FindByUser(ctx, record.UserID, record.SessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenRevoked
+		}
+		return err
+	}
+	if session.RevokedAt != nil {
+		return ErrRefreshTokenRevoked
+	}
+	return nil
+}
+
+// RefreshTokenSession 返回刷新令牌所属的登录会话ID，不会轮换令牌
+func (s *TokenService) RefreshTokenSession(ctx context.Context, refreshToken string) (uint, error) {
+	data, err := s.rdb.Get(ctx, refreshTokenKeyPrefix+utils.HashToken(refreshToken)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrInvalidRefreshToken
+		}
+		return 0, err
+	}
+	var record refreshTokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return 0, fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	return record.SessionID, nil
+}
+
+// ValidateAccessToken 验证访问令牌，并检查其本身或所属会话是否已被注销，以及是否因令牌版本递增而失效
+func (s *TokenService) ValidateAccessToken(ctx context.Context, tokenString string) (*utils.JWTClaims, error) {
+	claims, err := utils.ValidateToken(tokenString, s.keys, s.opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrTokenExpired),
+			errors.Is(err, utils.ErrTokenNotValidYet),
+			errors.Is(err, utils.ErrTokenInvalidAudience),
+			errors.Is(err, utils.ErrTokenInvalidIssuer):
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	keys := []string{tokenDenylistKeyPrefix + claims.ID}
+	if claims.SessionID != 0 {
+		keys = append(keys, sessionRevokedKeyPrefix+fmt.Sprint(claims.SessionID))
+	}
+	denied, err := s.rdb.Exists(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	if denied > 0 {
+		return nil, ErrTokenRevoked
+	}
+
+	// 客户端凭证令牌等没有用户主体的令牌不受用户令牌版本影响
+	if claims.UserID != 0 {
+		version, err := s.TokenVersion(ctx, claims.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if claims.TokenVersion < version {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// RevokeAccessToken 将访问令牌加入黑名单，黑名单条目在令牌原本过期时自动清除
+func (s *TokenService) RevokeAccessToken(ctx context.Context, claims *utils.JWTClaims) error {
+	if claims.ExpiresAt == nil {
+		return ErrInvalidToken
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.rdb.Set(ctx, tokenDenylistKeyPrefix+claims.ID, 1, ttl).Err()
+}
+
+// IssueImpersonationToken 为模拟登录签发一个只含访问令牌的短期令牌
+// 令牌以被模拟用户的身份和角色签发，act 声明记录实际操作的管理员；不创建会话也没有刷新令牌，到期后需要重新发起模拟
+func (s *TokenService) IssueImpersonationToken(ctx context.Context, userID, actorID uint, ttl time.Duration) (token, tokenID string, err error) {
+	claims, err := s.accessTokenClaims(ctx, userID, 0)
+	if err != nil {
+		return "", "", err
+	}
+	claims.ID = uuid.NewString()
+	claims.ActorID = actorID
+
+	opts := s.opts
+	opts.TTL = ttl
+	token, err = utils.GenerateToken(*claims, s.keys, opts)
+	if err != nil {
+		return "", "", err
+	}
+	return token, claims.ID, nil
+}
+
+// IssueOAuthAccessToken 为 OAuth 客户端签发访问令牌
+// userID 为 0 表示客户端凭证模式，令牌只代表客户端本身；否则令牌代表用户，权限为用户当前权限与授予作用域的交集
+func (s *TokenService) IssueOAuthAccessToken(ctx context.Context, userID uint, clientID string, scopes []string) (string, error) {
+	claims := &utils.JWTClaims{}
+	if userID != 0 {
+		var err error
+		if claims, err = s.accessTokenClaims(ctx, userID, 0); err != nil {
+			return "", err
+		}
+		var permissions []string
+		for _, perm := range claims.Permissions {
+			if slices.Contains(scopes, perm) {
+				permissions = append(permissions, perm)
+			}
+		}
+		claims.Permissions = permissions
+	}
+	claims.ClientID = clientID
+	claims.Scope = strings.Join(scopes, " ")
+	return utils.GenerateToken(*claims, s.keys, s.opts)
+}
+
+// TouchSession 更新会话的最近活跃时间，同一会话在更新间隔内只写一次数据库
+func (s *TokenService) TouchSession(ctx context.Context, sessionID uint, ip string) error {
+	acquired, err := s.rdb.SetNX(ctx, sessionSeenKeyPrefix+fmt.Sprint(sessionID), 1, sessionTouchInterval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+	return s.sessions.TouchLastSeen(ctx, sessionID, ip, time.Now())
+}
+
+// RevokeSession 撤销用户的一个会话：删除其刷新令牌族，并使已签发的访问令牌立即失效
+func (s *TokenService) RevokeSession(ctx context.Context, userID, sessionID uint) error {
+	session, err := s.sessions.FindByUser(ctx, userID, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	return s.revokeSession(ctx, userID, session.ID, session.FamilyID)
+}
+
+// RevokeRefreshToken 撤销刷新令牌所在的整个令牌族及其会话，只允许令牌持有者本人撤销
+func (s *TokenService) RevokeRefreshToken(ctx context.Context, userID uint, refreshToken string) error {
+	data, err := s.rdb.Get(ctx, refreshTokenKeyPrefix+utils.HashToken(refreshToken)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	var record refreshTokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("解析刷新令牌失败: %w", err)
+	}
+	if record.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+
+	return s.revokeSession(ctx, userID, record.SessionID, record.FamilyID)
+}
+
+// RevokeAllTokens 撤销用户的全部令牌：递增令牌版本使已签发的访问令牌失效，并删除所有刷新令牌族
+func (s *TokenService) RevokeAllTokens(ctx context.Context, userID uint) error {
+	if userID == 0 {
+		return errors.New("无效的用户，无法撤销令牌")
+	}
+	familiesKey := refreshUserFamiliesKeyPrefix + fmt.Sprint(userID)
+
+	families, err := s.rdb.SMembers(ctx, familiesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, tokenVersionKeyPrefix+fmt.Sprint(userID))
+		for _, family := range families {
+			pipe.Del(ctx, refreshFamilyKeyPrefix+family)
+		}
+		pipe.Del(ctx, familiesKey)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllByUser(ctx, userID, time.Now())
+}
+
+// TokenVersion 获取用户当前的令牌版本，未设置时为 0
+func (s *TokenService) TokenVersion(ctx context.Context, userID uint) (int64, error) {
+	version, err := s.rdb.Get(ctx, tokenVersionKeyPrefix+fmt.Sprint(userID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// revokeSession 删除刷新令牌族，标记会话已撤销，并在访问令牌的最长有效期内拒绝该会话的访问令牌
+func (s *TokenService) revokeSession(ctx context.Context, userID, sessionID uint, familyID string) error {
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, refreshFamilyKeyPrefix+familyID)
+		pipe.SRem(ctx, refreshUserFamiliesKeyPrefix+fmt.Sprint(userID), familyID)
+		if sessionID != 0 {
+			pipe.Set(ctx, sessionRevokedKeyPrefix+fmt.Sprint(sessionID), 1, s.opts.TTL+s.opts.Leeway)
+		}
+		return nil
+	})
+	if err != nil || sessionID == 0 {
+		return err
+	}
+	_, err = s.sessions.Revoke(ctx, sessionID, time.Now())
+	return err
+}
+
+// IssueElevatedToken 用户重新验证身份后签发短期有效的访问令牌，auth_time 为当前时间
+// 令牌属于当前登录会话，会话被注销时一同失效；不签发刷新令牌
+func (s *TokenService) IssueElevatedToken(ctx context.Context, userID, sessionID uint, amr []string, ttl time.Duration) (string, error) {
+	claims, err := s.accessTokenClaims(ctx, userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	claims.AuthTime = time.Now().Unix()
+	claims.AMR = amr
+
+	opts := s.opts
+	opts.TTL = ttl
+	return utils.GenerateToken(*claims, s.keys, opts)
+}
+
+// newAccessToken 按用户当前的令牌版本签发访问令牌，sid 声明指向所属会话
+// 每次签发都从数据库重新读取角色，刷新令牌后角色变更即可生效
+func (s *TokenService) newAccessToken(ctx context.Context, record refreshTokenRecord) (string, error) {
+	claims, err := s.accessTokenClaims(ctx, record.UserID, record.SessionID)
+	if err != nil {
+		return "", err
+	}
+	claims.AuthTime = record.AuthTime
+	claims.AMR = record.AMR
+	return utils.GenerateToken(*claims, s.keys, s.opts)
+}
+
+// accessTokenClaims 按用户当前的令牌版本和角色构造访问令牌的自定义声明
+func (s *TokenService) accessTokenClaims(ctx context.Context, userID, sessionID uint) (*utils.JWTClaims, error) {
+	version, err := s.TokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.FindByIDWithRoles(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	return &utils.JWTClaims{
+		UserID:       user.ID,
+		SessionID:    sessionID,
+		TokenVersion: version,
+		Roles:        user.RoleNames(),
+		Permissions:  user.PermissionCodes(),
+	}, nil
+}
+
+// issue 签发访问令牌，并将新的刷新令牌设为令牌族的当前令牌
+func (s *TokenService) issue(ctx context.Context, record refreshTokenRecord) (*TokenPair, error) {
+	accessToken, err := s.newAccessToken(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hash := utils.HashToken(refreshToken)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, refreshTokenKeyPrefix+hash, data, RefreshTokenTTL)
+		pipe.Set(ctx, refreshFamilyKeyPrefix+record.FamilyID, hash, RefreshTokenTTL)
+		familiesKey := refreshUserFamiliesKeyPrefix + fmt.Sprint(record.UserID)
+		pipe.SAdd(ctx, familiesKey, record.FamilyID)
+		pipe.Expire(ctx, familiesKey, RefreshTokenTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.opts.TTL,
+		SessionID:    record.SessionID,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/plusone/repositories"
+)
+
+func TestTokenServiceRevokeAllTokensAfterLongRefreshChain(t *testing.T) {
+	db := newTestDB(t)
+	rdb, mr := newTestRedis(t)
+	tokens := newTestLoginCompleter(db, rdb, false).tokens
+	alice := createTestUser(t, db, "alice", "alice@example.com", "correct-password")
+	ctx := context.Background()
+
+	pair, err := tokens.IssueTokens(ctx, alice.ID, ClientInfo{IP: "192.0.2.1"}, nil)
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+	session, err := repositories.NewSessionRepository(db).FindByUser(ctx, alice.ID, pair.SessionID)
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+
+	// 不再登录，只持续刷新，累计时间超过刷新令牌的有效期
+	for elapsed := time.Duration(0); elapsed <= 2*RefreshTokenTTL; elapsed += RefreshTokenTTL / 2 {
+		mr.FastForward(RefreshTokenTTL / 2)
+		if pair, err = tokens.Refresh(ctx, pair.RefreshToken); err != nil {
+			t.Fatalf("刷新令牌失败: %v", err)
+		}
+	}
+
+	if err := tokens.RevokeAllTokens(ctx, alice.ID); err != nil {
+		t.Fatalf("撤销全部令牌失败: %v", err)
+	}
+	if mr.Exists(refreshFamilyKeyPrefix + session.FamilyID) {
+		t.Fatalf("撤销全部令牌后令牌族仍然存在")
+	}
+	if _, err := tokens.Refresh(ctx, pair.RefreshToken); err == nil {
+		t.Fatalf("撤销全部令牌后仍可刷新")
+	}
+}
+
+func TestTokenServiceRefreshRejectsRevokedTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, mr *miniredis.Miniredis, tokens *TokenService, pair *TokenPair, userID uint)
+	}{
+		{
+			name: "会话已注销",
+			revoke: func(t *testing.T, _ *miniredis.Miniredis, tokens *TokenService, pair *TokenPair, userID uint) {
+				if err := tokens.RevokeSession(context.Background(), userID, pair.SessionID); err != nil {
+					t.Fatalf("注销会话失败: %v", err)
+				}
+			},
+		},
+		{
+			// 例如令牌族集合丢失后撤销全部令牌，令牌族本身仍然存在
+			name: "令牌版本已递增",
+			revoke: func(t *testing.T, mr *miniredis.Miniredis, _ *TokenService, _ *TokenPair, userID uint) {
+				if _, err := mr.Incr(tokenVersionKeyPrefix+fmt.Sprint(userID), 1); err != nil {
+					t.Fatalf("递增令牌版本失败: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			rdb, mr := newTestRedis(t)
+			tokens := newTestLoginCompleter(db, rdb, false).tokens
+			alice := createTestUser(t, db, "alice", "alice@example.com", "correct-password")
+			ctx := context.Background()
+
+			pair, err := tokens.IssueTokens(ctx, alice.ID, ClientInfo{IP: "192.0.2.1"}, nil)
+			if err != nil {
+				t.Fatalf("签发令牌失败: %v", err)
+			}
+			tt.revoke(t, mr, tokens, pair, alice.ID)
+
+			if _, err := tokens.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) && !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Fatalf("期望刷新令牌失效，实际为 %v", err)
+			}
+		})
+	}
+}
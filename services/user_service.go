@@ -3,98 +3,433 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/plusone/models"
 	"github.com/plusone/repositories"
 	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrUsernameTaken = errors.New("用户名已存在")
+	ErrEmailTaken    = errors.New("邮箱已被使用")
+	// ErrReauthenticationRequired 距离上次验证身份的时间过长，需要重新验证后才能执行敏感操作
+	ErrReauthenticationRequired = errors.New("该操作需要重新验证身份")
+)
+
+// usernameMaxLength 用户名的最大长度，与 User.Username 和 NormalizedUsername 的列宽一致
+const usernameMaxLength = 50
+
 // UserService 用户服务层
 type UserService struct {
-	repo *repositories.UserRepository
-	jwt  string
-	rdb  *redis.Client
+	repo         *repositories.UserRepository
+	roles        *repositories.RoleRepository
+	tokens       *TokenService
+	hasher       *utils.PasswordHasher
+	policy       *PasswordPolicy
+	mfa          *MFAService
+	verification *EmailVerificationService
+	throttle     *LoginThrottleService
+	rdb          *redis.Client
+
+	authenticator Authenticator   // 用户名密码的认证后端
+	completer     *LoginCompleter // 认证通过后的登录策略检查和令牌签发
+
+	concealRegistration bool          // 防枚举模式：注册时不提示用户名或邮箱已被使用
+	reauthTokenTTL      time.Duration // 重新验证身份后签发的短期令牌有效期
+}
+
+// LoginResult 登录结果
+// 启用了双因素认证的用户只会得到 MFAToken，需要再调用 LoginMFA 换取令牌
+type LoginResult struct {
+	Tokens   *TokenPair
+	MFAToken string
 }
 
 // NewUserService 创建用户服务实例
-func NewUserService(repo *repositories.UserRepository, jwtSecret string, rdb *redis.Client) *UserService {
+func NewUserService(repo *repositories.UserRepository, roles *repositories.RoleRepository, tokens *TokenService, hasher *utils.PasswordHasher, policy *PasswordPolicy, mfa *MFAService, verification *EmailVerificationService, throttle *LoginThrottleService, authenticator Authenticator, completer *LoginCompleter, rdb *redis.Client, concealRegistration bool, reauthTokenTTL time.Duration) *UserService {
 	return &UserService{
-		repo: repo,
-		jwt:  jwtSecret,
-		rdb:  rdb,
+		repo:         repo,
+		roles:        roles,
+		tokens:       tokens,
+		hasher:       hasher,
+		policy:       policy,
+		mfa:          mfa,
+		verification: verification,
+		throttle:     throttle,
+		rdb:          rdb,
+
+		authenticator: authenticator,
+		completer:     completer,
+
+		concealRegistration: concealRegistration,
+		reauthTokenTTL:      reauthTokenTTL,
 	}
 }
 
-// Register 用户注册
+// Register 用户注册，注册成功后向邮箱发送验证链接
+// 密码不符合密码策略时返回 *PasswordPolicyError
+// 开启防枚举模式时，用户名或邮箱已被使用不返回错误而是返回 nil 用户，并通过邮件告知邮箱的所有者，
+// 调用方对两种结果应返回相同的响应
 func (s *UserService) Register(ctx context.Context, username, password, email, nickname string) (*models.User, error) {
-	var user *models.User
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+	if err := s.policy.Validate(password, username, email); err != nil {
+		return nil, err
+	}
+
+	// 先设置密码，用户名已存在时同样承担哈希的开销，响应时间不会暴露冲突
+	newUser := &models.User{
+		Username: username,
+		Email:    email,
+		Nickname: nickname,
+	}
+	if err := newUser.SetPassword(s.hasher, password); err != nil {
+		return nil, err
+	}
+
+	var conflict error
 	// GORM 事务
 	err := s.repo.Transaction(func(tx *gorm.DB) error {
 		// 使用事务作用域的 repository
 		txRepo := repositories.NewUserRepository(tx)
 
-		// 1. 检查用户名是否已存在
+		// 1. 检查用户名和邮箱是否已被使用
 		_, err := txRepo.FindByUsername(ctx, username)
 		if err == nil {
-			return errors.New("用户名已存在")
+			conflict = ErrUsernameTaken
+			return nil
 		}
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
+		_, err = txRepo.FindByEmail(ctx, email)
+		if err == nil {
+			conflict = ErrEmailTaken
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 2. 保存用户并分配默认角色
+		return createUserWithDefaultRole(ctx, tx, newUser)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// 2. 创建新用户
-		newUser := &models.User{
-			Username: username,
-			Email:    email,
-			Nickname: nickname,
+	if conflict != nil {
+		if !s.concealRegistration {
+			return nil, conflict
 		}
+		logger.CtxInfof(ctx, "注册冲突已按防枚举模式处理, username: %s, error: %v", username, conflict)
+		s.verification.SendRegistrationConflict(ctx, username, email, errors.Is(conflict, ErrEmailTaken))
+		return nil, nil
+	}
 
-		// 3. 设置密码
-		if err := newUser.SetPassword(password); err != nil {
-			return err
+	s.verification.SendVerification(ctx, newUser)
+	return newUser, nil
+}
+
+// Login 用户登录
+// 未启用双因素认证时直接返回访问令牌和刷新令牌，否则返回短期有效的 MFA 挑战令牌
+// 用户不存在和密码错误统一返回 ErrInvalidCredentials；连续失败会触发退避等待和临时锁定，此时返回 *LoginLockedError
+func (s *UserService) Login(ctx context.Context, username, password string, client ClientInfo) (*LoginResult, error) {
+	account, err := s.loginAccount(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Check(ctx, account, client); err != nil {
+		return nil, err
+	}
+
+	// 通过配置的认证后端校验用户名和密码
+	user, err := s.authenticator.Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrWrongPassword) {
+			if err := s.throttle.RecordFailure(ctx, account, client); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidCredentials
 		}
+		return nil, err
+	}
+	if err := s.throttle.RecordSuccess(ctx, account); err != nil {
+		return nil, err
+	}
+	s.upgradePasswordHash(ctx, user, password)
 
-		// 4. 保存用户
-		if err := txRepo.Create(ctx, newUser); err != nil {
-			return err
+	// 检查邮箱验证状态，启用了双因素认证时进入第二步验证，否则签发令牌
+	return s.completer.Complete(ctx, user, client, utils.AMRPassword, false)
+}
+
+// LoginMFA 登录第二步：使用 MFA 挑战令牌和 TOTP 验证码 (或恢复码) 换取令牌
+func (s *UserService) LoginMFA(ctx context.Context, mfaToken, code, recoveryCode string, client ClientInfo) (*TokenPair, error) {
+	user, amr, err := s.mfa.CompleteChallenge(ctx, mfaToken, code, recoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokens.IssueTokens(ctx, user.ID, client, amr)
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对
+func (s *UserService) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return s.tokens.Refresh(ctx, refreshToken)
+}
+
+// RefreshTokenSession 返回刷新令牌所属的登录会话ID
+func (s *UserService) RefreshTokenSession(ctx context.Context, refreshToken string) (uint, error) {
+	return s.tokens.RefreshTokenSession(ctx, refreshToken)
+}
+
+// Logout 注销当前访问令牌及其所属会话
+// 没有会话信息的旧令牌如果提供了刷新令牌，则撤销刷新令牌所在的令牌族
+func (s *UserService) Logout(ctx context.Context, claims *utils.JWTClaims, refreshToken string) error {
+	if err := s.tokens.RevokeAccessToken(ctx, claims); err != nil {
+		return err
+	}
+	if claims.SessionID != 0 {
+		err := s.tokens.RevokeSession(ctx, claims.UserID, claims.SessionID)
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil
 		}
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	return s.tokens.RevokeRefreshToken(ctx, claims.UserID, refreshToken)
+}
 
-		user = newUser
-		return nil // 事务提交
-	})
+// LogoutAll 注销用户在所有设备上的登录状态
+func (s *UserService) LogoutAll(ctx context.Context, userID uint) error {
+	return s.tokens.RevokeAllTokens(ctx, userID)
+}
 
-	return user, err
+// ChangePassword 验证当前密码后修改密码，修改成功后注销用户在所有设备上的登录状态
+// 新密码不符合密码策略时返回 *PasswordPolicyError；当前密码错误同样计入登录失败次数
+func (s *UserService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string, client ClientInfo) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyPassword(ctx, user, currentPassword, client); err != nil {
+		if errors.Is(err, ErrWrongPassword) {
+			return errors.New("当前密码错误")
+		}
+		return err
+	}
+	if err := s.policy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	if err := user.SetPassword(s.hasher, newPassword); err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, user.Password); err != nil {
+		return fmt.Errorf("修改密码失败: %w", err)
+	}
+	return s.tokens.RevokeAllTokens(ctx, userID)
 }
 
-// Login 用户登录
-func (s *UserService) Login(ctx context.Context, username, password string) (string, error) {
-	user, err := s.repo.FindByUsername(ctx, username)
+// ChangeEmail 验证当前密码后更换邮箱，新邮箱需要重新验证
+// 只修改大小写或全角等写法、规范化后与当前邮箱相同时仍是同一个邮箱，保留验证状态；密码错误同样计入登录失败次数
+func (s *UserService) ChangeEmail(ctx context.Context, userID uint, password, email string, client ClientInfo) (*models.User, error) {
+	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", errors.New("用户不存在")
+		return nil, err
+	}
+	if err := s.verifyPassword(ctx, user, password, client); err != nil {
+		return nil, err
+	}
+	if email == user.Email {
+		return nil, errors.New("新邮箱与当前邮箱相同")
+	}
+
+	existing, err := s.repo.FindByEmail(ctx, email)
+	if err == nil && existing.ID != userID {
+		return nil, ErrEmailTaken
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if utils.NormalizeIdentity(email) == utils.NormalizeIdentity(user.Email) {
+		if err := s.repo.UpdateEmailSpelling(ctx, userID, email); err != nil {
+			return nil, fmt.Errorf("更换邮箱失败: %w", err)
 		}
-		return "", err
+		user.Email = email
+		// 未验证的邮箱重新发送验证链接，旧链接中的邮箱写法已与账号不一致
+		if user.EmailVerifiedAt == nil {
+			s.verification.SendVerification(ctx, user)
+		}
+		return user, nil
 	}
 
-	// 验证密码
-	if !user.CheckPassword(password) {
-		return "", errors.New("密码错误")
+	if err := s.repo.UpdateEmail(ctx, userID, email); err != nil {
+		return nil, fmt.Errorf("更换邮箱失败: %w", err)
 	}
+	user.Email = email
+	user.EmailVerifiedAt = nil
 
-	// 生成JWT令牌
-	token, err := utils.GenerateToken(user.ID, s.jwt)
+	s.verification.SendVerification(ctx, user)
+	return user, nil
+}
+
+// Reauthenticate 已登录的用户再次验证身份，成功后签发属于当前会话的短期令牌，用于修改密码等敏感操作
+// 提供密码时通过配置的认证后端校验，否则校验 TOTP 验证码或恢复码；密码错误同样计入登录失败次数
+func (s *UserService) Reauthenticate(ctx context.Context, claims *utils.JWTClaims, password, code, recoveryCode string, client ClientInfo) (string, time.Duration, error) {
+	user, err := s.GetUserByID(ctx, claims.UserID)
 	if err != nil {
-		return "", err
+		return "", 0, err
+	}
+
+	var amr []string
+	switch {
+	case password != "":
+		account := loginAccountByID(user.ID)
+		if err := s.throttle.Check(ctx, account, client); err != nil {
+			return "", 0, err
+		}
+		authenticated, err := s.authenticator.Authenticate(ctx, user.Username, password)
+		if err == nil && authenticated.ID != user.ID {
+			err = ErrWrongPassword
+		}
+		if err != nil {
+			if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrWrongPassword) {
+				if err := s.throttle.RecordFailure(ctx, account, client); err != nil {
+					return "", 0, err
+				}
+				return "", 0, ErrWrongPassword
+			}
+			return "", 0, err
+		}
+		if err := s.throttle.RecordSuccess(ctx, account); err != nil {
+			return "", 0, err
+		}
+		amr = []string{utils.AMRPassword}
+	case code != "" || recoveryCode != "":
+		// 验证码和恢复码的失败次数与登录第二步共用按用户累计的计数，达到上限后锁定
+		if err := s.mfa.VerifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+			return "", 0, err
+		}
+		amr = []string{utils.AMROTP}
+	default:
+		return "", 0, errors.New("请提供密码或验证码")
+	}
+
+	token, err := s.tokens.IssueElevatedToken(ctx, user.ID, claims.SessionID, amr, s.reauthTokenTTL)
+	if err != nil {
+		return "", 0, err
+	}
+	return token, s.reauthTokenTTL, nil
+}
+
+// DeleteAccount 注销账号：撤销全部令牌后彻底删除用户，最后一个管理员不能注销
+func (s *UserService) DeleteAccount(ctx context.Context, userID uint) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(user.RoleNames(), models.RoleAdmin) {
+		count, err := s.roles.CountUsers(ctx, models.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			return errors.New("不能注销最后一个管理员")
+		}
 	}
 
-	return token, nil
+	if err := s.tokens.RevokeAllTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteAccount(ctx, userID); err != nil {
+		return fmt.Errorf("注销账号失败: %w", err)
+	}
+	return nil
+}
+
+// BackfillNormalizedIdentities 为升级前创建的用户回填规范化的用户名和邮箱，返回需要人工处理的冲突
+func (s *UserService) BackfillNormalizedIdentities(ctx context.Context) ([]repositories.IdentityConflict, error) {
+	conflicts, err := s.repo.BackfillNormalizedIdentities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("回填规范化用户名和邮箱失败: %w", err)
+	}
+	return conflicts, nil
+}
+
+// upgradePasswordHash 密码哈希的算法或参数与当前配置不一致时，使用本次登录的明文密码重新生成
+// 用户可能通过 LDAP 等其他后端认证，只有密码与本地哈希匹配时才更新；更新失败不影响登录
+func (s *UserService) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	if !user.HasPassword() || !s.hasher.NeedsRehash(user.Password) || !user.CheckPassword(password) {
+		return
+	}
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		logger.CtxErrorf(ctx, "重新生成密码哈希失败, userID: %d, error: %v", user.ID, err)
+		return
+	}
+	if err := s.repo.ReplacePassword(ctx, user.ID, user.Password, hashedPassword); err != nil {
+		logger.CtxErrorf(ctx, "更新密码哈希失败, userID: %d, error: %v", user.ID, err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// UnlockLogin 解除用户因登录或第二因素验证失败次数过多而被施加的锁定
+func (s *UserService) UnlockLogin(ctx context.Context, userID uint) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// 用户创建之前 (例如 LDAP 用户首次登录前) 按登录名累计的失败记录一并清除
+	if err := s.throttle.Unlock(ctx, loginAccountByID(user.ID), loginAccountByName(user.Username), loginAccountByName(user.Email)); err != nil {
+		return err
+	}
+	return s.mfa.Unlock(ctx, userID)
+}
+
+// verifyPassword 校验已登录用户的当前密码，与登录共用按用户ID累计的失败计数
+// 密码错误时返回 ErrWrongPassword，达到失败次数后返回 *LoginLockedError
+func (s *UserService) verifyPassword(ctx context.Context, user *models.User, password string, client ClientInfo) error {
+	account := loginAccountByID(user.ID)
+	if err := s.throttle.Check(ctx, account, client); err != nil {
+		return err
+	}
+	if !user.CheckPassword(password) {
+		if err := s.throttle.RecordFailure(ctx, account, client); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+	return s.throttle.RecordSuccess(ctx, account)
+}
+
+// loginAccount 返回登录失败计数使用的账号标识：能找到本地用户时按用户ID计数，否则按登录名计数
+// 用户不存在时同样查询一次数据库，响应时间不会暴露账号是否存在
+func (s *UserService) loginAccount(ctx context.Context, username string) (string, error) {
+	user, err := s.repo.FindByUsernameOrEmail(ctx, username)
+	if err == nil {
+		return loginAccountByID(user.ID), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return loginAccountByName(username), nil
 }
 
 // GetUserByID 通过ID获取用户
 func (s *UserService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
-	user, err := s.repo.FindByID(ctx, id)
+	user, err := s.repo.FindByIDWithRoles(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("用户不存在")
@@ -103,3 +438,31 @@ func (s *UserService) GetUserByID(ctx context.Context, id uint) (*models.User, e
 	}
 	return user, nil
 }
+
+// validateUsername 检查用户名能否用于创建本地用户
+// 用户名不能包含 @，否则可能与其他用户的邮箱相同；NFKC 规范化可能使字符串变长，规范化前后都不能超过列宽
+func validateUsername(username string) error {
+	normalized := utils.NormalizeIdentity(username)
+	switch {
+	case normalized == "":
+		return errors.New("用户名不能为空")
+	case strings.Contains(normalized, "@"):
+		return errors.New("用户名不能包含 @")
+	case utf8.RuneCountInString(username) > usernameMaxLength || utf8.RuneCountInString(normalized) > usernameMaxLength:
+		return fmt.Errorf("用户名不能超过 %d 个字符", usernameMaxLength)
+	}
+	return nil
+}
+
+// createUserWithDefaultRole 在事务中保存新用户并分配默认角色
+func createUserWithDefaultRole(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	txRepo := repositories.NewUserRepository(tx)
+	if err := txRepo.Create(ctx, user); err != nil {
+		return err
+	}
+	defaultRole, err := repositories.NewRoleRepository(tx).FindByName(ctx, models.RoleUser)
+	if err != nil {
+		return fmt.Errorf("获取默认角色失败: %w", err)
+	}
+	return txRepo.AddRoles(ctx, user, []models.Role{*defaultRole})
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	testLoginMaxAttempts     = 3
+	testLoginLockoutDuration = 15 * time.Minute
+)
+
+type userServiceFixture struct {
+	service *UserService
+	db      *gorm.DB
+	hasher  *utils.PasswordHasher
+}
+
+// newUserServiceFixture 创建只使用数据库认证后端的用户服务，关闭退避等待以便连续尝试登录
+func newUserServiceFixture(t *testing.T, hasher *utils.PasswordHasher, requireVerifiedEmail bool) *userServiceFixture {
+	t.Helper()
+	db := newTestDB(t)
+	rdb, _ := newTestRedis(t)
+	users := repositories.NewUserRepository(db)
+	completer := newTestLoginCompleter(db, rdb, requireVerifiedEmail)
+	verification := NewEmailVerificationService(users, &testMailer{}, rdb, "verify-secret", "http://localhost", time.Hour, time.Minute)
+	throttle := NewLoginThrottleService(rdb, LoginThrottleOptions{
+		MaxAttempts:     testLoginMaxAttempts,
+		IPMaxAttempts:   1000,
+		Window:          15 * time.Minute,
+		LockoutDuration: testLoginLockoutDuration,
+	})
+	service := NewUserService(users, repositories.NewRoleRepository(db), completer.tokens, hasher, NewPasswordPolicy(PasswordPolicyOptions{MinLength: 1, MaxLength: 128}, nil),
+		completer.mfa, verification, throttle, NewDatabaseAuthenticator(users, hasher), completer, rdb, false, 5*time.Minute)
+	return &userServiceFixture{service: service, db: db, hasher: hasher}
+}
+
+// createUser 创建用户，verified 为 false 时邮箱未验证，password 为空时用户没有密码
+func (f *userServiceFixture) createUser(t *testing.T, username, email, password string, verified bool) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: email}
+	if password != "" {
+		if err := user.SetPassword(f.hasher, password); err != nil {
+			t.Fatalf("设置密码失败: %v", err)
+		}
+	}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := createUserWithDefaultRole(context.Background(), f.db, user); err != nil {
+		t.Fatalf("创建用户 %s 失败: %v", username, err)
+	}
+	return user
+}
+
+func TestUserServiceLoginUniformErrors(t *testing.T) {
+	tests := []struct {
+		name                 string
+		requireVerifiedEmail bool
+		username             string
+		password             string
+		wantErr              error
+	}{
+		{name: "用户不存在", username: "ghost", password: "correct-password", wantErr: ErrInvalidCredentials},
+		{name: "邮箱不存在", username: "ghost@example.com", password: "correct-password", wantErr: ErrInvalidCredentials},
+		{name: "密码错误", username: "alice", password: "wrong-password", wantErr: ErrInvalidCredentials},
+		{name: "使用邮箱登录且密码错误", username: "alice@example.com", password: "wrong-password", wantErr: ErrInvalidCredentials},
+		{name: "没有密码的外部账号", username: "federated", password: "correct-password", wantErr: ErrInvalidCredentials},
+		{name: "邮箱未验证且密码错误", requireVerifiedEmail: true, username: "unverified", password: "wrong-password", wantErr: ErrInvalidCredentials},
+		// 只有知道密码的人才能得知邮箱未验证
+		{name: "邮箱未验证且密码正确", requireVerifiedEmail: true, username: "unverified", password: "correct-password", wantErr: ErrEmailNotVerified},
+		{name: "用户名和密码正确", requireVerifiedEmail: true, username: "alice", password: "correct-password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserServiceFixture(t, newTestHasher(), tt.requireVerifiedEmail)
+			f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+			f.createUser(t, "federated", "federated@example.com", "", true)
+			f.createUser(t, "unverified", "unverified@example.com", "correct-password", false)
+
+			result, err := f.service.Login(context.Background(), tt.username, tt.password, ClientInfo{IP: "192.0.2.1"})
+			if tt.wantErr == nil {
+				if err != nil || result.Tokens == nil {
+					t.Fatalf("登录失败: %+v, %v", result, err)
+				}
+				return
+			}
+			// 必须是同一个错误值，错误信息不能因账号是否存在而不同
+			if err != tt.wantErr {
+				t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUserServiceLoginLockoutDoesNotRevealAccounts(t *testing.T) {
+	f := newUserServiceFixture(t, newTestHasher(), false)
+	f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+	ctx := context.Background()
+	client := ClientInfo{IP: "192.0.2.1"}
+
+	lockedError := func(username, password string) *LoginLockedError {
+		t.Helper()
+		for i := 0; i < testLoginMaxAttempts; i++ {
+			if _, err := f.service.Login(ctx, username, "wrong-password", client); err != ErrInvalidCredentials {
+				t.Fatalf("第 %d 次失败期望错误 %v，实际为 %v", i+1, ErrInvalidCredentials, err)
+			}
+		}
+		_, err := f.service.Login(ctx, username, password, client)
+		var locked *LoginLockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("%s 达到失败次数后应被锁定，实际为 %v", username, err)
+		}
+		return locked
+	}
+
+	// 已存在的账号即使随后输入了正确的密码也被锁定，不存在的账号以同样的方式锁定
+	existing := lockedError("alice", "correct-password")
+	unknown := lockedError("ghost", "correct-password")
+	if !existing.Locked || !unknown.Locked {
+		t.Fatalf("期望锁定，实际为 %+v 和 %+v", existing, unknown)
+	}
+	if diff := existing.RetryAfter - unknown.RetryAfter; diff > 2*time.Second || diff < -2*time.Second {
+		t.Fatalf("锁定时长不应因账号是否存在而不同: %v 和 %v", existing.RetryAfter, unknown.RetryAfter)
+	}
+	if existing.RetryAfter > testLoginLockoutDuration || existing.RetryAfter < testLoginLockoutDuration-time.Minute {
+		t.Fatalf("锁定时长不正确: %v", existing.RetryAfter)
+	}
+}
+
+func TestUserServiceLoginTimingParity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("计时测试较慢")
+	}
+	// 使用有实际开销的哈希成本，否则数据库查询的耗时会掩盖差异
+	hasher := utils.NewPasswordHasher(utils.PasswordHasherOptions{Algorithm: "bcrypt", BcryptCost: 8})
+	f := newUserServiceFixture(t, hasher, true)
+	f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+	f.createUser(t, "federated", "federated@example.com", "", true)
+	f.createUser(t, "unverified", "unverified@example.com", "correct-password", false)
+	ctx := context.Background()
+
+	// 每个账号的失败次数达到阈值前清空计数，避免锁定后直接返回
+	measure := func(username string) time.Duration {
+		const samples = 7
+		durations := make([]time.Duration, 0, samples)
+		for i := 0; i < samples; i++ {
+			account, err := f.service.loginAccount(ctx, username)
+			if err != nil {
+				t.Fatalf("获取登录账号标识失败: %v", err)
+			}
+			if err := f.service.throttle.Unlock(ctx, account); err != nil {
+				t.Fatalf("清除登录失败计数失败: %v", err)
+			}
+			start := time.Now()
+			if _, err := f.service.Login(ctx, username, "wrong-password", ClientInfo{IP: "192.0.2.1"}); err != ErrInvalidCredentials {
+				t.Fatalf("期望错误 %v，实际为 %v", ErrInvalidCredentials, err)
+			}
+			durations = append(durations, time.Since(start))
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		return durations[samples/2]
+	}
+
+	// 预热：第一次使用时才生成用于对比的随机密码哈希
+	measure("ghost")
+
+	baseline := measure("alice")
+	for _, username := range []string{"ghost", "ghost@example.com", "federated", "unverified"} {
+		elapsed := measure(username)
+		ratio := float64(elapsed) / float64(baseline)
+		if ratio < 0.5 || ratio > 2 {
+			t.Errorf("%s 登录失败耗时 %v，与密码错误的 %v 相差过大", username, elapsed, baseline)
+		}
+	}
+}
+
+func TestUserServiceLoginThrottleSharedAcrossIdentifiers(t *testing.T) {
+	f := newUserServiceFixture(t, newTestHasher(), false)
+	alice := f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+	ctx := context.Background()
+	client := ClientInfo{IP: "192.0.2.1"}
+
+	// 使用用户名、邮箱和大小写变体的失败都累计到同一个账号上
+	for _, username := range []string{"alice", "Alice@Example.com", "ALICE"} {
+		if _, err := f.service.Login(ctx, username, "wrong-password", client); err != ErrInvalidCredentials {
+			t.Fatalf("期望错误 %v，实际为 %v", ErrInvalidCredentials, err)
+		}
+	}
+	for _, username := range []string{"alice", "alice@example.com"} {
+		var locked *LoginLockedError
+		if _, err := f.service.Login(ctx, username, "correct-password", client); !errors.As(err, &locked) {
+			t.Fatalf("使用 %s 登录期望被锁定，实际为 %v", username, err)
+		}
+	}
+
+	// 管理员解除锁定后无论使用用户名还是邮箱都可以登录
+	if err := f.service.UnlockLogin(ctx, alice.ID); err != nil {
+		t.Fatalf("解除锁定失败: %v", err)
+	}
+	if _, err := f.service.Login(ctx, "alice@example.com", "correct-password", client); err != nil {
+		t.Fatalf("解除锁定后登录失败: %v", err)
+	}
+}
+
+func TestUserServiceRegisterValidatesUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{name: "普通用户名", username: "alice"},
+		{name: "包含 @", username: "bob@example.com", wantErr: true},
+		{name: "包含全角 @", username: "bob＠example.com", wantErr: true},
+		{name: "只有空白", username: "   ", wantErr: true},
+		{name: "长度恰好为上限", username: strings.Repeat("a", usernameMaxLength)},
+		{name: "超过长度上限", username: strings.Repeat("a", usernameMaxLength+1), wantErr: true},
+		// U+FDFA 经 NFKC 规范化后展开为 18 个字符
+		{name: "规范化后超过长度上限", username: strings.Repeat("\ufdfa", 3), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserServiceFixture(t, newTestHasher(), false)
+			user, err := f.service.Register(context.Background(), tt.username, "correct-password", "new@example.com", "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望拒绝用户名 %q，实际创建了用户 %+v", tt.username, user)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("注册失败: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserServiceChangeEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		wantErr      error
+		wantSame     bool // 期望因与当前邮箱相同而被拒绝
+		wantVerified bool
+	}{
+		{name: "更换为新邮箱", email: "alice@example.org"},
+		{name: "只修改大小写", email: "Alice@Example.com", wantVerified: true},
+		{name: "与当前邮箱完全相同", email: "alice@example.com", wantSame: true},
+		{name: "其他用户的邮箱", email: "bob@example.com", wantErr: ErrEmailTaken},
+		{name: "其他用户的邮箱只是大小写不同", email: "BOB@example.com", wantErr: ErrEmailTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserServiceFixture(t, newTestHasher(), false)
+			alice := f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+			f.createUser(t, "bob", "bob@example.com", "correct-password", true)
+			ctx := context.Background()
+
+			user, err := f.service.ChangeEmail(ctx, alice.ID, "correct-password", tt.email, ClientInfo{IP: "192.0.2.1"})
+			switch {
+			case tt.wantErr != nil:
+				if err != tt.wantErr {
+					t.Fatalf("期望错误 %v，实际为 %v", tt.wantErr, err)
+				}
+				return
+			case tt.wantSame:
+				if err == nil || err == ErrEmailTaken {
+					t.Fatalf("期望提示与当前邮箱相同，实际为 %v", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("更换邮箱失败: %v", err)
+			}
+
+			saved, err := f.service.GetUserByID(ctx, alice.ID)
+			if err != nil {
+				t.Fatalf("查询用户失败: %v", err)
+			}
+			if saved.Email != tt.email || user.Email != tt.email {
+				t.Fatalf("邮箱未更新: %s", saved.Email)
+			}
+			if (saved.EmailVerifiedAt != nil) != tt.wantVerified {
+				t.Fatalf("邮箱验证状态不正确: %v", saved.EmailVerifiedAt)
+			}
+		})
+	}
+}
+
+func TestUserServiceBackfillReportsUsernamesWithAt(t *testing.T) {
+	f := newUserServiceFixture(t, newTestHasher(), false)
+	f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+	// 升级前创建的用户名，已回填过的用户同样需要列出
+	legacy := f.createUser(t, "Bob@Example.com", "bob@example.org", "correct-password", true)
+
+	conflicts, err := f.service.BackfillNormalizedIdentities(context.Background())
+	if err != nil {
+		t.Fatalf("回填失败: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("期望列出 1 个用户，实际为 %+v", conflicts)
+	}
+	got := conflicts[0]
+	if got.Kind != repositories.IdentityConflictUsernameAt || got.Value != "bob@example.com" || len(got.UserIDs) != 1 || got.UserIDs[0] != legacy.ID {
+		t.Fatalf("冲突信息不正确: %+v", got)
+	}
+}
+
+func TestUserServicePasswordChecksShareLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name   string
+		verify func(f *userServiceFixture, userID uint, password string) error
+	}{
+		{
+			name: "修改密码",
+			verify: func(f *userServiceFixture, userID uint, password string) error {
+				return f.service.ChangePassword(context.Background(), userID, password, "new-password", ClientInfo{IP: "192.0.2.1"})
+			},
+		},
+		{
+			name: "更换邮箱",
+			verify: func(f *userServiceFixture, userID uint, password string) error {
+				_, err := f.service.ChangeEmail(context.Background(), userID, password, "alice@example.org", ClientInfo{IP: "192.0.2.1"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newUserServiceFixture(t, newTestHasher(), false)
+			alice := f.createUser(t, "alice", "alice@example.com", "correct-password", true)
+
+			for i := 0; i < testLoginMaxAttempts; i++ {
+				var locked *LoginLockedError
+				if err := tt.verify(f, alice.ID, "wrong-password"); err == nil || errors.As(err, &locked) {
+					t.Fatalf("第 %d 次失败期望密码错误，实际为 %v", i+1, err)
+				}
+			}
+			// 达到失败次数后即使密码正确也被锁定，登录同样被锁定
+			var locked *LoginLockedError
+			if err := tt.verify(f, alice.ID, "correct-password"); !errors.As(err, &locked) {
+				t.Fatalf("达到失败次数后应被锁定，实际为 %v", err)
+			}
+			if _, err := f.service.Login(context.Background(), "alice", "correct-password", ClientInfo{IP: "192.0.2.1"}); !errors.As(err, &locked) {
+				t.Fatalf("达到失败次数后登录应被锁定，实际为 %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/plusone/models"
+	"github.com/plusone/repositories"
+	"github.com/plusone/utils"
+	"github.com/plusone/utils/logger"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// WebAuthnSessionTTL 注册和登录仪式中挑战的有效期
+	WebAuthnSessionTTL = 5 * time.Minute
+
+	webAuthnRegistrationKeyPrefix = "webauthn_registration:"
+	webAuthnLoginKeyPrefix        = "webauthn_login:"
+)
+
+var (
+	ErrWebAuthnSessionExpired = errors.New("WebAuthn 验证已过期，请重新开始")
+	ErrWebAuthnLoginFailed    = errors.New("通行密钥验证失败")
+)
+
+// webAuthnUser 将 models.User 适配为 webauthn.User
+type webAuthnUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+// WebAuthnID 用户句柄，使用用户ID的 8 字节大端编码，不包含用户名等个人信息
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.user.ID)
+}
+
+// WebAuthnName 用户名
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+// WebAuthnDisplayName 显示名称，未设置昵称时使用用户名
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Nickname != "" {
+		return u.user.Nickname
+	}
+	return u.user.Username
+}
+
+// WebAuthnIcon 已从规范中移除，保留空实现以满足接口
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// WebAuthnCredentials 用户已注册的凭据
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			continue
+		}
+		transports := make([]protocol.AuthenticatorTransport, 0)
+		for _, t := range c.TransportList() {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// WebAuthnService WebAuthn 依赖方服务，负责通行密钥的注册和登录仪式
+type WebAuthnService struct {
+	relyingParty *webauthn.WebAuthn
+	userRepo     *repositories.UserRepository
+	repo         *repositories.WebAuthnCredentialRepository
+	completer    *LoginCompleter
+	rdb          *redis.Client
+}
+
+// NewWebAuthnService 创建 WebAuthn 服务实例
+func NewWebAuthnService(relyingParty *webauthn.WebAuthn, userRepo *repositories.UserRepository, repo *repositories.WebAuthnCredentialRepository, completer *LoginCompleter, rdb *redis.Client) *WebAuthnService {
+	return &WebAuthnService{
+		relyingParty: relyingParty,
+		userRepo:     userRepo,
+		repo:         repo,
+		completer:    completer,
+		rdb:          rdb,
+	}
+}
+
+// BeginRegistration 开始注册凭据，返回传给 navigator.credentials.create() 的参数
+// 已注册的凭据会被排除，避免同一认证器重复注册
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID uint) (*protocol.CredentialCreation, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := s.relyingParty.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("生成注册参数失败: %w", err)
+	}
+
+	if err := s.saveSession(ctx, webAuthnRegistrationKeyPrefix+fmt.Sprint(userID), session); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// FinishRegistration 校验认证器返回的注册响应并保存凭据，每个挑战只能使用一次
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID uint, name string, body io.Reader) (*models.WebAuthnCredential, error) {
+	session, err := s.takeSession(ctx, webAuthnRegistrationKeyPrefix+fmt.Sprint(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("无效的注册响应: %w", err)
+	}
+	credential, err := s.relyingParty.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("注册凭据校验失败: %w", err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	if name == "" {
+		name = "通行密钥"
+	}
+
+	record := &models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, ","),
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, fmt.Errorf("保存凭据失败: %w", err)
+	}
+	return record, nil
+}
+
+// BeginLogin 开始通行密钥登录，返回会话ID和传给 navigator.credentials.get() 的参数
+// username 为空时使用可发现凭据登录，由认证器选择账号
+// 用户不存在和未注册凭据返回相同的错误，避免泄露账号是否存在
+// 通行密钥登录视为多因素认证并跳过 TOTP，因此要求认证器验证用户 (PIN 或生物识别)，仅触摸的安全密钥不能登录
+func (s *WebAuthnService) BeginLogin(ctx context.Context, username string) (string, *protocol.CredentialAssertion, error) {
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var err error
+
+	if username == "" {
+		assertion, session, err = s.relyingParty.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	} else {
+		var user *models.User
+		user, err = s.userRepo.FindByUsername(ctx, username)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", nil, ErrWebAuthnLoginFailed
+			}
+			return "", nil, err
+		}
+		var waUser *webAuthnUser
+		if waUser, err = s.loadUser(ctx, user.ID); err != nil {
+			return "", nil, err
+		}
+		if len(waUser.credentials) == 0 {
+			return "", nil, ErrWebAuthnLoginFailed
+		}
+		assertion, session, err = s.relyingParty.BeginLogin(waUser, webauthn.WithUserVerification(protocol.VerificationRequired))
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("生成登录参数失败: %w", err)
+	}
+
+	sessionID, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.saveSession(ctx, webAuthnLoginKeyPrefix+utils.HashToken(sessionID), session); err != nil {
+		return "", nil, err
+	}
+	return sessionID, assertion, nil
+}
+
+// FinishLogin 校验认证器返回的断言，成功后签发与密码登录相同的令牌
+// 签名计数器回退说明凭据可能被克隆，此时拒绝登录；开启了邮箱验证要求时邮箱未验证的用户同样不能登录
+func (s *WebAuthnService) FinishLogin(ctx context.Context, sessionID string, body io.Reader, client ClientInfo) (*TokenPair, error) {
+	session, err := s.takeSession(ctx, webAuthnLoginKeyPrefix+utils.HashToken(sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("无效的登录响应: %w", err)
+	}
+
+	var user *webAuthnUser
+	var credential *webauthn.Credential
+	if len(session.UserID) == 0 {
+		credential, err = s.relyingParty.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			userID, ok := parseWebAuthnUserHandle(userHandle)
+			if !ok {
+				return nil, ErrWebAuthnLoginFailed
+			}
+			loaded, err := s.loadUser(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			user = loaded
+			return loaded, nil
+		}, *session, parsed)
+	} else {
+		userID, ok := parseWebAuthnUserHandle(session.UserID)
+		if !ok {
+			return nil, ErrWebAuthnSessionExpired
+		}
+		if user, err = s.loadUser(ctx, userID); err != nil {
+			return nil, err
+		}
+		credential, err = s.relyingParty.ValidateLogin(user, *session, parsed)
+	}
+	if err != nil {
+		logger.CtxWarnf(ctx, "通行密钥验证失败: %v", err)
+		return nil, ErrWebAuthnLoginFailed
+	}
+
+	record, err := s.repo.FindByCredentialID(ctx, base64.RawURLEncoding.EncodeToString(credential.ID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnLoginFailed
+		}
+		return nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		logger.CtxWarnf(ctx, "通行密钥签名计数器异常，可能已被克隆, userID: %d, credentialID: %d", record.UserID, record.ID)
+		return nil, ErrWebAuthnLoginFailed
+	}
+	if err := s.repo.UpdateAfterLogin(ctx, record.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// 通行密钥本身即为多因素认证 (持有认证器且已验证用户)，不再要求 TOTP，但仍需满足邮箱验证等登录策略
+	result, err := s.completer.Complete(ctx, user.user, client, utils.AMRHardwareKey, true)
+	if err != nil {
+		return nil, err
+	}
+	return result.Tokens, nil
+}
+
+// ListCredentials 列出用户已注册的凭据
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID uint) ([]models.WebAuthnCredential, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// DeleteCredential 删除用户的凭据
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id uint) error {
+	affected, err := s.repo.DeleteByUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("凭据不存在")
+	}
+	return nil
+}
+
+// loadUser 加载用户及其已注册的凭据
+func (s *WebAuthnService) loadUser(ctx context.Context, userID uint) (*webAuthnUser, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	credentials, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// saveSession 将仪式的会话数据保存到 Redis
+func (s *WebAuthnService) saveSession(ctx context.Context, key string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, key, data, WebAuthnSessionTTL).Err()
+}
+
+// takeSession 取出并删除仪式的会话数据，保证每个挑战只能使用一次
+func (s *WebAuthnService) takeSession(ctx context.Context, key string) (*webauthn.SessionData, error) {
+	data, err := s.rdb.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrWebAuthnSessionExpired
+		}
+		return nil, err
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("解析 WebAuthn 会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+// webAuthnUserHandle 将用户ID编码为用户句柄
+func webAuthnUserHandle(userID uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+// parseWebAuthnUserHandle 从用户句柄中解析用户ID
+func parseWebAuthnUserHandle(handle []byte) (uint, bool) {
+	if len(handle) != 8 {
+		return 0, false
+	}
+	return uint(binary.BigEndian.Uint64(handle)), true
+}
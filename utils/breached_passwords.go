@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// breachedLineMaxLength 语料库中单行的最大长度，HIBP 的行为 40 位哈希加出现次数
+const breachedLineMaxLength = 256
+
+// BreachedPasswords 本地的泄露密码语料库，不需要访问网络
+// 支持 HIBP Pwned Passwords 下载工具的两种输出格式：
+//   - 目录：按 SHA-1 前 5 位拆分的文件 <目录>/<前缀>.txt，每行为 "后 35 位:次数"，与 range API 的响应一致
+//   - 单个文件：按哈希排序的 "40 位哈希:次数"，查找时二分定位，不需要把文件读入内存
+type BreachedPasswords struct {
+	path string
+}
+
+// NewBreachedPasswords 创建泄露密码语料库实例，path 为目录或按哈希排序的文件
+func NewBreachedPasswords(path string) *BreachedPasswords {
+	return &BreachedPasswords{path: path}
+}
+
+// Contains 判断密码是否出现在语料库中
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, fmt.Errorf("读取泄露密码库失败: %w", err)
+	}
+	if info.IsDir() {
+		return b.searchPrefixFile(hash)
+	}
+	return b.searchSortedFile(hash, info.Size())
+}
+
+// searchPrefixFile 在哈希前缀对应的文件中查找后缀，没有该前缀的文件时视为不存在
+func (b *BreachedPasswords) searchPrefixFile(hash string) (bool, error) {
+	file, err := os.Open(filepath.Join(b.path, hash[:5]+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取泄露密码库失败: %w", err)
+	}
+	defer file.Close()
+
+	suffix := []byte(hash[5:])
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, _, _ := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if bytes.EqualFold(bytes.TrimSpace(candidate), suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("读取泄露密码库失败: %w", err)
+	}
+	return false, nil
+}
+
+// searchSortedFile 在按哈希排序的文件中二分查找
+// [lo, hi) 始终覆盖目标行可能的起始位置，每轮取中点之后的第一个完整行比较
+func (b *BreachedPasswords) searchSortedFile(hash string, size int64) (bool, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return false, fmt.Errorf("读取泄露密码库失败: %w", err)
+	}
+	defer file.Close()
+
+	target := []byte(hash)
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAfter(file, mid)
+		if err != nil {
+			return false, fmt.Errorf("读取泄露密码库失败: %w", err)
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		candidate, _, _ := bytes.Cut(line, []byte(":"))
+		switch cmp := bytes.Compare(bytes.ToUpper(bytes.TrimSpace(candidate)), target); {
+		case cmp == 0:
+			return true, nil
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = start
+		}
+	}
+	return false, nil
+}
+
+// lineAfter 返回从 offset 起第一个完整行的起始位置和内容 (不含换行符)，offset 之后没有完整行时起始位置为文件末尾
+func lineAfter(r io.ReaderAt, offset int64) (int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// 从前一个字节开始读，offset 恰好是行首时也能识别
+		buf, err := readChunk(r, offset-1)
+		if err != nil {
+			return 0, nil, err
+		}
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			if len(buf) == breachedLineMaxLength {
+				return 0, nil, fmt.Errorf("文件格式不正确，单行超过 %d 字节", breachedLineMaxLength)
+			}
+			return offset - 1 + int64(len(buf)), nil, nil
+		}
+		start = offset + int64(i)
+	}
+
+	buf, err := readChunk(r, start)
+	if err != nil {
+		return 0, nil, err
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	} else if len(buf) == breachedLineMaxLength {
+		return 0, nil, fmt.Errorf("文件格式不正确，单行超过 %d 字节", breachedLineMaxLength)
+	}
+	return start, buf, nil
+}
+
+// readChunk 从 offset 读取最多一行长度的数据，到达文件末尾时返回已读到的部分
+func readChunk(r io.ReaderAt, offset int64) ([]byte, error) {
+	buf := make([]byte, breachedLineMaxLength)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:n], nil
+}
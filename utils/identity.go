@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeIdentity 规范化用户名或邮箱：去除首尾空白后做 Unicode NFKC 规范化并转为小写
+// 全角字符、兼容字符和大小写不同的写法得到相同的结果，用于唯一性判断和登录查找
+func NormalizeIdentity(value string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(value)))
+}
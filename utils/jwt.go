@@ -5,36 +5,96 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+var (
+	ErrTokenExpired         = errors.New("令牌已过期")
+	ErrTokenNotValidYet     = errors.New("令牌尚未生效")
+	ErrTokenInvalidAudience = errors.New("令牌的受众不匹配")
+	ErrTokenInvalidIssuer   = errors.New("令牌的签发者不匹配")
+)
+
+// 认证方式 (amr 声明)，取值参考 RFC 8176
+const (
+	AMRPassword    = "pwd" // 密码，包括 LDAP 等目录服务的密码
+	AMROTP         = "otp" // TOTP 验证码或恢复码
+	AMRHardwareKey = "hwk" // WebAuthn 通行密钥
+	AMRFederated   = "fed" // 外部身份提供方 (OpenID Connect、OAuth2、SAML)
+)
+
+// TokenOptions 签发和验证访问令牌时使用的标准声明配置
+type TokenOptions struct {
+	Issuer   string        // iss，留空则不签发也不校验
+	Audience string        // aud，留空则不签发也不校验
+	TTL      time.Duration // 访问令牌有效期
+	Leeway   time.Duration // 校验 exp/nbf/iat 时允许的时钟偏差
+}
+
 // JWTClaims 自定义JWT声明
 type JWTClaims struct {
-	UserID uint `json:"user_id"`
+	UserID       uint     `json:"user_id"`
+	SessionID    uint     `json:"sid,omitempty"` // 登录会话ID，个人访问令牌等非会话令牌没有此声明
+	ActorID      uint     `json:"act,omitempty"` // 模拟登录时实际操作的管理员ID，UserID 为被模拟的用户
+	TokenVersion int64    `json:"ver"`           // 用户令牌版本，递增后旧令牌全部失效
+	Roles        []string `json:"roles,omitempty"`
+	Permissions  []string `json:"perms,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"` // OAuth 客户端签发的令牌才有此声明，客户端凭证模式下 UserID 为 0
+	Scope        string   `json:"scope,omitempty"`     // OAuth 授予的作用域，空格分隔
+	AuthTime     int64    `json:"auth_time,omitempty"` // 用户最近一次验证身份的时间 (Unix 秒)，刷新令牌不会更新
+	AMR          []string `json:"amr,omitempty"`       // 最近一次验证身份使用的认证方式
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成JWT令牌
-func GenerateToken(userID uint, secret string) (string, error) {
-	claims := JWTClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)), // 24小时后过期
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// 调用方填写自定义声明，iss、aud 和有效期等标准声明由本函数根据 opts 填充；jti 未指定时自动生成
+func GenerateToken(claims JWTClaims, keys *Keyring, opts TokenOptions) (string, error) {
+	now := time.Now()
+	id := claims.ID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        id,
+		Issuer:    opts.Issuer,
+		ExpiresAt: jwt.NewNumericDate(now.Add(opts.TTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+	if opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{opts.Audience}
+	}
+
+	return keys.Sign(claims)
 }
 
-// ValidateToken 验证JWT令牌
-func ValidateToken(tokenString string, secret string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+// ValidateToken 验证JWT令牌，只接受密钥环中已配置的算法，并校验签发者、受众和有效期
+func ValidateToken(tokenString string, keys *Keyring, opts TokenOptions) (*JWTClaims, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	token, err := keys.Parse(tokenString, &JWTClaims{}, parserOpts...)
 
 	if err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrTokenExpired
+		case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+			return nil, ErrTokenNotValidYet
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, ErrTokenInvalidAudience
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+			return nil, ErrTokenInvalidIssuer
+		}
 		return nil, err
 	}
 
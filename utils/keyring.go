@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// hmacKeyID HS256 共享密钥的 kid，对称密钥不会出现在 JWKS 中
+const hmacKeyID = "hs256"
+
+// jwtKey 密钥环中的一把密钥
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // 签名用的私钥或共享密钥，仅验证用的密钥为 nil
+	verify interface{} // 验证用的公钥或共享密钥
+}
+
+// Keyring JWT 密钥环
+// 持有一把当前签名密钥和若干验证密钥，密钥轮换期间旧密钥仍可用于验证已签发的令牌
+type Keyring struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+}
+
+// JWK 单个 JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeyring 创建仅包含 HS256 共享密钥的密钥环
+func NewHMACKeyring(secret string) *Keyring {
+	key := &jwtKey{
+		id:     hmacKeyID,
+		method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}
+	return &Keyring{
+		signing: key,
+		keys:    map[string]*jwtKey{key.id: key},
+	}
+}
+
+// LoadKeyring 根据配置加载密钥环
+// algorithm 为 HS256 时使用 secret 签名；否则从 signingKeyFile 读取 PEM 私钥签名。
+// verificationKeys 形如 "kid1=path1,kid2=path2"，用于在轮换期间继续接受旧密钥签发的令牌
+func LoadKeyring(algorithm, secret, signingKeyFile, signingKeyID, verificationKeys string) (*Keyring, error) {
+	var ring *Keyring
+
+	if algorithm == "" || algorithm == jwt.SigningMethodHS256.Alg() {
+		if secret == "" {
+			return nil, errors.New("HS256 签名需要配置 JWT_SECRET")
+		}
+		ring = NewHMACKeyring(secret)
+	} else {
+		if signingKeyFile == "" {
+			return nil, fmt.Errorf("%s 签名需要配置 JWT_SIGNING_KEY_FILE", algorithm)
+		}
+		parsed, err := readPEMKey(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s 不是私钥", signingKeyFile)
+		}
+		method, err := methodForKey(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+		if method.Alg() != algorithm {
+			return nil, fmt.Errorf("签名密钥类型与算法 %s 不匹配", algorithm)
+		}
+		if signingKeyID == "" {
+			if signingKeyID, err = keyID(signer.Public()); err != nil {
+				return nil, err
+			}
+		}
+		key := &jwtKey{id: signingKeyID, method: method, sign: signer, verify: signer.Public()}
+		ring = &Keyring{
+			signing: key,
+			keys:    map[string]*jwtKey{key.id: key},
+		}
+	}
+
+	for _, entry := range strings.Split(verificationKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("无效的验证密钥配置: %s", entry)
+		}
+		if _, exists := ring.keys[kid]; exists {
+			return nil, fmt.Errorf("重复的密钥 ID: %s", kid)
+		}
+		parsed, err := readPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		public := parsed
+		if signer, ok := parsed.(crypto.Signer); ok {
+			public = signer.Public()
+		}
+		method, err := methodForKey(public)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[kid] = &jwtKey{id: kid, method: method, verify: public}
+	}
+
+	return ring, nil
+}
+
+// Sign 使用当前签名密钥签名，并在头部写入 kid
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.id
+	return token.SignedString(k.signing.sign)
+}
+
+// Parse 解析并验证令牌
+// 根据 kid 选择验证密钥，并要求令牌头部的算法与该密钥的算法一致，不信任令牌自述的算法
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods(k.Algorithms()))
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		key := k.signing
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = k.keys[kid]; !ok {
+				return nil, fmt.Errorf("未知的密钥 ID: %s", kid)
+			}
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("令牌算法 %s 与密钥不匹配", token.Method.Alg())
+		}
+		return key.verify, nil
+	}, opts...)
+}
+
+// SigningAlgorithm 返回当前签名密钥的算法
+func (k *Keyring) SigningAlgorithm() string {
+	return k.signing.method.Alg()
+}
+
+// Algorithms 返回密钥环接受的全部签名算法
+func (k *Keyring) Algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, key := range k.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	sort.Strings(algs)
+	return algs
+}
+
+// JWKS 返回所有非对称验证密钥的公钥集合
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk, ok := publicJWK(key)
+		if ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// publicJWK 将公钥编码为 JWK，对称密钥返回 false
+func publicJWK(key *jwtKey) (JWK, bool) {
+	jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+	switch pub := key.verify.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// methodForKey 根据公钥类型确定签名算法
+func methodForKey(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("不支持的椭圆曲线: %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %T", public)
+	}
+}
+
+// keyID 以公钥 DER 编码的 SHA-256 摘要前缀作为默认 kid
+func keyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// readPEMKey 读取 PEM 文件中的私钥或公钥
+func readPEMKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是有效的 PEM 文件", path)
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("无法解析密钥文件: %s", path)
+}
@@ -16,6 +16,9 @@ type loggerKey struct{}
 // 定义上下文中 TraceID 的键
 type traceIDKey struct{}
 
+// 定义上下文中模拟登录实际操作者ID的键
+type actorIDKey struct{}
+
 var logFile *os.File
 
 // Init 初始化日志系统
@@ -93,20 +96,43 @@ func GetTraceID(ctx context.Context) string {
 	return ""
 }
 
+// WithActorID 将模拟登录的实际操作者ID存入 context，并让此后的日志都带上 actor_id
+func WithActorID(ctx context.Context, actorID uint) context.Context {
+	ctx = context.WithValue(ctx, actorIDKey{}, actorID)
+	return WithLogger(ctx, ctxLogger(ctx).With("actor_id", actorID))
+}
+
+// GetActorID 从 context 中获取模拟登录的实际操作者ID，非模拟登录时返回 0
+func GetActorID(ctx context.Context) uint {
+	if actorID, ok := ctx.Value(actorIDKey{}).(uint); ok {
+		return actorID
+	}
+	return 0
+}
+
+// ctxLogger 返回记录日志使用的 logger
+// 日志中间件存入 context 的 logger 已经带有 trace_id，其余情况在默认 logger 上补充 trace_id
+func ctxLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default().With("trace_id", GetTraceID(ctx))
+}
+
 // CtxErrorf 是一个辅助函数，用于从 context 记录错误日志
 func CtxErrorf(ctx context.Context, format string, args ...interface{}) {
-	log := FromContext(ctx).With("trace_id", GetTraceID(ctx))
+	log := ctxLogger(ctx)
 	log.Error(fmt.Sprintf(format, args...))
 }
 
 // CtxInfof 是一个辅助函数，用于从 context 记录信息日志
 func CtxInfof(ctx context.Context, format string, args ...interface{}) {
-	log := FromContext(ctx).With("trace_id", GetTraceID(ctx))
+	log := ctxLogger(ctx)
 	log.Info(fmt.Sprintf(format, args...))
 }
 
 // CtxWarnf 是一个辅助函数，用于从 context 记录警告日志
 func CtxWarnf(ctx context.Context, format string, args ...interface{}) {
-	log := FromContext(ctx).With("trace_id", GetTraceID(ctx))
+	log := ctxLogger(ctx)
 	log.Warn(fmt.Sprintf(format, args...))
 }
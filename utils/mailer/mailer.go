@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/plusone/utils/logger"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口，可替换为不同的发送渠道
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New 根据驱动名称创建邮件发送器，支持 "log" 和 "smtp"
+func New(driver, host, port, username, password, from string) (Mailer, error) {
+	switch driver {
+	case "", "log":
+		return NewLogMailer(), nil
+	case "smtp":
+		if host == "" || from == "" {
+			return nil, fmt.Errorf("smtp 邮件驱动需要配置 SMTP_HOST 和 MAIL_FROM")
+		}
+		return NewSMTPMailer(host, port, username, password, from), nil
+	default:
+		return nil, fmt.Errorf("不支持的邮件驱动: %s", driver)
+	}
+}
+
+// LogMailer 只把邮件写入日志，用于开发和测试环境
+type LogMailer struct{}
+
+// NewLogMailer 创建日志邮件发送器
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send 将邮件内容写入日志
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	logger.CtxInfof(ctx, "发送邮件 (仅记录日志), to: %s, subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer 创建 SMTP 邮件发送器，username 为空时不进行认证
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("邮件头包含非法字符")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String())); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// UserInfoClaims OpenID Connect 标准用户声明，按授予的作用域填充，未授予的字段为空
+type UserInfoClaims struct {
+	PreferredUsername string `json:"preferred_username,omitempty"` // profile
+	Name              string `json:"name,omitempty"`               // profile
+	Email             string `json:"email,omitempty"`              // email
+	EmailVerified     *bool  `json:"email_verified,omitempty"`     // email
+}
+
+// IDTokenClaims OpenID Connect ID Token 的声明，sub 为用户ID，aud 为客户端ID
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"` // 用户完成登录的时间
+	UserInfoClaims
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken 生成 ID Token
+// 调用方填写 sub 和用户声明，iss、aud、jti 和有效期由本函数填充
+func GenerateIDToken(claims IDTokenClaims, keys *Keyring, issuer, audience string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    issuer,
+		Subject:   claims.Subject,
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	return keys.Sign(claims)
+}
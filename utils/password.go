@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希算法
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+// BcryptMaxPasswordBytes bcrypt 能处理的最大密码长度 (字节)，更长的密码无法哈希
+const BcryptMaxPasswordBytes = 72
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// PasswordHasherOptions 密码哈希配置，只有 Algorithm 对应算法的参数生效
+type PasswordHasherOptions struct {
+	Algorithm string // argon2id 或 bcrypt
+
+	Argon2Memory      uint32 // 内存开销 (KiB)
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+
+	BcryptCost int
+}
+
+// PasswordHasher 按配置的算法和参数生成密码哈希
+// 哈希值是自描述的：argon2id 使用 PHC 字符串格式 $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>，
+// bcrypt 使用标准的 $2a$<cost>$ 格式，因此校验时不依赖当前配置，调整算法或参数后旧密码仍然可用
+type PasswordHasher struct {
+	opts PasswordHasherOptions
+
+	dummyOnce sync.Once
+	dummyHash string // 以当前参数生成的随机密码哈希，供 VerifyDummy 使用
+}
+
+// NewPasswordHasher 创建密码哈希实例，参数的合法性由配置加载时校验
+func NewPasswordHasher(opts PasswordHasherOptions) *PasswordHasher {
+	return &PasswordHasher{opts: opts}
+}
+
+// Hash 使用当前配置的算法和参数生成密码哈希
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.opts.Algorithm == PasswordAlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.opts.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	params := argon2Params{
+		Memory:      h.opts.Argon2Memory,
+		Iterations:  h.opts.Argon2Iterations,
+		Parallelism: h.opts.Argon2Parallelism,
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+	return params.encode(salt, key), nil
+}
+
+// NeedsRehash 判断哈希是否使用了与当前配置不同的算法或参数，用户下次提供正确密码时应重新生成
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	if h.opts.Algorithm == PasswordAlgorithmBcrypt {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.opts.BcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.opts.Argon2Memory ||
+		params.Iterations != h.opts.Argon2Iterations ||
+		params.Parallelism != h.opts.Argon2Parallelism ||
+		len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+}
+
+// VerifyDummy 对一个随机密码的哈希执行一次完整校验，结果总是不匹配
+// 用户不存在或没有设置密码时调用，使响应时间与真实用户的密码校验一致，无法据此判断账号是否存在
+func (h *PasswordHasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		if secret, err := GenerateRandomToken(32); err == nil {
+			h.dummyHash, _ = h.Hash(secret)
+		}
+	})
+	VerifyPassword(h.dummyHash, password)
+}
+
+// VerifyPassword 按哈希值中记录的算法和参数校验密码，无法识别的哈希视为不匹配
+func VerifyPassword(encoded, password string) bool {
+	if strings.HasPrefix(encoded, "$"+PasswordAlgorithmArgon2id+"$") {
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false
+		}
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(actual, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+}
+
+// argon2Params argon2id 的参数
+type argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// encode 按 PHC 字符串格式编码，salt 和 hash 使用无填充的标准 Base64
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		PasswordAlgorithmArgon2id, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2 解析 PHC 格式的 argon2id 哈希
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, fmt.Errorf("不是 argon2id 哈希")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("不支持的 argon2 版本: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("无效的 argon2 参数: %w", err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("无效的 argon2 参数: %s", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("无效的 argon2 盐值: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("无效的 argon2 哈希值")
+	}
+	return params, salt, key, nil
+}
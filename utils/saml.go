@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+)
+
+// SAMLServiceProviderOptions SAML 服务提供方 (SP) 配置
+type SAMLServiceProviderOptions struct {
+	EntityID          string
+	BaseURL           string // 本服务对外的根地址，元数据为 BaseURL/saml/metadata，ACS 为 BaseURL/saml/acs
+	CertFile          string
+	KeyFile           string
+	IDPMetadataFile   string // 为空时由调用方在首次使用时从元数据地址获取
+	AllowIDPInitiated bool
+}
+
+// NewSAMLServiceProvider 加载 SP 的证书和私钥，创建 SAML 服务提供方
+// 认证请求使用 SP 私钥签名 (RSA-SHA256)，断言必须由 IdP 元数据中的证书签名
+func NewSAMLServiceProvider(opts SAMLServiceProviderOptions) (*saml.ServiceProvider, error) {
+	key, err := readPEMKey(opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("SAML 私钥必须是 RSA 私钥: %s", opts.KeyFile)
+	}
+	cert, err := readPEMCertificate(opts.CertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL, err := url.Parse(opts.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的服务地址: %w", err)
+	}
+	sp := &saml.ServiceProvider{
+		EntityID:          opts.EntityID,
+		Key:               rsaKey,
+		Certificate:       cert,
+		MetadataURL:       *baseURL.JoinPath("/saml/metadata"),
+		AcsURL:            *baseURL.JoinPath("/saml/acs"),
+		AllowIDPInitiated: opts.AllowIDPInitiated,
+		SignatureMethod:   "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
+	}
+
+	if opts.IDPMetadataFile != "" {
+		data, err := os.ReadFile(opts.IDPMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 IdP 元数据失败: %w", err)
+		}
+		if sp.IDPMetadata, err = samlsp.ParseMetadata(data); err != nil {
+			return nil, fmt.Errorf("解析 IdP 元数据失败: %w", err)
+		}
+	}
+	return sp, nil
+}
+
+// readPEMCertificate 读取 PEM 文件中的 X.509 证书
+func readPEMCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取证书文件失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s 不是有效的 PEM 证书", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析证书失败: %w", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthModeHeader 登录请求中选择令牌返回方式的请求头，值为 AuthModeCookie 时令牌写入 Cookie
+	AuthModeHeader = "X-Auth-Mode"
+	AuthModeCookie = "cookie"
+	// CSRFHeader 使用 Cookie 认证的写请求回传 CSRF 令牌的请求头
+	CSRFHeader = "X-CSRF-Token"
+
+	// refreshCookiePath 刷新令牌 Cookie 只随刷新请求发送
+	refreshCookiePath = "/api/token/refresh"
+)
+
+// ErrCSRFTokenInvalid CSRF 令牌缺失或不匹配
+var ErrCSRFTokenInvalid = errors.New("CSRF 令牌无效")
+
+// SessionCookieOptions Cookie 会话配置
+type SessionCookieOptions struct {
+	Name       string // 访问令牌 Cookie 名称，刷新令牌和 CSRF 令牌分别使用 <Name>_refresh 和 <Name>_csrf
+	Domain     string
+	Secure     bool
+	SameSite   http.SameSite
+	CSRFSecret []byte
+}
+
+// SessionCookies 浏览器 Cookie 会话
+// 访问令牌和刷新令牌保存在 HttpOnly Cookie 中，前端脚本无法读取；
+// CSRF 令牌使用签名的双重提交方式：写入前端可读的 Cookie，写请求需在 X-CSRF-Token 头中回传相同的值，
+// 令牌绑定登录会话ID，其他会话的令牌即使被写入 Cookie 也无法通过校验
+type SessionCookies struct {
+	opts SessionCookieOptions
+}
+
+// NewSessionCookies 创建 Cookie 会话实例
+func NewSessionCookies(opts SessionCookieOptions) *SessionCookies {
+	return &SessionCookies{opts: opts}
+}
+
+// ParseSameSite 解析 lax、strict 或 none，其他值按 lax 处理
+func ParseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// CookieModeRequested 判断请求是否选择了 Cookie 模式
+func CookieModeRequested(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(AuthModeHeader), AuthModeCookie)
+}
+
+// Set 写入访问令牌、刷新令牌和绑定会话的 CSRF 令牌 Cookie，返回 CSRF 令牌
+func (c *SessionCookies) Set(w http.ResponseWriter, accessToken, refreshToken string, sessionID uint, accessTTL, refreshTTL time.Duration) (string, error) {
+	nonce, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	csrfToken := SignPayload(c.opts.CSRFSecret, []byte(strconv.FormatUint(uint64(sessionID), 10)+":"+nonce))
+
+	http.SetCookie(w, c.cookie(c.opts.Name, accessToken, "/", accessTTL, true))
+	http.SetCookie(w, c.cookie(c.refreshName(), refreshToken, refreshCookiePath, refreshTTL, true))
+	http.SetCookie(w, c.cookie(c.csrfName(), csrfToken, "/", refreshTTL, false))
+	return csrfToken, nil
+}
+
+// SetAccessToken 只替换访问令牌 Cookie，刷新令牌和 CSRF 令牌保持不变
+func (c *SessionCookies) SetAccessToken(w http.ResponseWriter, accessToken string, ttl time.Duration) {
+	http.SetCookie(w, c.cookie(c.opts.Name, accessToken, "/", ttl, true))
+}
+
+// Clear 删除全部会话 Cookie
+func (c *SessionCookies) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, c.cookie(c.opts.Name, "", "/", -1, true))
+	http.SetCookie(w, c.cookie(c.refreshName(), "", refreshCookiePath, -1, true))
+	http.SetCookie(w, c.cookie(c.csrfName(), "", "/", -1, false))
+}
+
+// AccessToken 读取访问令牌 Cookie，不存在时返回空字符串
+func (c *SessionCookies) AccessToken(r *http.Request) string {
+	return c.value(r, c.opts.Name)
+}
+
+// RefreshToken 读取刷新令牌 Cookie，不存在时返回空字符串
+func (c *SessionCookies) RefreshToken(r *http.Request) string {
+	return c.value(r, c.refreshName())
+}
+
+// VerifyCSRF 校验请求头中的 CSRF 令牌与 Cookie 一致、签名有效且属于指定的登录会话
+func (c *SessionCookies) VerifyCSRF(r *http.Request, sessionID uint) error {
+	header := r.Header.Get(CSRFHeader)
+	cookie := c.value(r, c.csrfName())
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 {
+		return ErrCSRFTokenInvalid
+	}
+	payload, err := VerifySignedPayload(c.opts.CSRFSecret, header)
+	if err != nil {
+		return ErrCSRFTokenInvalid
+	}
+	sid, _, _ := strings.Cut(string(payload), ":")
+	if sid != strconv.FormatUint(uint64(sessionID), 10) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// IsSafeMethod 判断请求方法是否不会修改状态，这类请求不需要 CSRF 令牌
+func IsSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func (c *SessionCookies) refreshName() string {
+	return c.opts.Name + "_refresh"
+}
+
+func (c *SessionCookies) csrfName() string {
+	return c.opts.Name + "_csrf"
+}
+
+func (c *SessionCookies) value(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// cookie 构造 Cookie，maxAge 小于 0 时删除
+func (c *SessionCookies) cookie(name, value, path string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.opts.Domain,
+		Secure:   c.opts.Secure,
+		HttpOnly: httpOnly,
+		SameSite: c.opts.SameSite,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
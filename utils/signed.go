@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidSignature 签名校验失败
+var ErrInvalidSignature = errors.New("签名无效")
+
+// SignPayload 使用 HMAC-SHA256 对数据签名，返回 "<payload>.<signature>" 形式的 URL 安全字符串
+// 数据本身只做编码不加密，不要放入需要保密的内容
+func SignPayload(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignedPayload 校验 SignPayload 生成的字符串并返回原始数据
+func VerifySignedPayload(secret []byte, signed string) ([]byte, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+	return payload, nil
+}
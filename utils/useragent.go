@@ -0,0 +1,63 @@
+package utils
+
+import "strings"
+
+// uaBrowsers 按匹配优先级排列的浏览器标识，Edge 和 Opera 的 UA 中同时包含 Chrome，需要先匹配
+var uaBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"Chrome/", "Chrome"},
+	{"CriOS/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+// uaPlatforms 按匹配优先级排列的操作系统标识，iOS 和 Android 的 UA 中可能包含其他系统的字样
+var uaPlatforms = []struct{ token, name string }{
+	{"iPhone", "iPhone"},
+	{"iPad", "iPad"},
+	{"Android", "Android"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"Linux", "Linux"},
+}
+
+// DeviceLabel 根据 User-Agent 生成可读的设备名称，如 "Chrome on Windows"
+// 只做粗略识别，用于在会话列表中帮助用户辨认设备
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "未知设备"
+	}
+
+	browser := ""
+	for _, b := range uaBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	platform := ""
+	for _, p := range uaPlatforms {
+		if strings.Contains(userAgent, p.token) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	// 非浏览器客户端通常以 "name/version" 开头，如 curl/8.0
+	name, _, _ := strings.Cut(userAgent, " ")
+	name, _, _ = strings.Cut(name, "/")
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return name
+}